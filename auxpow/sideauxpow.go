@@ -1,6 +1,7 @@
 package auxpow
 
 import (
+	"errors"
 	"io"
 
 	. "github.com/elastos/Elastos.ELA.Utility/common"
@@ -8,6 +9,12 @@ import (
 	ela "github.com/elastos/Elastos.ELA/core"
 )
 
+// MainChainVerifier checks main chain block headers, spv.Verifier implements
+// it by the SPV service or by local main chain data.
+type MainChainVerifier interface {
+	VerifyElaHeader(hash *Uint256) error
+}
+
 type SideAuxPow struct {
 	SideAuxMerkleBranch []Uint256
 	SideAuxMerkleIndex  int
@@ -108,3 +115,13 @@ func (sap *SideAuxPow) SideAuxPowCheck(hashAuxBlock Uint256) bool {
 
 	return true
 }
+
+// MainBlockHeaderCheck checks the main chain block header of the aux pow is in
+// the main chain.
+func (sap *SideAuxPow) MainBlockHeaderCheck(verifier MainChainVerifier) error {
+	if verifier == nil {
+		return errors.New("[SideAuxPow], main chain verifier is not initialized.")
+	}
+	mainBlockHeaderHash := sap.MainBlockHeader.Hash()
+	return verifier.VerifyElaHeader(&mainBlockHeaderHash)
+}
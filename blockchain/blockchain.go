@@ -46,6 +46,7 @@ type Blockchain struct {
 	Orphans        map[Uint256]*OrphanBlock
 	PrevOrphans    map[Uint256][]*OrphanBlock
 	OldestOrphan   *OrphanBlock
+	DeferredBlocks map[Uint256]*OrphanBlock
 	BlockCache     map[Uint256]*core.Block
 	TimeSource     MedianTimeSource
 	MedianTimePast time.Time
@@ -53,20 +54,27 @@ type Blockchain struct {
 	BCEvents       *events.Event
 	mutex          sync.RWMutex
 	AssetID        Uint256
+
+	versionBitsLock  sync.Mutex
+	versionBitsCache map[string]map[Uint256]ThresholdState
+
+	assumedValidLock sync.Mutex
+	assumedValid     map[Uint256]struct{}
 }
 
 func NewBlockchain(height uint32) *Blockchain {
 	return &Blockchain{
-		BlockHeight:  height,
-		Root:         nil,
-		BestChain:    nil,
-		Index:        make(map[Uint256]*BlockNode),
-		DepNodes:     make(map[Uint256][]*BlockNode),
-		OldestOrphan: nil,
-		Orphans:      make(map[Uint256]*OrphanBlock),
-		PrevOrphans:  make(map[Uint256][]*OrphanBlock),
-		BlockCache:   make(map[Uint256]*core.Block),
-		TimeSource:   NewMedianTime(),
+		BlockHeight:    height,
+		Root:           nil,
+		BestChain:      nil,
+		Index:          make(map[Uint256]*BlockNode),
+		DepNodes:       make(map[Uint256][]*BlockNode),
+		OldestOrphan:   nil,
+		Orphans:        make(map[Uint256]*OrphanBlock),
+		PrevOrphans:    make(map[Uint256][]*OrphanBlock),
+		DeferredBlocks: make(map[Uint256]*OrphanBlock),
+		BlockCache:     make(map[Uint256]*core.Block),
+		TimeSource:     NewMedianTime(),
 
 		BCEvents: events.NewEvent(),
 		AssetID:  EmptyHash,
@@ -89,6 +97,8 @@ func Init(store IChainStore) error {
 	}
 
 	DefaultLedger.Blockchain.UpdateBestHeight(height)
+	DefaultLedger.Blockchain.BCEvents.Subscribe(events.EventMainChainRollback, DefaultLedger.Blockchain.MainChainRollback)
+	DefaultLedger.Blockchain.BCEvents.Subscribe(events.EventBlockPersistCompleted, DefaultLedger.Blockchain.VerifyReorganizedDeposits)
 	return nil
 }
 
@@ -313,6 +323,57 @@ func (bc *Blockchain) AddOrphanBlock(block *core.Block) {
 	return
 }
 
+// addDeferredBlock keeps the block whose main chain block is not synced by the
+// SPV module yet, it expires 1 hour later.
+func (bc *Blockchain) addDeferredBlock(block *core.Block) {
+	hash := block.Hash()
+	if _, exists := bc.DeferredBlocks[hash]; exists {
+		return
+	}
+
+	var oldest *OrphanBlock
+	for h, deferred := range bc.DeferredBlocks {
+		if time.Now().After(deferred.Expiration) {
+			delete(bc.DeferredBlocks, h)
+			continue
+		}
+		if oldest == nil || deferred.Expiration.Before(oldest.Expiration) {
+			oldest = deferred
+		}
+	}
+	if len(bc.DeferredBlocks)+1 > maxOrphanBlocks && oldest != nil {
+		delete(bc.DeferredBlocks, oldest.Block.Hash())
+	}
+
+	bc.DeferredBlocks[hash] = &OrphanBlock{
+		Block:      block,
+		Expiration: time.Now().Add(time.Hour),
+	}
+}
+
+// ProcessDeferredBlocks processes the deferred blocks again, the blocks whose
+// main chain block has been synced by the SPV module are accepted or rejected,
+// the others are kept until they expire.
+func (bc *Blockchain) ProcessDeferredBlocks() {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	for hash, deferred := range bc.DeferredBlocks {
+		if time.Now().After(deferred.Expiration) {
+			delete(bc.DeferredBlocks, hash)
+			continue
+		}
+		_, _, err := bc.ProcessBlock(deferred.Block, bc.TimeSource, 0)
+		if err == ErrMainChainBlockNotSynced {
+			continue
+		}
+		delete(bc.DeferredBlocks, hash)
+		if err != nil {
+			log.Warnf("Deferred block %x is rejected, %s", hash.Bytes(), err)
+		}
+	}
+}
+
 func (bc *Blockchain) IsKnownOrphan(hash *Uint256) bool {
 	bc.OrphanLock.RLock()
 	defer bc.OrphanLock.RUnlock()
@@ -351,6 +412,7 @@ type BlockNode struct {
 	Timestamp   uint32
 	WorkSum     *big.Int
 	InMainChain bool
+	Status      BlockStatus
 	Parent      *BlockNode
 	Children    []*BlockNode
 }
@@ -542,6 +604,7 @@ func (bc *Blockchain) RemoveBlockNode(node *BlockNode) error {
 	// Remove the node from the node index.
 	//delete(bc.Index, *node.Hash)
 	bc.RemoveNodeFromIndex(node)
+	bc.removeThresholdStates(node.Hash)
 
 	// Unlink all of the node's children.
 	for _, child := range node.Children {
@@ -688,6 +751,14 @@ func (bc *Blockchain) ReorganizeChain(detachNodes, attachNodes *list.List) error
 		}
 	}
 
+	// Make sure the fork point is above the pruned blocks.
+	if detachNodes.Len() > 0 {
+		forkHeight := detachNodes.Back().Value.(*BlockNode).Height - 1
+		if pruneHeight, ok := DefaultLedger.Store.GetPruneHeight(); ok && forkHeight <= pruneHeight {
+			return ErrForkPruned
+		}
+	}
+
 	// Perform several checks to verify each block that needs to be attached
 	// to the main chain can be connected without violating any rules and
 	// without actually connecting the block.
@@ -772,6 +843,7 @@ func (bc *Blockchain) DisconnectBlock(node *BlockNode, block *core.Block) error
 
 	// Put block in the side chain cache.
 	node.InMainChain = false
+	node.Status |= StatusValid
 	bc.BlockCache[*node.Hash] = block
 
 	//// This node's parent is now the end of the best chain.
@@ -790,9 +862,21 @@ func (bc *Blockchain) DisconnectBlock(node *BlockNode, block *core.Block) error
 // connectBlock handles connecting the passed node/block to the end of the main
 // (best) chain.
 func (bc *Blockchain) ConnectBlock(node *BlockNode, block *core.Block) error {
+	// signatures of the blocks leading to the assume valid block are not
+	// verified
+	verifySignature := !bc.isAssumedValid(*node.Hash)
+
+	// verify signatures concurrently first, so they are not verified again
+	// below.
+	if verifySignature {
+		if err := VerifyBlockSignatures(block); err != nil {
+			log.Warn("[VerifyBlockSignatures],", err)
+			return errors.New("VerifyBlockSignatures failed when verifiy block")
+		}
+	}
 
 	for _, txVerify := range block.Transactions {
-		if errCode := CheckTransactionContext(txVerify); errCode != Success {
+		if errCode := checkTransactionContext(txVerify, node.Height, false); errCode != Success {
 			fmt.Println("CheckTransactionContext failed when verifiy block", errCode)
 			return errors.New(fmt.Sprintf("CheckTransactionContext failed when verifiy block"))
 		}
@@ -810,10 +894,12 @@ func (bc *Blockchain) ConnectBlock(node *BlockNode, block *core.Block) error {
 	if err != nil {
 		return err
 	}
+	SigCache.Remove(block.Transactions)
 
 	// Add the new node to the memory main chain indices for faster
 	// lookups.
 	node.InMainChain = true
+	node.Status |= StatusValid
 	//bc.Index[*node.Hash] = node
 	bc.AddNodeToIndex(node)
 	bc.DepNodes[*prevHash] = append(bc.DepNodes[*prevHash], node)
@@ -864,6 +950,23 @@ func (bc *Blockchain) maybeAcceptBlock(block *core.Block) (bool, error) {
 		return false, fmt.Errorf("wrong block height!")
 	}
 
+	// The block must not extend a chain with an invalidated block.
+	if isInvalidChain(prevNode) {
+		return false, fmt.Errorf("block %x extends an invalid chain", block.Hash().Bytes())
+	}
+
+	// The block must match the checkpoints, and must not fork the best chain
+	// before the latest checkpoint.
+	bestHeight := uint32(0)
+	if bc.BestChain != nil {
+		bestHeight = bc.BestChain.Height
+	}
+	err = checkCheckpoints(config.Parameters.ChainParam, blockHeight, block.Hash(), bestHeight)
+	if err != nil {
+		log.Error("checkCheckpoints error!", err)
+		return false, err
+	}
+
 	// The block must pass all of the validation rules which depend on the
 	// position of the block within the block chain.
 	err = PowCheckBlockContext(block, prevNode, DefaultLedger)
@@ -1028,9 +1131,20 @@ func (bc *Blockchain) ProcessBlock(block *core.Block, timeSource MedianTimeSourc
 
 	log.Tracef("[ProcessBLock] orphan already exist= %v", exists)
 
+	// The block must not be marked invalid by invalidateblock.
+	if DefaultLedger.Store.IsBlockInvalid(blockHash) {
+		return false, false, fmt.Errorf("block %x is marked invalid", blockHash.Bytes())
+	}
+
 	// Perform preliminary sanity checks on the block and its transactions.
 	//err = PowCheckBlockSanity(block, PowLimit, bc.TimeSource)
 	err = PowCheckBlockSanity(block, config.Parameters.ChainParam.PowLimit, bc.TimeSource)
+	if err == ErrMainChainBlockNotSynced {
+		log.Debugf("Deferring block %x until main chain block is synced", blockHash.Bytes())
+		bc.addDeferredBlock(block)
+		return false, false, err
+	}
+	delete(bc.DeferredBlocks, blockHash)
 
 	if err != nil {
 		log.Error("PowCheckBlockSanity error!", err)
@@ -9,6 +9,7 @@ import (
 	"github.com/elastos/Elastos.ELA.SideChain/config"
 	. "github.com/elastos/Elastos.ELA.SideChain/core"
 	. "github.com/elastos/Elastos.ELA.SideChain/errors"
+	"github.com/elastos/Elastos.ELA.SideChain/spv"
 
 	. "github.com/elastos/Elastos.ELA.Utility/common"
 	"github.com/elastos/Elastos.ELA.Utility/crypto"
@@ -18,14 +19,37 @@ const (
 	MaxTimeOffsetSeconds = 2 * 60 * 60
 )
 
+// ErrMainChainBlockNotSynced is returned when the main chain block in the aux
+// pow of a block is higher than the best header of the SPV module, the block
+// is deferred until the SPV module has synced the main chain block.
+var ErrMainChainBlockNotSynced = errors.New("[PowCheckBlockSanity] main chain block header is not synced")
+
+// CheckMainChainAnchor checks the main chain block header in the aux pow of
+// the block is verified by the SPV module since MainChainAnchorHeight.
+func CheckMainChainAnchor(block *Block) error {
+	if block.Header.Height < config.Parameters.ChainParam.MainChainAnchorHeight {
+		return nil
+	}
+	sideAuxPow := block.Header.SideAuxPow
+	err := sideAuxPow.MainBlockHeaderCheck(spv.GetVerifier())
+	if err == nil {
+		return nil
+	}
+
+	bestHeight, e := spv.GetBestHeight()
+	if e == nil && sideAuxPow.MainBlockHeader.Height > bestHeight {
+		return ErrMainChainBlockNotSynced
+	}
+	return errors.New("[PowCheckBlockSanity] main chain block header is not verified, " + err.Error())
+}
+
 func PowCheckBlockSanity(block *Block, powLimit *big.Int, timeSource MedianTimeSource) error {
 	header := block.Header
 
 	// A block's main chain block header must contain in spv module
-	//mainChainBlockHash := header.SideAuxPow.MainBlockHeader.Hash()
-	//if err := spv.VerifyElaHeader(&mainChainBlockHash); err != nil {
-	//	return err
-	//}
+	if err := CheckMainChainAnchor(block); err != nil {
+		return err
+	}
 
 	if !header.SideAuxPow.SideAuxPowCheck(header.Hash()) {
 		return errors.New("[PowCheckBlockSanity] block check proof is failed")
@@ -85,7 +109,7 @@ func PowCheckBlockSanity(block *Block, powLimit *big.Int, timeSource MedianTimeS
 		}
 
 		// Calculate transaction fee
-		totalTxFee += GetTxFee(tx, DefaultLedger.Blockchain.AssetID)
+		totalTxFee += GetTxFee(tx, DefaultLedger.Blockchain.AssetID, header.Height)
 	}
 
 	// Reward in coinbase must match total transaction fee
@@ -97,6 +121,8 @@ func PowCheckBlockSanity(block *Block, powLimit *big.Int, timeSource MedianTimeS
 	existingTxIds := make(map[Uint256]struct{})
 	existingTxInputs := make(map[string]struct{})
 	existingMainTxs := make(map[Uint256]struct{})
+	existingWithdrawals := make(map[Uint256]struct{})
+	existingIdentities := make(map[string]struct{})
 	for _, txn := range transactions {
 		txId := txn.Hash()
 		// Check for duplicate transactions.
@@ -132,6 +158,29 @@ func PowCheckBlockSanity(block *Block, powLimit *big.Int, timeSource MedianTimeS
 			existingMainTxs[*hash] = struct{}{}
 		}
 
+		if txn.IsWithdrawFromSideChainTx() {
+			withdrawPayload := txn.Payload.(*PayloadWithdrawFromSideChain)
+			// Check for duplicate withdrawals processed in a block
+			_, mainchainPayload, err := withdrawPayload.GetMainchainPayload()
+			if err != nil {
+				return err
+			}
+			for _, hash := range mainchainPayload.SideChainTransactionHashes {
+				if _, exists := existingWithdrawals[hash]; exists {
+					return errors.New("[PowCheckBlockSanity] block contains duplicate withdrawal")
+				}
+				existingWithdrawals[hash] = struct{}{}
+			}
+		}
+
+		// Check for IDs revoked or rotated more than once in a block
+		if id, ok := getIdentificationUpdateID(txn); ok {
+			if _, exists := existingIdentities[id]; exists {
+				return errors.New("[PowCheckBlockSanity] block contains conflict identification Tx")
+			}
+			existingIdentities[id] = struct{}{}
+		}
+
 		// Append transaction to list
 		txIds = append(txIds, txId)
 	}
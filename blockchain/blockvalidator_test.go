@@ -0,0 +1,87 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain/config"
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+	"github.com/elastos/Elastos.ELA.SideChain/log"
+	"github.com/elastos/Elastos.ELA.SideChain/spv"
+
+	ela "github.com/elastos/Elastos.ELA/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckMainChainAnchor(t *testing.T) {
+	log.Init(
+		config.Parameters.PrintLevel,
+		config.Parameters.MaxPerLogSize,
+		config.Parameters.MaxLogsSize,
+	)
+
+	anchorHeight := config.Parameters.ChainParam.MainChainAnchorHeight
+	config.Parameters.ChainParam.MainChainAnchorHeight = 10
+	defer func() {
+		config.Parameters.ChainParam.MainChainAnchorHeight = anchorHeight
+		spv.SetVerifier(nil)
+	}()
+
+	mainBlockHeader := ela.Header{Version: 1, Height: 100, Timestamp: uint32(time.Now().Unix())}
+	newBlock := func(height uint32, mainHeight uint32) *core.Block {
+		block := &core.Block{Header: core.Header{Height: height, Timestamp: uint32(time.Now().Unix())}}
+		block.Header.SideAuxPow.MainBlockHeader = mainBlockHeader
+		block.Header.SideAuxPow.MainBlockHeader.Height = mainHeight
+		return block
+	}
+
+	// not checked before the activation height
+	spv.SetVerifier(nil)
+	assert.NoError(t, CheckMainChainAnchor(newBlock(9, 100)))
+	assert.Error(t, CheckMainChainAnchor(newBlock(10, 100)))
+
+	verifier := spv.NewLocalVerifier()
+	spv.SetVerifier(verifier)
+	verifier.AddHeader(&mainBlockHeader)
+	assert.NoError(t, CheckMainChainAnchor(newBlock(10, 100)))
+
+	// the main chain block header is unknown
+	err := CheckMainChainAnchor(newBlock(10, 99))
+	assert.Error(t, err)
+	assert.NotEqual(t, ErrMainChainBlockNotSynced, err)
+
+	// the main chain block is not synced, the block is deferred
+	assert.Equal(t, ErrMainChainBlockNotSynced, CheckMainChainAnchor(newBlock(10, 101)))
+}
+
+func TestBlockchain_DeferredBlocks(t *testing.T) {
+	orphans := maxOrphanBlocks
+	maxOrphanBlocks = 2
+	defer func() {
+		maxOrphanBlocks = orphans
+	}()
+
+	bc := NewBlockchain(0)
+	blocks := make([]*core.Block, 3)
+	for i := range blocks {
+		blocks[i] = &core.Block{Header: core.Header{Height: uint32(i + 1)}}
+	}
+
+	bc.addDeferredBlock(blocks[0])
+	bc.addDeferredBlock(blocks[1])
+	bc.addDeferredBlock(blocks[1])
+	assert.Equal(t, 2, len(bc.DeferredBlocks))
+
+	// the oldest deferred block is removed when the limit is reached
+	bc.DeferredBlocks[blocks[0].Hash()].Expiration = time.Now().Add(time.Minute)
+	bc.addDeferredBlock(blocks[2])
+	assert.Equal(t, 2, len(bc.DeferredBlocks))
+	_, exists := bc.DeferredBlocks[blocks[0].Hash()]
+	assert.False(t, exists)
+
+	// expired deferred blocks are removed without processing
+	delete(bc.DeferredBlocks, blocks[2].Hash())
+	bc.DeferredBlocks[blocks[1].Hash()].Expiration = time.Now().Add(-time.Minute)
+	bc.ProcessDeferredBlocks()
+	assert.Equal(t, 0, len(bc.DeferredBlocks))
+}
@@ -291,7 +291,7 @@ func (c *ChainStore) RollbackUnspendUTXOs(b *core.Block) error {
 }
 
 func (c *ChainStore) PersistTransactions(b *core.Block) error {
-	for _, txn := range b.Transactions {
+	for i, txn := range b.Transactions {
 		if err := c.PersistTransaction(txn, b.Header.Height); err != nil {
 			return err
 		}
@@ -307,7 +307,24 @@ func (c *ChainStore) PersistTransactions(b *core.Block) error {
 			if err != nil {
 				return err
 			}
-			c.PersistMainchainTx(*hash)
+			deposit, err := NewDeposit(txn, b.Header.Height)
+			if err != nil {
+				return err
+			}
+			c.PersistMainchainTx(*hash, deposit)
+			if err := c.PersistRecharge(txn); err != nil {
+				return err
+			}
+		}
+		if txn.TxType == core.TransferCrossChainAsset {
+			if err := c.PersistWithdrawal(txn, b.Header.Height); err != nil {
+				return err
+			}
+		}
+		if txn.TxType == core.WithdrawFromSideChain {
+			if err := c.PersistWithdrawalsProcessed(txn, b.Header.Height); err != nil {
+				return err
+			}
 		}
 		if txn.TxType == core.RegisterIdentification {
 			regPayload := txn.Payload.(*core.PayloadRegisterIdentification)
@@ -317,13 +334,26 @@ func (c *ChainStore) PersistTransactions(b *core.Block) error {
 				buf.WriteString(content.Path)
 				c.PersistRegisterIdentificationTx(buf.Bytes(), txn.Hash())
 			}
+			if err := c.PersistIdentificationVersions(txn, b.Header.Height, i); err != nil {
+				return err
+			}
+		}
+		if txn.TxType == core.RevokeIdentification {
+			if err := c.PersistIdentificationRevocations(txn, b.Header.Height, i); err != nil {
+				return err
+			}
+		}
+		if txn.TxType == core.RotateIdentificationKey {
+			if err := c.PersistIdentificationKey(txn, b.Header.Height, i); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
 func (c *ChainStore) RollbackTransactions(b *core.Block) error {
-	for _, txn := range b.Transactions {
+	for i, txn := range b.Transactions {
 		if err := c.RollbackTransaction(txn); err != nil {
 			return err
 		}
@@ -339,6 +369,34 @@ func (c *ChainStore) RollbackTransactions(b *core.Block) error {
 				return err
 			}
 			c.RollbackMainchainTx(*hash)
+			if err := c.RollbackRecharge(txn); err != nil {
+				return err
+			}
+		}
+		if txn.TxType == core.TransferCrossChainAsset {
+			if err := c.RollbackWithdrawal(txn, b.Header.Height); err != nil {
+				return err
+			}
+		}
+		if txn.TxType == core.WithdrawFromSideChain {
+			if err := c.RollbackWithdrawalsProcessed(txn); err != nil {
+				return err
+			}
+		}
+		if txn.TxType == core.RegisterIdentification {
+			if err := c.RollbackIdentificationVersions(txn, b.Header.Height, i); err != nil {
+				return err
+			}
+		}
+		if txn.TxType == core.RevokeIdentification {
+			if err := c.RollbackIdentificationRevocations(txn, b.Header.Height, i); err != nil {
+				return err
+			}
+		}
+		if txn.TxType == core.RotateIdentificationKey {
+			if err := c.RollbackIdentificationKey(txn, b.Header.Height, i); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/elastos/Elastos.ELA.SideChain/config"
 	"github.com/elastos/Elastos.ELA.SideChain/core"
 	"github.com/elastos/Elastos.ELA.SideChain/events"
 	"github.com/elastos/Elastos.ELA.SideChain/log"
@@ -48,9 +49,18 @@ type ChainStore struct {
 
 	currentBlockHeight uint32
 	storedHeaderCount  uint32
+
+	// pruneDepth is the number of recent blocks with full transactions, 0
+	// disables pruning.
+	pruneDepth uint32
 }
 
 func NewChainStore() (IChainStore, error) {
+	pruneDepth := config.Parameters.PruneDepth
+	if pruneDepth > 0 && pruneDepth < MinPruneDepth {
+		return nil, errors.New("[ChainStore], prune depth is less than the minimum prune depth.")
+	}
+
 	// TODO: read config file decide which db to use.
 	st, err := NewLevelDB("Chain")
 	if err != nil {
@@ -66,6 +76,7 @@ func NewChainStore() (IChainStore, error) {
 		storedHeaderCount:  0,
 		taskCh:             make(chan persistTask, TaskChanCap),
 		quit:               make(chan chan bool, 1),
+		pruneDepth:         pruneDepth,
 	}
 
 	go store.loop()
@@ -368,12 +379,22 @@ func (c *ChainStore) GetAsset(hash Uint256) (*core.Asset, error) {
 	return asset, nil
 }
 
-func (c *ChainStore) PersistMainchainTx(mainchainTxHash Uint256) {
+// PersistMainchainTx marks the main chain transaction deposited, the deposit
+// record follows the ValueExist flag if it is not nil.
+func (c *ChainStore) PersistMainchainTx(mainchainTxHash Uint256, deposit *Deposit) {
 	key := []byte{byte(IX_MainChain_Tx)}
 	key = append(key, mainchainTxHash.Bytes()...)
 
+	value := bytes.NewBuffer([]byte{byte(ValueExist)})
+	if deposit != nil {
+		if err := deposit.Serialize(value); err != nil {
+			log.Error("[PersistMainchainTx] serialize deposit failed:", err)
+			value = bytes.NewBuffer([]byte{byte(ValueExist)})
+		}
+	}
+
 	// PUT VALUE
-	c.BatchPut(key, []byte{byte(ValueExist)})
+	c.BatchPut(key, value.Bytes())
 }
 
 func (c *ChainStore) GetMainchainTx(mainchainTxHash Uint256) (byte, error) {
@@ -545,6 +566,9 @@ func (c *ChainStore) persist(b *core.Block) error {
 	if err := c.PersistCurrentBlock(b); err != nil {
 		return err
 	}
+	if err := c.pruneBlocks(b.Header.Height); err != nil {
+		return err
+	}
 	return c.BatchCommit()
 }
 
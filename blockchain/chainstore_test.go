@@ -2,12 +2,21 @@ package blockchain
 
 import (
 	"container/list"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/elastos/Elastos.ELA.SideChain/config"
 	"github.com/elastos/Elastos.ELA.SideChain/core"
+	"github.com/elastos/Elastos.ELA.SideChain/log"
 
 	"bytes"
 	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"github.com/elastos/Elastos.ELA/bloom"
+	ela "github.com/elastos/Elastos.ELA/core"
 )
 
 var testChainStore *ChainStore
@@ -64,7 +73,7 @@ func TestChainStore_PersisMainchainTx(t *testing.T) {
 	}
 
 	// 2. Run PersistMainchainTx
-	testChainStore.PersistMainchainTx(mainchainTxHash)
+	testChainStore.PersistMainchainTx(mainchainTxHash, nil)
 
 	// Need batch commit here because PersistMainchainTx use BatchPut
 	testChainStore.BatchCommit()
@@ -178,7 +187,7 @@ func TestChainStore_IsMainchainTxHashDuplicate(t *testing.T) {
 	}
 
 	// 2. Persist the mainchain Tx hash
-	testChainStore.PersistMainchainTx(mainchainTxHash)
+	testChainStore.PersistMainchainTx(mainchainTxHash, nil)
 
 	// Need batch commit here because PersistMainchainTx use BatchPut
 	testChainStore.BatchCommit()
@@ -199,6 +208,737 @@ func TestChainStore_IsMainchainTxHashDuplicate(t *testing.T) {
 	}
 }
 
+func TestChainStore_Deposit(t *testing.T) {
+	if testChainStore == nil {
+		t.Error("Chainstore init failed")
+	}
+
+	// 1. The mainchain Tx is persisted without deposit record
+	if _, err := testChainStore.GetDeposit(mainchainTxHash); err == nil {
+		t.Error("Found the deposit which should not be recorded")
+	}
+
+	// 2. Persist the mainchain Tx with deposit record
+	recharge := &core.Transaction{
+		TxType:  core.RechargeToSideChain,
+		Payload: new(core.PayloadRechargeToSideChain),
+		Outputs: []*core.Output{{ProgramHash: common.Uint168{0x21, 1}, Value: 100}},
+	}
+	deposit, err := NewDeposit(recharge, 10)
+	if err != nil {
+		t.Error("Create deposit failed")
+	}
+	testChainStore.PersistMainchainTx(mainchainTxHash, deposit)
+	testChainStore.BatchCommit()
+
+	// 3. Verify the deposit record
+	exist, err := testChainStore.GetMainchainTx(mainchainTxHash)
+	if err != nil || exist != ValueExist {
+		t.Error("Mainchian Tx matched wrong value")
+	}
+	stored, err := testChainStore.GetDeposit(mainchainTxHash)
+	if err != nil {
+		t.Error("Not found the deposit")
+		return
+	}
+	address, _ := recharge.Outputs[0].ProgramHash.ToAddress()
+	if stored.TxHash != recharge.Hash() || stored.Height != 10 || len(stored.Outputs) != 1 ||
+		stored.Outputs[0].Address != address || stored.Outputs[0].Amount != 100 {
+		t.Error("Deposit matched wrong value")
+	}
+}
+
+func TestChainStore_IdentificationHistory(t *testing.T) {
+	if testChainStore == nil {
+		t.Error("Chainstore init failed")
+	}
+
+	log.Init(
+		config.Parameters.PrintLevel,
+		config.Parameters.MaxPerLogSize,
+		config.Parameters.MaxLogsSize,
+	)
+
+	id := "ij8rfb6A4Ri7c5CRE1nDVdVCUMuUxkk2c6"
+	newBlock := func(height uint32, dataHash common.Uint256, paths ...string) *core.Block {
+		payload := &core.PayloadRegisterIdentification{ID: id}
+		for _, path := range paths {
+			payload.Contents = append(payload.Contents, core.RegisterIdentificationContent{
+				Path:   path,
+				Values: []core.RegisterIdentificationValue{{DataHash: dataHash, Proof: "proof"}},
+			})
+		}
+		return &core.Block{
+			Header: core.Header{Height: height},
+			Transactions: []*core.Transaction{{
+				TxType:  core.RegisterIdentification,
+				Payload: payload,
+			}},
+		}
+	}
+	latest := func(path string) (*common.Uint256, error) {
+		txHash, err := testChainStore.GetRegisterIdentificationTx([]byte(id + path))
+		if err != nil {
+			return nil, err
+		}
+		return common.Uint256FromBytes(txHash)
+	}
+
+	// 1. Register two paths, and register one of them again
+	block1 := newBlock(10, common.Uint256{1}, "kyc/person/phone", "kyc/person/email")
+	block2 := newBlock(11, common.Uint256{2}, "kyc/person/phone")
+	for _, block := range []*core.Block{block1, block2} {
+		testChainStore.NewBatch()
+		if err := testChainStore.PersistTransactions(block); err != nil {
+			t.Error("Persist transactions failed")
+		}
+		testChainStore.BatchCommit()
+	}
+
+	// 2. All versions are kept
+	versions, err := testChainStore.GetIdentificationHistory(id, "kyc/person/phone")
+	if err != nil || len(versions) != 2 {
+		t.Fatal("Identification history should have 2 versions")
+	}
+	if versions[0].Height != 10 || !versions[0].TxHash.IsEqual(block1.Transactions[0].Hash()) ||
+		versions[1].Height != 11 || !versions[1].TxHash.IsEqual(block2.Transactions[0].Hash()) {
+		t.Error("Identification history matched wrong versions")
+	}
+	if len(versions[1].DataHashes) != 1 || !versions[1].DataHashes[0].IsEqual(common.Uint256{2}) {
+		t.Error("Identification version matched wrong data hashes")
+	}
+	// proofs are kept in the index for pruned registrations
+	if len(versions[1].Proofs) != 1 || versions[1].Proofs[0] != "proof" {
+		t.Error("Identification version matched wrong proofs")
+	}
+	paths, err := testChainStore.GetIdentificationPaths(id)
+	if err != nil || len(paths) != 2 || paths[0] != "kyc/person/email" || paths[1] != "kyc/person/phone" {
+		t.Error("Identification paths matched wrong value")
+	}
+	txHash, err := latest("kyc/person/phone")
+	if err != nil || !txHash.IsEqual(block2.Transactions[0].Hash()) {
+		t.Error("Latest identification should be the second registration")
+	}
+
+	// 3. Rollback the second registration, the latest goes back to the first
+	testChainStore.NewBatch()
+	testChainStore.RollbackTransactions(block2)
+	testChainStore.BatchCommit()
+	versions, _ = testChainStore.GetIdentificationHistory(id, "kyc/person/phone")
+	if len(versions) != 1 || versions[0].Height != 10 {
+		t.Error("Identification history should have 1 version after rollback")
+	}
+	txHash, err = latest("kyc/person/phone")
+	if err != nil || !txHash.IsEqual(block1.Transactions[0].Hash()) {
+		t.Error("Latest identification should be the first registration")
+	}
+
+	// 4. Rollback the first registration, nothing left
+	testChainStore.NewBatch()
+	testChainStore.RollbackTransactions(block1)
+	testChainStore.BatchCommit()
+	versions, _ = testChainStore.GetIdentificationHistory(id, "kyc/person/phone")
+	paths, _ = testChainStore.GetIdentificationPaths(id)
+	if len(versions) != 0 || len(paths) != 0 {
+		t.Error("Identification history should be empty after rollback")
+	}
+	if _, err := latest("kyc/person/email"); err == nil {
+		t.Error("Found the identification which should been deleted")
+	}
+}
+
+func TestChainStore_ResolveIdentification(t *testing.T) {
+	if testChainStore == nil {
+		t.Error("Chainstore init failed")
+	}
+
+	newKey := func() ([]byte, *common.Uint168) {
+		_, publicKey, _ := crypto.GenerateKeyPair()
+		encoded, _ := publicKey.EncodePoint(true)
+		programHash, _ := GetIdentificationProgramHash(encoded)
+		return encoded, programHash
+	}
+	_, programHash := newKey()
+	newPublicKey, newProgramHash := newKey()
+	id, _ := programHash.ToAddress()
+	register := func(dataHash common.Uint256, paths ...string) *core.Transaction {
+		payload := &core.PayloadRegisterIdentification{ID: id}
+		for _, path := range paths {
+			payload.Contents = append(payload.Contents, core.RegisterIdentificationContent{
+				Path:   path,
+				Values: []core.RegisterIdentificationValue{{DataHash: dataHash, Proof: "proof"}},
+			})
+		}
+		return &core.Transaction{TxType: core.RegisterIdentification, Payload: payload}
+	}
+
+	// register two paths at 20, register the phone again at 21, revoke the
+	// email at 22 and rotate the key at 23
+	txs := []*core.Transaction{
+		register(common.Uint256{1}, "kyc/person/email", "kyc/person/phone"),
+		register(common.Uint256{2}, "kyc/person/phone"),
+		{TxType: core.RevokeIdentification, Payload: &core.PayloadRevokeIdentification{
+			ID: id, Paths: []string{"kyc/person/email"}}},
+		{TxType: core.RotateIdentificationKey, Payload: &core.PayloadRotateIdentificationKey{
+			ID: id, PublicKey: newPublicKey}},
+	}
+	var blocks []*core.Block
+	for i, tx := range txs {
+		block := &core.Block{
+			Header:       core.Header{Height: uint32(20 + i)},
+			Transactions: []*core.Transaction{tx},
+		}
+		testChainStore.NewBatch()
+		if err := testChainStore.PersistTransactions(block); err != nil {
+			t.Fatal("Persist transactions failed:", err)
+		}
+		testChainStore.BatchCommit()
+		blocks = append(blocks, block)
+	}
+	defer func() {
+		for i := len(blocks) - 1; i >= 0; i-- {
+			testChainStore.NewBatch()
+			testChainStore.RollbackTransactions(blocks[i])
+			testChainStore.BatchCommit()
+		}
+	}()
+
+	pathHeights := func(doc *IdentificationDocument) map[string]uint32 {
+		heights := make(map[string]uint32)
+		for _, path := range doc.Paths {
+			heights[path.Path] = path.Version.Height
+		}
+		return heights
+	}
+
+	// 1. Not registered before the first registration
+	if _, err := testChainStore.ResolveIdentification(id, 19); err != ErrIdentificationNotFound {
+		t.Error("Identification should not be found before registered")
+	}
+
+	// 2. Both paths are registered by the first registration
+	doc, err := testChainStore.ResolveIdentification(id, 20)
+	if err != nil {
+		t.Fatal("Resolve identification failed:", err)
+	}
+	heights := pathHeights(doc)
+	if len(doc.Paths) != 2 || doc.Paths[0].Path != "kyc/person/email" ||
+		heights["kyc/person/email"] != 20 || heights["kyc/person/phone"] != 20 {
+		t.Error("Identification paths at 20 matched wrong value")
+	}
+	if !doc.Controller.IsEqual(*programHash) {
+		t.Error("Identification should be controlled by the ID key")
+	}
+
+	// 3. The current version of the phone is the registration again
+	doc, _ = testChainStore.ResolveIdentification(id, 21)
+	heights = pathHeights(doc)
+	if len(doc.Paths) != 2 || heights["kyc/person/phone"] != 21 ||
+		!doc.Paths[1].Version.DataHashes[0].IsEqual(common.Uint256{2}) {
+		t.Error("Identification paths at 21 matched wrong value")
+	}
+
+	// 4. The revoked email is skipped
+	doc, _ = testChainStore.ResolveIdentification(id, 22)
+	if len(doc.Paths) != 1 || doc.Paths[0].Path != "kyc/person/phone" {
+		t.Error("Revoked identification path should be skipped")
+	}
+	if !doc.Controller.IsEqual(*programHash) {
+		t.Error("Identification key should not be rotated at 22")
+	}
+
+	// 5. The rotated key controls the ID from 23
+	doc, _ = testChainStore.ResolveIdentification(id, 23)
+	if !doc.Controller.IsEqual(*newProgramHash) {
+		t.Error("Identification should be controlled by the rotated key")
+	}
+}
+
+func TestChainStore_Recharge(t *testing.T) {
+	if testChainStore == nil {
+		t.Error("Chainstore init failed")
+	}
+
+	newRecharge := func(mainchainHeight uint32, lockTime uint32) (*core.Transaction, common.Uint256) {
+		mainchainTx := &ela.Transaction{
+			TxType:   ela.TransferCrossChainAsset,
+			Payload:  new(ela.PayloadTransferCrossChainAsset),
+			LockTime: lockTime,
+		}
+		txBuf := new(bytes.Buffer)
+		mainchainTx.Serialize(txBuf)
+		proofBuf := new(bytes.Buffer)
+		proof := bloom.MerkleProof{Height: mainchainHeight}
+		proof.Serialize(proofBuf)
+		return &core.Transaction{
+			TxType: core.RechargeToSideChain,
+			Payload: &core.PayloadRechargeToSideChain{
+				MerkleProof:          proofBuf.Bytes(),
+				MainChainTransaction: txBuf.Bytes(),
+			},
+		}, mainchainTx.Hash()
+	}
+	recharge1, hash1 := newRecharge(100, 1)
+	recharge2, hash2 := newRecharge(200, 2)
+	testChainStore.PersistRecharge(recharge1)
+	testChainStore.PersistRecharge(recharge2)
+	testChainStore.BatchCommit()
+
+	// 1. Only the recharge depends on main chain blocks from the height
+	hashes, err := testChainStore.GetRecharges(150)
+	if err != nil || len(hashes) != 1 || hashes[0] != hash2 {
+		t.Error("Recharges matched wrong value")
+	}
+	hashes, _ = testChainStore.GetRecharges(100)
+	if len(hashes) != 2 {
+		t.Error("Recharges matched wrong value")
+	}
+
+	// 2. Mark the deposit reorganized
+	if testChainStore.IsDepositReorganized(hash2) {
+		t.Error("Deposit should not be reorganized")
+	}
+	if err := testChainStore.PersistDepositReorganized(hash2, 150); err != nil {
+		t.Error("Mark deposit reorganized failed")
+	}
+	if !testChainStore.IsDepositReorganized(hash2) || testChainStore.IsDepositReorganized(hash1) {
+		t.Error("Deposit reorganized matched wrong value")
+	}
+	hashes, err = testChainStore.GetReorganizedDeposits()
+	if err != nil || len(hashes) != 1 || hashes[0] != hash2 {
+		t.Error("Reorganized deposits matched wrong value")
+	}
+
+	// 3. Remove the reorganized mark when the deposit is proved again
+	if err := testChainStore.RemoveDepositReorganized(hash2); err != nil {
+		t.Error("Remove deposit reorganized failed")
+	}
+	hashes, _ = testChainStore.GetReorganizedDeposits()
+	if len(hashes) != 0 || testChainStore.IsDepositReorganized(hash2) {
+		t.Error("Reorganized mark should be removed")
+	}
+	testChainStore.PersistDepositReorganized(hash2, 150)
+
+	// 4. Rollback the recharges
+	testChainStore.RollbackRecharge(recharge1)
+	testChainStore.RollbackRecharge(recharge2)
+	testChainStore.BatchCommit()
+	hashes, _ = testChainStore.GetRecharges(0)
+	if len(hashes) != 0 || testChainStore.IsDepositReorganized(hash2) {
+		t.Error("Recharges should be removed after rollback")
+	}
+}
+
+func TestBlockchain_SumCrossChainTransaction(t *testing.T) {
+	assetID := common.Uint256{0xaa}
+	other := common.Uint256{0xbb}
+
+	// cross chain transfers of the native asset
+	bc := &Blockchain{AssetID: assetID}
+	summary := new(CrossChainSummary)
+	bc.sumCrossChainTransaction(summary, &core.Transaction{
+		TxType:  core.RechargeToSideChain,
+		Payload: new(core.PayloadRechargeToSideChain),
+		Outputs: []*core.Output{{AssetID: assetID, Value: 100}, {AssetID: other, Value: 10}},
+	})
+	bc.sumCrossChainTransaction(summary, &core.Transaction{
+		TxType: core.TransferCrossChainAsset,
+		Payload: &core.PayloadTransferCrossChainAsset{
+			CrossChainAddresses: []string{"a", "b"},
+			OutputIndexes:       []uint64{0, 1},
+			CrossChainAmounts:   []common.Fixed64{40, 20},
+		},
+		Outputs: []*core.Output{
+			{AssetID: assetID, Value: 41},
+			{AssetID: assetID, Value: 21},
+			{AssetID: assetID, ProgramHash: common.Uint168{0x21, 1}, Value: 30},
+		},
+	})
+	if summary.Recharged != 100 || summary.Destroyed != 62 ||
+		summary.Withdrawn != 60 || summary.FeesBurned != 2 {
+		t.Error("Cross chain summary matched wrong value")
+	}
+}
+
+func TestChainStore_InvalidBlock(t *testing.T) {
+	if testChainStore == nil {
+		t.Error("Chainstore init failed")
+	}
+
+	hash := common.Uint256{0x9a, 1}
+	if testChainStore.IsBlockInvalid(hash) {
+		t.Error("Block should not be invalid")
+	}
+	if err := testChainStore.PersistInvalidBlock(hash); err != nil {
+		t.Error("Mark block invalid failed")
+	}
+	if !testChainStore.IsBlockInvalid(hash) {
+		t.Error("Block should be invalid")
+	}
+	if err := testChainStore.RemoveInvalidBlock(hash); err != nil || testChainStore.IsBlockInvalid(hash) {
+		t.Error("Invalid mark should be removed")
+	}
+}
+
+func TestChainStore_VerifyTransactionIndexes(t *testing.T) {
+	if testChainStore == nil {
+		t.Error("Chainstore init failed")
+	}
+
+	assetID := common.Uint256{0xcc}
+	programHash := common.Uint168{0x21, 0xcc}
+	coinbase := NewCoinBaseTransaction(new(core.PayloadCoinBase), 1000)
+	coinbase.Outputs = []*core.Output{
+		{AssetID: assetID, Value: 10, ProgramHash: programHash},
+		{AssetID: assetID, Value: 20, ProgramHash: programHash},
+	}
+	block := &core.Block{
+		Header:       core.Header{Height: 1000},
+		Transactions: []*core.Transaction{coinbase},
+	}
+	testChainStore.NewBatch()
+	testChainStore.PersistTransactions(block)
+	testChainStore.PersistUnspendUTXOs(block)
+	testChainStore.PersistUnspend(block)
+	testChainStore.BatchCommit()
+
+	// 1. The indexes match the transaction at the block height
+	if err := testChainStore.verifyTransactionIndexes(coinbase, 1000); err != nil {
+		t.Error("Transaction indexes should be consistent:", err)
+	}
+	if err := testChainStore.verifyTransactionIndexes(coinbase, 1001); err == nil {
+		t.Error("Transaction should not be stored at another height")
+	}
+
+	// 2. Remove the unspent outputs from the UTXO index
+	testChainStore.NewBatch()
+	testChainStore.PersistUnspentWithProgramHash(programHash, assetID, 1000, nil)
+	testChainStore.BatchCommit()
+	if err := testChainStore.verifyTransactionIndexes(coinbase, 1000); err == nil {
+		t.Error("Missing UTXO index should be detected")
+	}
+}
+
+func TestChainStore_Prune(t *testing.T) {
+	if testChainStore == nil {
+		t.Error("Chainstore init failed")
+	}
+
+	assetID := common.Uint256{0xdd}
+	programHash := common.Uint168{0x21, 0xdd}
+	newBlock := func(height uint32, txs ...*core.Transaction) *core.Block {
+		coinbase := NewCoinBaseTransaction(new(core.PayloadCoinBase), height)
+		coinbase.Outputs = []*core.Output{
+			{AssetID: assetID, Value: 10, ProgramHash: programHash},
+			{AssetID: assetID, Value: 20, ProgramHash: programHash},
+		}
+		block := &core.Block{
+			Header:       core.Header{Height: height},
+			Transactions: append([]*core.Transaction{coinbase}, txs...),
+		}
+		block.Header.SideAuxPow.SideAuxBlockTx.Payload = new(ela.PayloadCoinBase)
+		return block
+	}
+	newSpend := func(txID common.Uint256, index uint16) *core.Transaction {
+		return &core.Transaction{
+			TxType:  core.TransferAsset,
+			Payload: new(core.PayloadTransferAsset),
+			Inputs:  []*core.Input{{Previous: core.OutPoint{TxID: txID, Index: index}}},
+			Outputs: []*core.Output{{AssetID: assetID, Value: 10, ProgramHash: programHash}},
+		}
+	}
+
+	// the outputs of the coinbase at 2000 are spent at 2001 and 2002
+	block0 := newBlock(2000)
+	coinbaseHash := block0.Transactions[0].Hash()
+	blocks := []*core.Block{
+		block0,
+		newBlock(2001, newSpend(coinbaseHash, 0)),
+		newBlock(2002, newSpend(coinbaseHash, 1)),
+	}
+	testChainStore.NewBatch()
+	for _, block := range blocks {
+		testChainStore.PersistTrimmedBlock(block)
+		testChainStore.PersistBlockHash(block)
+		testChainStore.PersistTransactions(block)
+	}
+	testChainStore.BatchCommit()
+
+	// 1. The transaction is kept while one of its outputs is not spent by
+	// pruned blocks
+	testChainStore.NewBatch()
+	if err := testChainStore.pruneBlock(2001, make(map[common.Uint256]uint16)); err != nil {
+		t.Error("Prune block failed:", err)
+	}
+	testChainStore.BatchCommit()
+	if _, _, err := testChainStore.GetTransaction(coinbaseHash); err != nil {
+		t.Error("Transaction with outputs not spent by pruned blocks should be kept")
+	}
+	if height, ok := testChainStore.GetPruneHeight(); !ok || height != 2001 {
+		t.Error("Prune height should be 2001")
+	}
+
+	// 2. The transaction is removed once all of its outputs are spent
+	testChainStore.NewBatch()
+	if err := testChainStore.pruneBlock(2002, make(map[common.Uint256]uint16)); err != nil {
+		t.Error("Prune block failed:", err)
+	}
+	testChainStore.BatchCommit()
+	if _, _, err := testChainStore.GetTransaction(coinbaseHash); err == nil {
+		t.Error("Transaction spent by pruned blocks should be removed")
+	}
+	if _, err := testChainStore.Get(getPruneSpentKey(coinbaseHash)); err == nil {
+		t.Error("Spent count of removed transaction should be removed")
+	}
+	if !testChainStore.IsBlockPruned(blocks[1].Hash()) || testChainStore.IsBlockPruned(common.Uint256{0xdd}) {
+		t.Error("Block pruned state is wrong")
+	}
+
+	testChainStore.Delete([]byte{byte(SYS_PruneHeight)})
+	if _, ok := testChainStore.GetPruneHeight(); ok {
+		t.Error("Prune height should be removed")
+	}
+}
+
+func TestChainStore_ReadOnlyView(t *testing.T) {
+	if testChainStore == nil {
+		t.Error("Chainstore init failed")
+	}
+
+	block := &core.Block{Header: core.Header{Height: 0}}
+	testChainStore.NewBatch()
+	testChainStore.PersistCurrentBlock(block)
+	testChainStore.BatchCommit()
+
+	// 1. The view reads the store at the time it's taken
+	view, err := testChainStore.newReadOnlyView()
+	if err != nil {
+		t.Error("Create read only view failed:", err)
+		return
+	}
+	defer view.IStore.Close()
+	reindexKey := []byte{byte(SYS_Reindexing)}
+	testChainStore.Put(reindexKey, []byte{1})
+	defer testChainStore.Delete(reindexKey)
+	if !testChainStore.IsReindexing() || view.IsReindexing() {
+		t.Error("View should not see the changes after it's taken")
+	}
+	if view.GetHeight() != 0 {
+		t.Error("View height should be the current block height")
+	}
+
+	// 2. The view can not be written
+	if err := view.Put(reindexKey, []byte{1}); err == nil {
+		t.Error("View should be read only")
+	}
+}
+
+func TestChainStore_Snapshot(t *testing.T) {
+	if testChainStore == nil {
+		t.Error("Chainstore init failed")
+	}
+
+	assetID := common.Uint256{0xee}
+	coinbase := NewCoinBaseTransaction(new(core.PayloadCoinBase), 0)
+	coinbase.Outputs = []*core.Output{
+		{AssetID: assetID, Value: 10, ProgramHash: common.Uint168{0x21, 0xee}},
+	}
+	block := &core.Block{Transactions: []*core.Transaction{coinbase}}
+	block.Header.SideAuxPow.SideAuxBlockTx.Payload = new(ela.PayloadCoinBase)
+	testChainStore.NewBatch()
+	testChainStore.PersistTrimmedBlock(block)
+	testChainStore.PersistBlockHash(block)
+	testChainStore.PersistTransactions(block)
+	testChainStore.PersistUnspend(block)
+	testChainStore.PersistCurrentBlock(block)
+	testChainStore.BatchCommit()
+
+	// 1. Export the snapshot below the best block
+	coinbase1 := NewCoinBaseTransaction(new(core.PayloadCoinBase), 1)
+	coinbase1.Outputs = []*core.Output{
+		{AssetID: assetID, Value: 10, ProgramHash: common.Uint168{0x21, 0xee}},
+	}
+	block1 := &core.Block{
+		Header:       core.Header{Height: 1, Previous: block.Hash()},
+		Transactions: []*core.Transaction{coinbase1},
+	}
+	block1.Header.SideAuxPow.SideAuxBlockTx.Payload = new(ela.PayloadCoinBase)
+	if err := testChainStore.persist(block1); err != nil {
+		t.Fatal("Persist block failed:", err)
+	}
+	testChainStore.currentBlockHeight = 1
+	defer testChainStore.rollbackBlock(block1)
+
+	buf := new(bytes.Buffer)
+	if err := testChainStore.ExportSnapshot(buf, 0); err != nil {
+		t.Error("Export snapshot failed:", err)
+	}
+	data := buf.Bytes()
+	if err := testChainStore.ExportSnapshot(new(bytes.Buffer), 2); err == nil {
+		t.Error("Snapshot above the best block should not be exported")
+	}
+	if _, err := testChainStore.GetBlockHash(1); err != nil {
+		t.Error("Export snapshot should not change the store")
+	}
+
+	newStore := func(dir string) *ChainStore {
+		st, err := NewLevelDB(dir)
+		if err != nil {
+			t.Fatal("Create leveldb failed:", err)
+		}
+		return &ChainStore{IStore: st}
+	}
+
+	// 2. Import the snapshot into a fresh chain store
+	dir, _ := ioutil.TempDir("", "snapshot")
+	defer os.RemoveAll(dir)
+	store := newStore(filepath.Join(dir, "import"))
+	defer store.IStore.Close()
+	if height, err := store.ImportSnapshot(bytes.NewReader(data)); err != nil || height != 0 {
+		t.Error("Import snapshot failed:", err)
+	}
+	if hash, err := store.GetBlockHash(0); err != nil || !hash.IsEqual(block.Hash()) {
+		t.Error("Imported block hash is wrong")
+	}
+	if unspent, _ := store.ContainsUnspent(coinbase.Hash(), 0); !unspent {
+		t.Error("Imported unspent output not found")
+	}
+	if _, _, err := store.GetTransaction(coinbase.Hash()); err != nil {
+		t.Error("Imported transaction not found")
+	}
+	if pruneHeight, ok := store.GetPruneHeight(); !ok || pruneHeight != 0 {
+		t.Error("Imported blocks should be pruned")
+	}
+	if _, err := store.GetBlockHash(1); err == nil {
+		t.Error("Blocks above the snapshot height should not be imported")
+	}
+	if unspent, _ := store.ContainsUnspent(coinbase1.Hash(), 0); unspent {
+		t.Error("Outputs above the snapshot height should not be imported")
+	}
+	if _, err := store.Get([]byte{byte(SYS_WithdrawalIndex)}); err != nil {
+		t.Error("Imported withdrawal index should be marked as built")
+	}
+	if height, err := store.ImportSnapshot(bytes.NewReader(data)); err != nil || height != 0 {
+		t.Error("Imported snapshot should be skipped:", err)
+	}
+	used := newStore(filepath.Join(dir, "used"))
+	defer used.IStore.Close()
+	used.Put([]byte{byte(CFG_Version)}, []byte{0x01})
+	if _, err := used.ImportSnapshot(bytes.NewReader(data)); err != ErrChainStoreNotEmpty {
+		t.Error("Snapshot should not be imported into a used chain store")
+	}
+
+	// 3. A modified snapshot is rejected
+	modified := append([]byte{}, data...)
+	modified[len(modified)-sha256.Size-2] ^= 0xff
+	store = newStore(filepath.Join(dir, "modified"))
+	defer store.IStore.Close()
+	if _, err := store.ImportSnapshot(bytes.NewReader(modified)); err == nil {
+		t.Error("Modified snapshot should be rejected")
+	}
+	if _, err := store.Get([]byte{byte(CFG_Version)}); err == nil {
+		t.Error("Rejected snapshot should not be marked as imported")
+	}
+}
+
+func TestChainStore_TxOutSetInfo(t *testing.T) {
+	if testChainStore == nil {
+		t.Error("Chainstore init failed")
+	}
+
+	before, err := testChainStore.GetTxOutSetInfo()
+	if err != nil {
+		t.Error("Get tx out set info failed:", err)
+		return
+	}
+
+	// 1. Outputs to the destroy address are counted but not in the supply
+	assetID := common.Uint256{0xff}
+	coinbase := NewCoinBaseTransaction(new(core.PayloadCoinBase), 3000)
+	coinbase.Outputs = []*core.Output{
+		{AssetID: assetID, Value: 10, ProgramHash: common.Uint168{0x21, 0xff}},
+		{AssetID: assetID, Value: 20, ProgramHash: common.Uint168{0x21, 0xff}},
+		{AssetID: assetID, Value: 40, ProgramHash: common.Uint168{}},
+	}
+	block := &core.Block{
+		Header:       core.Header{Height: 3000},
+		Transactions: []*core.Transaction{coinbase},
+	}
+	testChainStore.NewBatch()
+	testChainStore.PersistTransactions(block)
+	testChainStore.PersistUnspend(block)
+	testChainStore.BatchCommit()
+
+	after, err := testChainStore.GetTxOutSetInfo()
+	if err != nil {
+		t.Error("Get tx out set info failed:", err)
+		return
+	}
+	if after.Transactions != before.Transactions+1 || after.TxOuts != before.TxOuts+3 {
+		t.Error("Unspent output counts are wrong")
+	}
+	if after.Supply[assetID] != 30 {
+		t.Error("Asset supply should be 30")
+	}
+	if after.SetHash.IsEqual(before.SetHash) {
+		t.Error("Set hash should change with the unspent outputs")
+	}
+
+	// 2. The set hash is deterministic
+	again, _ := testChainStore.GetTxOutSetInfo()
+	if !again.SetHash.IsEqual(after.SetHash) {
+		t.Error("Set hash should be deterministic")
+	}
+}
+
+func TestBlockchain_ReorganizeBelowPruneHeight(t *testing.T) {
+	if testChainStore == nil {
+		t.Error("Chainstore init failed")
+	}
+
+	ledger := DefaultLedger
+	defer func() { DefaultLedger = ledger }()
+
+	// main chain 0-1-2-3 pruned to height 1, fork 1-4
+	bc := &Blockchain{Index: make(map[common.Uint256]*BlockNode), BlockCache: make(map[common.Uint256]*core.Block)}
+	DefaultLedger = &Ledger{Blockchain: bc, Store: testChainStore}
+	var nodes []*BlockNode
+	for i := byte(0); i < 4; i++ {
+		node := &BlockNode{Hash: &common.Uint256{0xf0, i}, Height: uint32(i), InMainChain: true}
+		if i > 0 {
+			node.Parent = nodes[i-1]
+		}
+		bc.Index[*node.Hash] = node
+		nodes = append(nodes, node)
+	}
+	bc.GenesisHash = *nodes[0].Hash
+	bc.BestChain = nodes[3]
+	fork := &BlockNode{Hash: &common.Uint256{0xf0, 4}, Height: 2, Parent: nodes[1]}
+	bc.BlockCache[*fork.Hash] = new(core.Block)
+	testChainStore.Put([]byte{byte(SYS_PruneHeight)}, []byte{0, 0, 0, 1})
+	defer testChainStore.Delete([]byte{byte(SYS_PruneHeight)})
+
+	// 1. The blocks on top of the pruned blocks are not reorganized
+	detachNodes, attachNodes := list.New(), list.New()
+	detachNodes.PushBack(nodes[3])
+	detachNodes.PushBack(nodes[2])
+	attachNodes.PushBack(fork)
+	if err := bc.ReorganizeChain(detachNodes, attachNodes); err != ErrForkPruned {
+		t.Error("Reorganize below prune height should fail")
+	}
+	if bc.BestChain != nodes[3] || !nodes[2].InMainChain {
+		t.Error("Best chain should not change")
+	}
+
+	// 2. The blocks on top of the pruned blocks are not invalidated
+	if err := bc.InvalidateBlock(*nodes[2].Hash); err != ErrForkPruned {
+		t.Error("Invalidate block on top of pruned blocks should fail")
+	}
+	if bc.BestChain != nodes[3] || nodes[2].Status&StatusInvalid != 0 {
+		t.Error("Best chain should not change")
+	}
+}
+
 func TestChainStoreDone(t *testing.T) {
 	if testChainStore == nil {
 		t.Error("Chainstore init failed")
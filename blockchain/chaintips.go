@@ -0,0 +1,241 @@
+package blockchain
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/elastos/Elastos.ELA.SideChain/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// BlockStatus is the validation status of a block node.
+type BlockStatus byte
+
+const (
+	// StatusValid is set once the block has been connected to the main chain.
+	StatusValid BlockStatus = 1 << iota
+
+	// StatusInvalid is set on a block invalidated by InvalidateBlock.
+	StatusInvalid
+)
+
+// Chain tip status
+const (
+	TipActive       = "active"
+	TipValidFork    = "valid-fork"
+	TipValidHeaders = "valid-headers"
+	TipInvalid      = "invalid"
+)
+
+// ChainTip is the end of the main chain or a side chain in the block index.
+type ChainTip struct {
+	Height uint32
+	Hash   Uint256
+	// BranchLen is the length of the branch from the fork point with the
+	// main chain, it's 0 for the main chain.
+	BranchLen uint32
+	Status    string
+}
+
+func getInvalidBlockKey(hash Uint256) []byte {
+	return append([]byte{byte(IX_Invalid_Block)}, hash.Bytes()...)
+}
+
+// PersistInvalidBlock marks the block invalid, it's written out of the block
+// batch, so the mark is kept while the block is disconnected.
+func (c *ChainStore) PersistInvalidBlock(hash Uint256) error {
+	return c.Put(getInvalidBlockKey(hash), []byte{byte(ValueExist)})
+}
+
+func (c *ChainStore) RemoveInvalidBlock(hash Uint256) error {
+	return c.Delete(getInvalidBlockKey(hash))
+}
+
+func (c *ChainStore) IsBlockInvalid(hash Uint256) bool {
+	_, err := c.Get(getInvalidBlockKey(hash))
+	return err == nil
+}
+
+// isInvalidChain returns if the node or one of its side chain ancestors is
+// invalid.
+func isInvalidChain(node *BlockNode) bool {
+	for n := node; n != nil && !n.InMainChain; n = n.Parent {
+		if n.Status&StatusInvalid != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GetChainTips returns the tips of the main chain and the side chains in the
+// block index ordered by height.
+func (bc *Blockchain) GetChainTips() []*ChainTip {
+	bc.mutex.RLock()
+	defer bc.mutex.RUnlock()
+
+	var tips []*ChainTip
+	bc.IndexLock.RLock()
+	for _, node := range bc.Index {
+		if len(node.Children) > 0 || node == bc.BestChain {
+			continue
+		}
+		tips = append(tips, getChainTip(node))
+	}
+	bc.IndexLock.RUnlock()
+	if bc.BestChain != nil {
+		tips = append(tips, &ChainTip{
+			Height: bc.BestChain.Height,
+			Hash:   *bc.BestChain.Hash,
+			Status: TipActive,
+		})
+	}
+
+	sort.Slice(tips, func(i, j int) bool {
+		return tips[i].Height > tips[j].Height
+	})
+	return tips
+}
+
+func getChainTip(node *BlockNode) *ChainTip {
+	tip := &ChainTip{Height: node.Height, Hash: *node.Hash, Status: TipValidFork}
+	for n := node; n != nil && !n.InMainChain; n = n.Parent {
+		tip.BranchLen++
+		if n.Status&StatusInvalid != 0 {
+			tip.Status = TipInvalid
+		} else if n.Status&StatusValid == 0 && tip.Status == TipValidFork {
+			tip.Status = TipValidHeaders
+		}
+	}
+	return tip
+}
+
+// InvalidateBlock marks the block invalid, disconnects it and its descendants
+// from the main chain, and activates the valid chain with the most work.
+func (bc *Blockchain) InvalidateBlock(hash Uint256) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	node, err := bc.lookupNode(hash)
+	if err != nil {
+		return err
+	}
+	if node.Hash.IsEqual(bc.GenesisHash) {
+		return errors.New("[InvalidateBlock], genesis block can not be invalidated.")
+	}
+	if pruneHeight, ok := DefaultLedger.Store.GetPruneHeight(); ok && node.InMainChain && node.Height-1 <= pruneHeight {
+		return ErrForkPruned
+	}
+
+	for node.InMainChain && bc.BestChain != nil && bc.BestChain.Height >= node.Height {
+		block, err := DefaultLedger.Store.GetBlock(*bc.BestChain.Hash)
+		if err != nil {
+			return err
+		}
+		if err := bc.DisconnectBlock(bc.BestChain, block); err != nil {
+			return err
+		}
+	}
+
+	if err := DefaultLedger.Store.PersistInvalidBlock(hash); err != nil {
+		return err
+	}
+	node.Status |= StatusInvalid
+	log.Infof("[InvalidateBlock] block %x at height %d is invalidated", hash.Bytes(), node.Height)
+
+	return bc.activateBestChain()
+}
+
+// ReconsiderBlock removes the invalid marks of the block, its ancestors and
+// its descendants, and activates the valid chain with the most work.
+func (bc *Blockchain) ReconsiderBlock(hash Uint256) error {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+
+	node, ok := bc.LookupNodeInIndex(&hash)
+	if !ok {
+		// the block was forgotten after restart, it can be received again
+		// once the mark is removed.
+		if !DefaultLedger.Store.IsBlockInvalid(hash) {
+			return errors.New("[ReconsiderBlock], block not found.")
+		}
+		return DefaultLedger.Store.RemoveInvalidBlock(hash)
+	}
+
+	for n := node; n != nil && !n.InMainChain; n = n.Parent {
+		if err := bc.reconsiderNode(n); err != nil {
+			return err
+		}
+	}
+	nodes := append([]*BlockNode{}, node.Children...)
+	for len(nodes) > 0 {
+		n := nodes[0]
+		nodes = append(nodes[1:], n.Children...)
+		if err := bc.reconsiderNode(n); err != nil {
+			return err
+		}
+	}
+
+	return bc.activateBestChain()
+}
+
+func (bc *Blockchain) reconsiderNode(node *BlockNode) error {
+	if node.Status&StatusInvalid == 0 {
+		return nil
+	}
+	if err := DefaultLedger.Store.RemoveInvalidBlock(*node.Hash); err != nil {
+		return err
+	}
+	node.Status &^= StatusInvalid
+	return nil
+}
+
+// lookupNode returns the block node from the block index, or loads it when
+// it's a main chain block not in memory.
+func (bc *Blockchain) lookupNode(hash Uint256) (*BlockNode, error) {
+	if node, ok := bc.LookupNodeInIndex(&hash); ok {
+		return node, nil
+	}
+
+	header, err := bc.GetHeader(hash)
+	if err != nil {
+		return nil, errors.New("[Blockchain], block not found.")
+	}
+	node := bc.BestChain
+	for node != nil && node.Height > header.Height {
+		if node, err = bc.GetPrevNodeFromNode(node); err != nil {
+			return nil, err
+		}
+	}
+	if node == nil || !node.Hash.IsEqual(hash) {
+		return nil, errors.New("[Blockchain], block not in main chain.")
+	}
+	return node, nil
+}
+
+// activateBestChain reorganizes the chain to the side chain block with the
+// most work, when it's valid and has more work than the best chain.
+func (bc *Blockchain) activateBestChain() error {
+	var best *BlockNode
+	bc.IndexLock.RLock()
+	for _, node := range bc.Index {
+		if node.InMainChain || isInvalidChain(node) {
+			continue
+		}
+		if _, ok := bc.BlockCache[*node.Hash]; !ok {
+			continue
+		}
+		if best == nil || node.WorkSum.Cmp(best.WorkSum) > 0 {
+			best = node
+		}
+	}
+	bc.IndexLock.RUnlock()
+
+	if best == nil || (bc.BestChain != nil && best.WorkSum.Cmp(bc.BestChain.WorkSum) <= 0) {
+		return nil
+	}
+
+	log.Infof("[ActivateBestChain] reorganize to block %x at height %d", best.Hash.Bytes(), best.Height)
+	detachNodes, attachNodes := bc.GetReorganizeNodes(best)
+	return bc.ReorganizeChain(detachNodes, attachNodes)
+}
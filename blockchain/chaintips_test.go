@@ -0,0 +1,54 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetChainTips(t *testing.T) {
+	bc := &Blockchain{Index: make(map[common.Uint256]*BlockNode)}
+	newNode := func(parent *BlockNode, id byte, status BlockStatus) *BlockNode {
+		node := &BlockNode{Hash: &common.Uint256{id}, WorkSum: big.NewInt(1), Status: status, Parent: parent}
+		if parent != nil {
+			node.Height = parent.Height + 1
+			parent.Children = append(parent.Children, node)
+		}
+		bc.Index[*node.Hash] = node
+		return node
+	}
+
+	// main chain 1-2-3, fork 2-4-5 connected before, fork 3-6 not connected,
+	// and fork 1-7-8 invalidated at 7
+	n1 := newNode(nil, 1, StatusValid)
+	n2 := newNode(n1, 2, StatusValid)
+	n3 := newNode(n2, 3, StatusValid)
+	n1.InMainChain, n2.InMainChain, n3.InMainChain = true, true, true
+	bc.BestChain = n3
+	newNode(newNode(n2, 4, StatusValid), 5, StatusValid)
+	newNode(n3, 6, 0)
+	n7 := newNode(n1, 7, StatusValid|StatusInvalid)
+	n8 := newNode(n7, 8, StatusValid)
+
+	tips := bc.GetChainTips()
+	assert.Equal(t, 4, len(tips))
+	status := make(map[common.Uint256]*ChainTip)
+	for _, tip := range tips {
+		status[tip.Hash] = tip
+	}
+	assert.Equal(t, TipActive, status[common.Uint256{3}].Status)
+	assert.Equal(t, uint32(0), status[common.Uint256{3}].BranchLen)
+	assert.Equal(t, TipValidFork, status[common.Uint256{5}].Status)
+	assert.Equal(t, uint32(2), status[common.Uint256{5}].BranchLen)
+	assert.Equal(t, TipValidHeaders, status[common.Uint256{6}].Status)
+	assert.Equal(t, uint32(1), status[common.Uint256{6}].BranchLen)
+	assert.Equal(t, TipInvalid, status[common.Uint256{8}].Status)
+	assert.Equal(t, uint32(2), status[common.Uint256{8}].BranchLen)
+	assert.Equal(t, uint32(3), tips[0].Height)
+
+	// descendants of invalid blocks are invalid
+	assert.True(t, isInvalidChain(n8))
+	assert.False(t, isInvalidChain(n3))
+}
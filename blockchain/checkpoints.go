@@ -0,0 +1,116 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA.SideChain/config"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+func checkpointHash(checkpoint *config.Checkpoint) (*Uint256, error) {
+	hashBytes, err := HexStringToBytes(checkpoint.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return Uint256FromBytes(BytesReverse(hashBytes))
+}
+
+// latestCheckpoint returns the checkpoint with the greatest height not higher
+// than height, or nil if there is no such checkpoint. The assume valid block
+// is a checkpoint too.
+func latestCheckpoint(params *config.ChainParams, height uint32) *config.Checkpoint {
+	var latest *config.Checkpoint
+	for i := range params.Checkpoints {
+		if params.Checkpoints[i].Height > height {
+			break
+		}
+		latest = &params.Checkpoints[i]
+	}
+	assumeValid := &params.AssumeValid
+	if assumeValid.Hash != "" && assumeValid.Height <= height &&
+		(latest == nil || assumeValid.Height > latest.Height) {
+		latest = assumeValid
+	}
+	return latest
+}
+
+// checkCheckpoints checks the block at height matches the checkpoints and
+// the assume valid block at the height, and the block does not fork the best
+// chain at bestHeight below it's latest checkpoint.
+func checkCheckpoints(params *config.ChainParams, height uint32, hash Uint256, bestHeight uint32) error {
+	for i := range params.Checkpoints {
+		if err := matchCheckpoint(&params.Checkpoints[i], height, hash); err != nil {
+			return err
+		}
+	}
+	if params.AssumeValid.Hash != "" {
+		if err := matchCheckpoint(&params.AssumeValid, height, hash); err != nil {
+			return err
+		}
+	}
+
+	latest := latestCheckpoint(params, bestHeight)
+	if latest != nil && height < latest.Height {
+		return errors.New("[Checkpoint], block forks the chain before the latest checkpoint.")
+	}
+	return nil
+}
+
+func matchCheckpoint(checkpoint *config.Checkpoint, height uint32, hash Uint256) error {
+	if checkpoint.Height != height {
+		return nil
+	}
+	expected, err := checkpointHash(checkpoint)
+	if err != nil {
+		return err
+	}
+	if !expected.IsEqual(hash) {
+		return fmt.Errorf("[Checkpoint], block at height %d does not match checkpoint.", height)
+	}
+	return nil
+}
+
+// isAssumeValidBlock returns if the hash is the assume valid block at height.
+func isAssumeValidBlock(params *config.ChainParams, height uint32, hash Uint256) bool {
+	if params.AssumeValid.Hash == "" || params.AssumeValid.Height != height {
+		return false
+	}
+	expected, err := checkpointHash(&params.AssumeValid)
+	return err == nil && expected.IsEqual(hash)
+}
+
+// AddAssumedValidHeaders marks the headers leading to the assume valid block,
+// headerNode is a header checked by headers-first sync and its ancestors are
+// linked by Parent. Only the blocks of the marked headers are connected without
+// verifying their signatures.
+func (bc *Blockchain) AddAssumedValidHeaders(headerNode *BlockNode) {
+	if !isAssumeValidBlock(config.Parameters.ChainParam, headerNode.Height, *headerNode.Hash) {
+		return
+	}
+
+	bc.assumedValidLock.Lock()
+	defer bc.assumedValidLock.Unlock()
+	if bc.assumedValid == nil {
+		bc.assumedValid = make(map[Uint256]struct{})
+	}
+	for node := headerNode; node != nil; node = node.Parent {
+		// the blocks in the index are connected already
+		if _, ok := bc.LookupNodeInIndex(node.Hash); ok {
+			break
+		}
+		bc.assumedValid[*node.Hash] = struct{}{}
+	}
+}
+
+// isAssumedValid returns if the signatures of the block are not verified, the
+// block is on the header chain leading to the assume valid block. The mark is
+// removed, the block is verified if it's connected again.
+func (bc *Blockchain) isAssumedValid(hash Uint256) bool {
+	bc.assumedValidLock.Lock()
+	defer bc.assumedValidLock.Unlock()
+	_, ok := bc.assumedValid[hash]
+	delete(bc.assumedValid, hash)
+	return ok
+}
@@ -0,0 +1,93 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SideChain/config"
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCheckpoints(t *testing.T) {
+	hash10 := common.Uint256{10}
+	hash20 := common.Uint256{20}
+	hash30 := common.Uint256{30}
+	params := &config.ChainParams{
+		Checkpoints: []config.Checkpoint{
+			{Height: 10, Hash: common.BytesToHexString(common.BytesReverse(hash10.Bytes()))},
+			{Height: 20, Hash: common.BytesToHexString(common.BytesReverse(hash20.Bytes()))},
+		},
+	}
+
+	// blocks at checkpoint heights must match
+	assert.NoError(t, checkCheckpoints(params, 10, hash10, 9))
+	assert.Error(t, checkCheckpoints(params, 10, hash20, 9))
+	assert.NoError(t, checkCheckpoints(params, 11, hash20, 10))
+
+	// forks before the latest checkpoint are rejected
+	assert.NoError(t, checkCheckpoints(params, 15, common.Uint256{}, 19))
+	assert.Error(t, checkCheckpoints(params, 15, common.Uint256{}, 25))
+	assert.NoError(t, checkCheckpoints(params, 21, common.Uint256{}, 25))
+	assert.Nil(t, latestCheckpoint(params, 9))
+	assert.Equal(t, uint32(20), latestCheckpoint(params, 25).Height)
+
+	// the assume valid block is a checkpoint
+	assert.False(t, isAssumeValidBlock(params, 30, hash30))
+	params.AssumeValid = config.Checkpoint{Height: 30, Hash: common.BytesToHexString(common.BytesReverse(hash30.Bytes()))}
+	assert.True(t, isAssumeValidBlock(params, 30, hash30))
+	assert.False(t, isAssumeValidBlock(params, 30, hash20))
+	assert.Error(t, checkCheckpoints(params, 30, hash20, 29))
+	assert.NoError(t, checkCheckpoints(params, 30, hash30, 29))
+	assert.Error(t, checkCheckpoints(params, 25, common.Uint256{}, 30))
+	assert.Equal(t, uint32(30), latestCheckpoint(params, 35).Height)
+}
+
+func TestBlockchain_AssumedValidHeaders(t *testing.T) {
+	chainParam := config.Parameters.ChainParam
+	defer func() {
+		config.Parameters.ChainParam = chainParam
+	}()
+
+	bc := NewBlockchain(0)
+	root := NewBlockNode(&core.Header{Height: 0}, &common.Uint256{})
+	bc.Index[*root.Hash] = root
+
+	// a header chain and a fork of it from the root
+	newChain := func(n int, nonce uint32) []*BlockNode {
+		nodes := make([]*BlockNode, n)
+		prev := root
+		for i := range nodes {
+			header := &core.Header{Height: uint32(i + 1), Nonce: nonce}
+			hash := header.Hash()
+			nodes[i] = NewBlockNode(header, &hash)
+			nodes[i].Parent = prev
+			prev = nodes[i]
+		}
+		return nodes
+	}
+	chain := newChain(3, 1)
+	fork := newChain(3, 2)
+	config.Parameters.ChainParam = &config.ChainParams{
+		AssumeValid: config.Checkpoint{Height: 2,
+			Hash: common.BytesToHexString(common.BytesReverse(chain[1].Hash.Bytes()))},
+	}
+
+	// headers not leading to the assume valid block are not marked
+	bc.AddAssumedValidHeaders(chain[0])
+	bc.AddAssumedValidHeaders(fork[1])
+	bc.AddAssumedValidHeaders(chain[2])
+	assert.False(t, bc.isAssumedValid(*chain[0].Hash))
+
+	bc.AddAssumedValidHeaders(chain[1])
+	assert.False(t, bc.isAssumedValid(*root.Hash))
+	assert.False(t, bc.isAssumedValid(*fork[0].Hash))
+	assert.False(t, bc.isAssumedValid(*fork[1].Hash))
+	assert.False(t, bc.isAssumedValid(*chain[2].Hash))
+	assert.True(t, bc.isAssumedValid(*chain[0].Hash))
+	assert.True(t, bc.isAssumedValid(*chain[1].Hash))
+
+	// the mark is removed once the block is connected
+	assert.False(t, bc.isAssumedValid(*chain[1].Hash))
+}
@@ -0,0 +1,86 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// MaxCrossChainSummaryBlocks is the max number of blocks walked by
+// GetCrossChainSummary.
+const MaxCrossChainSummaryBlocks = 10000
+
+// CrossChainSummary is the cross chain transfers of the native asset in a
+// height range, it is used to reconcile the side chain supply with deposits
+// and withdrawals on main chain.
+type CrossChainSummary struct {
+	StartHeight uint32
+	EndHeight   uint32
+
+	// Recharged is the amount credited by RechargeToSideChain transactions.
+	Recharged Fixed64
+	// Destroyed is the value of outputs sent to the destroy address by
+	// TransferCrossChainAsset transactions, it is Withdrawn plus FeesBurned.
+	Destroyed  Fixed64
+	Withdrawn  Fixed64
+	FeesBurned Fixed64
+}
+
+// GetCrossChainSummary walks the blocks from start to end height and sums up
+// the cross chain transfers of the native asset, at most
+// MaxCrossChainSummaryBlocks blocks are walked and none of them is pruned.
+func (bc *Blockchain) GetCrossChainSummary(start, end uint32) (*CrossChainSummary, error) {
+	if start > end || end > DefaultLedger.Store.GetHeight() {
+		return nil, errors.New("[CrossChainSummary], invalid height range.")
+	}
+	if end-start >= MaxCrossChainSummaryBlocks {
+		return nil, fmt.Errorf("[CrossChainSummary], height range is larger than %d blocks.", MaxCrossChainSummaryBlocks)
+	}
+	if pruneHeight, ok := DefaultLedger.Store.GetPruneHeight(); ok && start <= pruneHeight {
+		return nil, fmt.Errorf("[CrossChainSummary], blocks to height %d are pruned.", pruneHeight)
+	}
+
+	summary := &CrossChainSummary{StartHeight: start, EndHeight: end}
+	for height := start; height <= end; height++ {
+		hash, err := DefaultLedger.Store.GetBlockHash(height)
+		if err != nil {
+			return nil, err
+		}
+		block, err := DefaultLedger.Store.GetBlock(hash)
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range block.Transactions {
+			bc.sumCrossChainTransaction(summary, tx)
+		}
+	}
+
+	return summary, nil
+}
+
+func (bc *Blockchain) sumCrossChainTransaction(summary *CrossChainSummary, tx *core.Transaction) {
+	switch payload := tx.Payload.(type) {
+	case *core.PayloadRechargeToSideChain:
+		for _, output := range tx.Outputs {
+			if output.AssetID == bc.AssetID {
+				summary.Recharged += output.Value
+			}
+		}
+	case *core.PayloadTransferCrossChainAsset:
+		for i, index := range payload.OutputIndexes {
+			if int(index) >= len(tx.Outputs) {
+				continue
+			}
+			output := tx.Outputs[index]
+			if output.AssetID != bc.AssetID || !output.ProgramHash.IsEqual(Uint168{}) {
+				continue
+			}
+			summary.Destroyed += output.Value
+			summary.Withdrawn += payload.CrossChainAmounts[i]
+			summary.FeesBurned += output.Value - payload.CrossChainAmounts[i]
+		}
+	}
+}
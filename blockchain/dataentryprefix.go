@@ -16,6 +16,13 @@ const (
 	IX_SideChain_Tx   DataEntryPrefix = 0x92
 	IX_MainChain_Tx   DataEntryPrefix = 0x93
 	IX_IDENTIFICATION DataEntryPrefix = 0x94
+	IX_ID_History     DataEntryPrefix = 0x95
+	IX_ID_Key         DataEntryPrefix = 0x96
+	IX_Withdrawal     DataEntryPrefix = 0x97
+	IX_Recharge       DataEntryPrefix = 0x98
+	IX_Reorganized    DataEntryPrefix = 0x99
+	IX_Invalid_Block  DataEntryPrefix = 0x9a
+	IX_Prune_Spent    DataEntryPrefix = 0x9b
 
 	// ASSET
 	ST_Info DataEntryPrefix = 0xc0
@@ -23,6 +30,9 @@ const (
 	//SYSTEM
 	SYS_CurrentBlock      DataEntryPrefix = 0x40
 	SYS_CurrentBookKeeper DataEntryPrefix = 0x42
+	SYS_PruneHeight       DataEntryPrefix = 0x43
+	SYS_WithdrawalIndex   DataEntryPrefix = 0x44
+	SYS_Reindexing        DataEntryPrefix = 0x45
 
 	//CONFIG
 	CFG_Version DataEntryPrefix = 0xf0
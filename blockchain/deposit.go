@@ -0,0 +1,105 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// Deposit is the RechargeToSideChain transaction which credits a main chain
+// deposit, it is saved in IX_MainChain_Tx with the main chain tx hash as key.
+type Deposit struct {
+	TxHash  Uint256
+	Height  uint32
+	Outputs []*DepositOutput
+}
+
+// DepositOutput is an output credited by the deposit.
+type DepositOutput struct {
+	Address string
+	Amount  Fixed64
+}
+
+// NewDeposit returns the deposit of the recharge transaction in height.
+func NewDeposit(txn *core.Transaction, height uint32) (*Deposit, error) {
+	deposit := &Deposit{TxHash: txn.Hash(), Height: height}
+	for _, output := range txn.Outputs {
+		address, err := output.ProgramHash.ToAddress()
+		if err != nil {
+			return nil, err
+		}
+		deposit.Outputs = append(deposit.Outputs, &DepositOutput{Address: address, Amount: output.Value})
+	}
+	return deposit, nil
+}
+
+func (d *Deposit) Serialize(w io.Writer) error {
+	if err := d.TxHash.Serialize(w); err != nil {
+		return err
+	}
+	if err := WriteUint32(w, d.Height); err != nil {
+		return err
+	}
+	if err := WriteVarUint(w, uint64(len(d.Outputs))); err != nil {
+		return err
+	}
+	for _, output := range d.Outputs {
+		if err := WriteVarString(w, output.Address); err != nil {
+			return err
+		}
+		if err := output.Amount.Serialize(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Deposit) Deserialize(r io.Reader) error {
+	if err := d.TxHash.Deserialize(r); err != nil {
+		return err
+	}
+	height, err := ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	d.Height = height
+	count, err := ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	d.Outputs = make([]*DepositOutput, 0, count)
+	for i := uint64(0); i < count; i++ {
+		output := new(DepositOutput)
+		if output.Address, err = ReadVarString(r); err != nil {
+			return err
+		}
+		if err := output.Amount.Deserialize(r); err != nil {
+			return err
+		}
+		d.Outputs = append(d.Outputs, output)
+	}
+	return nil
+}
+
+// GetDeposit returns the deposit of the main chain transaction, deposits
+// persisted without record only have the ValueExist flag.
+func (c *ChainStore) GetDeposit(mainchainTxHash Uint256) (*Deposit, error) {
+	key := []byte{byte(IX_MainChain_Tx)}
+	data, err := c.Get(append(key, mainchainTxHash.Bytes()...))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) <= 1 {
+		return nil, errors.New("[Deposit], deposit is not recorded.")
+	}
+
+	deposit := new(Deposit)
+	if err := deposit.Deserialize(bytes.NewReader(data[1:])); err != nil {
+		return nil, err
+	}
+	return deposit, nil
+}
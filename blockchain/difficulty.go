@@ -11,20 +11,32 @@ import (
 	. "github.com/elastos/Elastos.ELA.Utility/common"
 )
 
-var (
-	targetTimespan      = int64(config.Parameters.ChainParam.TargetTimespan / time.Second)
-	targetTimePerBlock  = int64(config.Parameters.ChainParam.TargetTimePerBlock / time.Second)
-	blocksPerRetarget   = uint32(targetTimespan / targetTimePerBlock)
-	minRetargetTimespan = int64(targetTimespan / config.Parameters.ChainParam.AdjustmentFactor)
-	maxRetargetTimespan = int64(targetTimespan * config.Parameters.ChainParam.AdjustmentFactor)
-)
-
 func CalcNextRequiredDifficulty(prevNode *BlockNode, newBlockTime time.Time) (uint32, error) {
+	return calcNextRequiredDifficulty(config.Parameters.ChainParam, prevNode, newBlockTime)
+}
+
+func calcNextRequiredDifficulty(params *config.ChainParams, prevNode *BlockNode, newBlockTime time.Time) (uint32, error) {
 	// Genesis block.
-	if (prevNode.Height == 0) || (config.Parameters.ChainParam.Name == "RegNet") {
-		return uint32(config.Parameters.ChainParam.PowLimitBits), nil
+	if prevNode.Height == 0 || params.NoRetargeting {
+		return params.PowLimitBits, nil
+	}
 
+	switch params.GetDifficultyAlgorithm(prevNode.Height + 1) {
+	case config.LWMAAlgorithm:
+		return calcLWMADifficulty(params, prevNode)
+	default:
+		return calcRetargetDifficulty(params, prevNode)
 	}
+}
+
+// calcRetargetDifficulty returns the difficulty of the block after prevNode
+// by the Bitcoin retarget algorithm.
+func calcRetargetDifficulty(params *config.ChainParams, prevNode *BlockNode) (uint32, error) {
+	targetTimespan := int64(params.TargetTimespan / time.Second)
+	targetTimePerBlock := int64(params.TargetTimePerBlock / time.Second)
+	blocksPerRetarget := uint32(targetTimespan / targetTimePerBlock)
+	minRetargetTimespan := targetTimespan / params.AdjustmentFactor
+	maxRetargetTimespan := targetTimespan * params.AdjustmentFactor
 
 	// Return the previous block's difficulty requirements if this block
 	// is not at a difficulty retarget interval.
@@ -43,6 +55,9 @@ func CalcNextRequiredDifficulty(prevNode *BlockNode, newBlockTime time.Time) (ui
 	for ; firstNode != nil && firstNode.Height != height; firstNode = firstNode.Parent {
 		// Intentionally left blank
 	}
+	if firstNode == nil {
+		return 0, errors.New("unable to obtain previous retarget block")
+	}
 
 	// Limit the amount of adjustment that can occur to the previous difficulty.
 	actualTimespan := int64(prevNode.Timestamp - firstNode.Timestamp)
@@ -63,8 +78,8 @@ func CalcNextRequiredDifficulty(prevNode *BlockNode, newBlockTime time.Time) (ui
 	newTarget.Div(newTarget, big.NewInt(targetTimespan))
 
 	// Limit new value to the proof of work limit.
-	if newTarget.Cmp(config.Parameters.ChainParam.PowLimit) > 0 {
-		newTarget.Set(config.Parameters.ChainParam.PowLimit)
+	if newTarget.Cmp(params.PowLimit) > 0 {
+		newTarget.Set(params.PowLimit)
 	}
 
 	// Log new target difficulty and return it.  The new target logging is
@@ -78,7 +93,77 @@ func CalcNextRequiredDifficulty(prevNode *BlockNode, newBlockTime time.Time) (ui
 	log.Tracef("Actual timespan %v, adjusted timespan %v, target timespan %v",
 		time.Duration(actualTimespan)*time.Second,
 		time.Duration(adjustedTimespan)*time.Second,
-		config.Parameters.ChainParam.TargetTimespan)
+		params.TargetTimespan)
+
+	return newTargetBits, nil
+}
+
+// calcLWMADifficulty returns the difficulty of the block after prevNode by the
+// linearly weighted moving average algorithm, the average target of the last
+// LWMAWindow blocks is adjusted by their solve times weighted by recency, so
+// the difficulty follows sudden changes of merge mining hashrate. Blocks
+// before the window is filled use the proof of work limit.
+func calcLWMADifficulty(params *config.ChainParams, prevNode *BlockNode) (uint32, error) {
+	window := params.LWMAWindow
+	if window == 0 {
+		return 0, errors.New("LWMA window is not set")
+	}
+	if prevNode.Height < window {
+		return params.PowLimitBits, nil
+	}
+
+	// Collect the last window + 1 blocks, the oldest one is only used for
+	// the timestamp of the first solve time.
+	nodes := make([]*BlockNode, window+1)
+	node := prevNode
+	for i := int(window); i >= 0; i-- {
+		if node == nil {
+			return 0, errors.New("unable to obtain LWMA window blocks")
+		}
+		nodes[i] = node
+		node = node.Parent
+	}
+
+	// Solve times are limited to 6 times the target time per block, and
+	// timestamps are made increasing so out of order timestamps can not
+	// lower the difficulty.
+	targetTimePerBlock := int64(params.TargetTimePerBlock / time.Second)
+	maxSolveTime := 6 * targetTimePerBlock
+	prevTimestamp := int64(nodes[0].Timestamp)
+	weightedSolveTimes := int64(0)
+	sumTarget := new(big.Int)
+	for i := 1; i <= int(window); i++ {
+		timestamp := int64(nodes[i].Timestamp)
+		if timestamp <= prevTimestamp {
+			timestamp = prevTimestamp + 1
+		}
+		solveTime := timestamp - prevTimestamp
+		if solveTime > maxSolveTime {
+			solveTime = maxSolveTime
+		}
+		prevTimestamp = timestamp
+
+		weightedSolveTimes += solveTime * int64(i)
+		sumTarget.Add(sumTarget, CompactToBig(nodes[i].Bits))
+	}
+
+	// Calculate new target as:
+	//  averageTarget * weightedSolveTimes / expectedWeightedSolveTimes
+	// where the expected weighted solve times is
+	//  window * (window + 1) / 2 * targetTimePerBlock
+	expected := int64(window) * int64(window+1) / 2 * targetTimePerBlock
+	newTarget := new(big.Int).Div(sumTarget, big.NewInt(int64(window)))
+	newTarget.Mul(newTarget, big.NewInt(weightedSolveTimes))
+	newTarget.Div(newTarget, big.NewInt(expected))
+
+	// Limit new value to the proof of work limit.
+	if newTarget.Cmp(params.PowLimit) > 0 {
+		newTarget.Set(params.PowLimit)
+	}
+
+	newTargetBits := BigToCompact(newTarget)
+	log.Tracef("LWMA difficulty at block height %d, new target %08x, weighted solve times %d, expected %d",
+		prevNode.Height+1, newTargetBits, weightedSolveTimes, expected)
 
 	return newTargetBits, nil
 }
@@ -0,0 +1,124 @@
+package blockchain
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newDifficultyTestChain returns the last node of a chain with the timestamps
+// and bits of its blocks from height 0.
+func newDifficultyTestChain(timestamps []uint32, bits []uint32) *BlockNode {
+	var node *BlockNode
+	for i := range timestamps {
+		node = &BlockNode{
+			Height:    uint32(i),
+			Timestamp: timestamps[i],
+			Bits:      bits[i],
+			Parent:    node,
+		}
+	}
+	return node
+}
+
+func newDifficultyTestParams() *config.ChainParams {
+	return &config.ChainParams{
+		PowLimit:                   new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(1)),
+		PowLimitBits:               0x207fffff,
+		TargetTimespan:             time.Second * 10 * 10,
+		TargetTimePerBlock:         time.Second * 10,
+		AdjustmentFactor:           4,
+		DifficultyAlgorithm:        config.LWMAAlgorithm,
+		DifficultyActivationHeight: math.MaxUint32,
+		LWMAWindow:                 5,
+	}
+}
+
+func repeatBits(bits uint32, count int) []uint32 {
+	result := make([]uint32, count)
+	for i := range result {
+		result[i] = bits
+	}
+	return result
+}
+
+func spacedTimestamps(spacing uint32, count int) []uint32 {
+	result := make([]uint32, count)
+	for i := range result {
+		result[i] = uint32(i) * spacing
+	}
+	return result
+}
+
+func TestCalcRetargetDifficulty(t *testing.T) {
+	params := newDifficultyTestParams()
+	bits := uint32(0x1e1da5ff)
+
+	tests := []struct {
+		name       string
+		timestamps []uint32
+		expected   uint32
+	}{
+		{"genesis", spacedTimestamps(10, 1), 0x207fffff},
+		{"not at retarget interval", spacedTimestamps(10, 6), bits},
+		{"faster blocks", spacedTimestamps(5, 10), 0x1e0d577f},
+		{"limited by slowest adjustment", spacedTimestamps(50, 10), 0x1e7697fc},
+		{"limited by fastest adjustment", spacedTimestamps(1, 10), 0x1e07697f},
+	}
+	for _, test := range tests {
+		prevNode := newDifficultyTestChain(test.timestamps, repeatBits(bits, len(test.timestamps)))
+		result, err := calcNextRequiredDifficulty(params, prevNode, time.Unix(0, 0))
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, result, test.name)
+	}
+
+	// all blocks use the proof of work limit without retargeting
+	params.NoRetargeting = true
+	prevNode := newDifficultyTestChain(spacedTimestamps(5, 10), repeatBits(bits, 10))
+	result, err := calcNextRequiredDifficulty(params, prevNode, time.Unix(0, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, params.PowLimitBits, result)
+}
+
+func TestCalcLWMADifficulty(t *testing.T) {
+	params := newDifficultyTestParams()
+	params.DifficultyActivationHeight = 0
+	bits := uint32(0x1e1da5ff)
+
+	tests := []struct {
+		name       string
+		timestamps []uint32
+		bits       []uint32
+		expected   uint32
+	}{
+		{"window not filled", spacedTimestamps(10, 5), repeatBits(bits, 5), 0x207fffff},
+		{"target solve times", spacedTimestamps(10, 6), repeatBits(bits, 6), bits},
+		{"faster blocks", spacedTimestamps(5, 6), repeatBits(bits, 6), 0x1e0ed2ff},
+		{"solve times limited", spacedTimestamps(100, 6), repeatBits(bits, 6), 0x1f00b1e3},
+		{"out of order timestamps", []uint32{100, 90, 200, 150, 250, 260}, repeatBits(bits, 6), 0x1e4921fd},
+		{"average target", spacedTimestamps(10, 6),
+			[]uint32{bits, bits, bits, bits, 0x1e0ed2ff, 0x1e0ed2ff}, 0x1e17b7ff},
+	}
+	for _, test := range tests {
+		prevNode := newDifficultyTestChain(test.timestamps, test.bits)
+		result, err := calcNextRequiredDifficulty(params, prevNode, time.Unix(0, 0))
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, result, test.name)
+	}
+
+	// the retarget algorithm is used before the activation height
+	params.DifficultyActivationHeight = 7
+	prevNode := newDifficultyTestChain(spacedTimestamps(5, 6), repeatBits(bits, 6))
+	result, err := calcNextRequiredDifficulty(params, prevNode, time.Unix(0, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, bits, result)
+	prevNode = newDifficultyTestChain(spacedTimestamps(5, 7), repeatBits(bits, 7))
+	result, err = calcNextRequiredDifficulty(params, prevNode, time.Unix(0, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0x1e0ed2ff), result)
+}
@@ -0,0 +1,53 @@
+package blockchain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain/config"
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+)
+
+// CheckBlockHeader checks the header received by headers-first sync before its
+// block is downloaded. prevNode is the node of the previous header, its
+// ancestors are linked by Parent to calculate the difficulty.
+func (bc *Blockchain) CheckBlockHeader(header *core.Header, prevNode *BlockNode) error {
+	return checkBlockHeader(config.Parameters.ChainParam, header, prevNode,
+		bc.TimeSource.AdjustedTime(), bc.GetBestHeight())
+}
+
+func checkBlockHeader(params *config.ChainParams, header *core.Header, prevNode *BlockNode,
+	adjustedTime time.Time, bestHeight uint32) error {
+	if prevNode == nil || !header.Previous.IsEqual(*prevNode.Hash) {
+		return errors.New("[CheckBlockHeader] header does not connect to the previous header")
+	}
+	if header.Height != prevNode.Height+1 {
+		return errors.New("[CheckBlockHeader] header height is not the expected")
+	}
+
+	hash := header.Hash()
+	if !header.SideAuxPow.SideAuxPowCheck(hash) {
+		return errors.New("[CheckBlockHeader] header check aux pow is failed")
+	}
+	if err := CheckProofOfWork(header, params.PowLimit); err != nil {
+		return err
+	}
+
+	bits, err := calcNextRequiredDifficulty(params, prevNode, time.Unix(int64(header.Timestamp), 0))
+	if err != nil {
+		return err
+	}
+	if header.Bits != bits {
+		return errors.New("[CheckBlockHeader] header difficulty is not the expected")
+	}
+
+	timestamp := time.Unix(int64(header.Timestamp), 0)
+	if !timestamp.After(CalcPastMedianTime(prevNode)) {
+		return errors.New("[CheckBlockHeader] header timestamp is not after expected")
+	}
+	if timestamp.After(adjustedTime.Add(time.Second * MaxTimeOffsetSeconds)) {
+		return errors.New("[CheckBlockHeader] header timestamp is too far in the future")
+	}
+
+	return checkCheckpoints(params, header.Height, hash, bestHeight)
+}
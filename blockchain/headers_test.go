@@ -0,0 +1,36 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain/config"
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	ela "github.com/elastos/Elastos.ELA/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckBlockHeader(t *testing.T) {
+	params := config.Parameters.ChainParam
+	prevHeader := &core.Header{Height: 10, Timestamp: uint32(time.Now().Unix())}
+	prevHash := prevHeader.Hash()
+	prevNode := NewBlockNode(prevHeader, &prevHash)
+
+	newHeader := func(previous common.Uint256, height uint32) *core.Header {
+		header := &core.Header{Previous: previous, Height: height, Timestamp: prevHeader.Timestamp + 1}
+		header.SideAuxPow.SideAuxBlockTx.Payload = new(ela.PayloadCoinBase)
+		return header
+	}
+
+	// the header does not connect to the previous header
+	assert.Error(t, checkBlockHeader(params, newHeader(common.Uint256{0x01}, 11), prevNode, time.Now(), 0))
+	assert.Error(t, checkBlockHeader(params, newHeader(prevHash, 11), nil, time.Now(), 0))
+
+	// the height is not the next height
+	assert.Error(t, checkBlockHeader(params, newHeader(prevHash, 12), prevNode, time.Now(), 0))
+
+	// the header connects but has no aux pow
+	assert.Error(t, checkBlockHeader(params, newHeader(prevHash, 11), prevNode, time.Now(), 0))
+}
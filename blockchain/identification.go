@@ -0,0 +1,358 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/elastos/Elastos.ELA.SideChain/common"
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+)
+
+// IdentificationVersion is one registration or revocation of an ID path, all
+// versions of a path are kept in IX_ID_History ordered by height. The values
+// are kept with the version, so the ID is resolved after the registration
+// transaction is pruned.
+type IdentificationVersion struct {
+	Height     uint32
+	TxHash     Uint256
+	Revoked    bool
+	DataHashes []Uint256
+	Proofs     []string
+}
+
+func (v *IdentificationVersion) Serialize(w io.Writer) error {
+	if err := v.TxHash.Serialize(w); err != nil {
+		return err
+	}
+	if err := WriteElement(w, v.Revoked); err != nil {
+		return err
+	}
+	if err := WriteVarUint(w, uint64(len(v.DataHashes))); err != nil {
+		return err
+	}
+	for i, hash := range v.DataHashes {
+		if err := hash.Serialize(w); err != nil {
+			return err
+		}
+		if err := WriteVarString(w, v.Proofs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *IdentificationVersion) Deserialize(r io.Reader) error {
+	if err := v.TxHash.Deserialize(r); err != nil {
+		return err
+	}
+	if err := ReadElement(r, &v.Revoked); err != nil {
+		return err
+	}
+	count, err := ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	v.DataHashes = nil
+	v.Proofs = nil
+	for i := uint64(0); i < count; i++ {
+		var hash Uint256
+		if err := hash.Deserialize(r); err != nil {
+			return err
+		}
+		proof, err := ReadVarString(r)
+		if err != nil {
+			return err
+		}
+		v.DataHashes = append(v.DataHashes, hash)
+		v.Proofs = append(v.Proofs, proof)
+	}
+	return nil
+}
+
+// ErrIdentificationNotFound is returned when an ID has no path registered.
+var ErrIdentificationNotFound = errors.New("[ChainStore], identification not found.")
+
+// IdentificationPath is a path of an ID and its version at a height.
+type IdentificationPath struct {
+	Path    string
+	Version *IdentificationVersion
+}
+
+// IdentificationDocument is an ID resolved at Height. Controller is the ID
+// program hash of the key which controls the ID, and Paths are the paths
+// registered and not revoked at Height ordered by path.
+type IdentificationDocument struct {
+	ID         string
+	Height     uint32
+	Controller Uint168
+	Paths      []IdentificationPath
+}
+
+// getIdentificationKey returns the history key prefix of an ID, or of an ID
+// path if path is not empty.
+func getIdentificationKey(id, path string) []byte {
+	key := new(bytes.Buffer)
+	key.WriteByte(byte(IX_ID_History))
+	WriteVarString(key, id)
+	if len(path) > 0 {
+		WriteVarString(key, path)
+	}
+	return key.Bytes()
+}
+
+// the version key is followed by big endian height and transaction index, so
+// versions are iterated in the order they are registered.
+func getIdentificationVersionKey(id, path string, height uint32, index int) []byte {
+	return appendHeightAndIndex(getIdentificationKey(id, path), height, index)
+}
+
+func appendHeightAndIndex(key []byte, height uint32, index int) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint32(buf[:4], height)
+	binary.BigEndian.PutUint32(buf[4:], uint32(index))
+	return append(key, buf[:]...)
+}
+
+// PersistIdentificationVersions adds versions of the paths registered by the
+// transaction at index of the block in height.
+func (c *ChainStore) PersistIdentificationVersions(txn *core.Transaction, height uint32, index int) error {
+	payload := txn.Payload.(*core.PayloadRegisterIdentification)
+	for _, content := range payload.Contents {
+		version := IdentificationVersion{Height: height, TxHash: txn.Hash()}
+		for _, value := range content.Values {
+			version.DataHashes = append(version.DataHashes, value.DataHash)
+			version.Proofs = append(version.Proofs, value.Proof)
+		}
+		value := new(bytes.Buffer)
+		if err := version.Serialize(value); err != nil {
+			return err
+		}
+		c.BatchPut(getIdentificationVersionKey(payload.ID, content.Path, height, index), value.Bytes())
+	}
+	return nil
+}
+
+// PersistIdentificationRevocations adds revoked versions of the paths revoked
+// by the transaction, the latest registration of the paths is not changed.
+func (c *ChainStore) PersistIdentificationRevocations(txn *core.Transaction, height uint32, index int) error {
+	payload := txn.Payload.(*core.PayloadRevokeIdentification)
+	for _, path := range payload.Paths {
+		version := IdentificationVersion{Height: height, TxHash: txn.Hash(), Revoked: true}
+		value := new(bytes.Buffer)
+		if err := version.Serialize(value); err != nil {
+			return err
+		}
+		c.BatchPut(getIdentificationVersionKey(payload.ID, path, height, index), value.Bytes())
+	}
+	return nil
+}
+
+func (c *ChainStore) RollbackIdentificationRevocations(txn *core.Transaction, height uint32, index int) error {
+	payload := txn.Payload.(*core.PayloadRevokeIdentification)
+	for _, path := range payload.Paths {
+		c.BatchDelete(getIdentificationVersionKey(payload.ID, path, height, index))
+	}
+	return nil
+}
+
+// RollbackIdentificationVersions removes versions added by the transaction,
+// and points the latest registration of the paths to the previous versions.
+func (c *ChainStore) RollbackIdentificationVersions(txn *core.Transaction, height uint32, index int) error {
+	payload := txn.Payload.(*core.PayloadRegisterIdentification)
+	for _, content := range payload.Contents {
+		c.BatchDelete(getIdentificationVersionKey(payload.ID, content.Path, height, index))
+
+		versions, err := c.GetIdentificationHistory(payload.ID, content.Path)
+		if err != nil {
+			return err
+		}
+		idKey := new(bytes.Buffer)
+		idKey.WriteString(payload.ID)
+		idKey.WriteString(content.Path)
+
+		// versions of the same block are all rolled back
+		var previous *IdentificationVersion
+		for _, version := range versions {
+			if version.Height < height && !version.Revoked {
+				previous = version
+			}
+		}
+		if previous != nil {
+			c.PersistRegisterIdentificationTx(idKey.Bytes(), previous.TxHash)
+		} else {
+			c.BatchDelete(append([]byte{byte(IX_IDENTIFICATION)}, idKey.Bytes()...))
+		}
+	}
+	return nil
+}
+
+// GetIdentificationHistory returns all versions of the ID path, from the first
+// registration to the latest.
+func (c *ChainStore) GetIdentificationHistory(id, path string) ([]*IdentificationVersion, error) {
+	prefix := getIdentificationKey(id, path)
+	var versions []*IdentificationVersion
+	iter := c.NewIterator(prefix)
+	defer iter.Release()
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != len(prefix)+8 {
+			continue
+		}
+		version := &IdentificationVersion{Height: binary.BigEndian.Uint32(key[len(prefix):])}
+		if err := version.Deserialize(bytes.NewReader(iter.Value())); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// GetIdentificationPaths returns the paths ever registered under the ID.
+func (c *ChainStore) GetIdentificationPaths(id string) ([]string, error) {
+	prefix := getIdentificationKey(id, "")
+	paths := make(map[string]struct{})
+	iter := c.NewIterator(prefix)
+	defer iter.Release()
+	for iter.Next() {
+		path, err := ReadVarString(bytes.NewReader(iter.Key()[len(prefix):]))
+		if err != nil {
+			return nil, err
+		}
+		paths[path] = struct{}{}
+	}
+
+	list := make([]string, 0, len(paths))
+	for path := range paths {
+		list = append(list, path)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// ResolveIdentification returns the document of the ID at height, the version
+// of each path is the latest one registered or revoked to the height. It
+// returns ErrIdentificationNotFound if no path is registered at height.
+func (c *ChainStore) ResolveIdentification(id string, height uint32) (*IdentificationDocument, error) {
+	paths, err := c.GetIdentificationPaths(id)
+	if err != nil {
+		return nil, err
+	}
+	doc := &IdentificationDocument{ID: id, Height: height}
+	for _, path := range paths {
+		versions, err := c.GetIdentificationHistory(id, path)
+		if err != nil {
+			return nil, err
+		}
+		var current *IdentificationVersion
+		for _, version := range versions {
+			if version.Height <= height {
+				current = version
+			}
+		}
+		if current == nil || current.Revoked {
+			continue
+		}
+		doc.Paths = append(doc.Paths, IdentificationPath{Path: path, Version: current})
+	}
+	if len(doc.Paths) == 0 {
+		return nil, ErrIdentificationNotFound
+	}
+
+	controller, err := c.GetIdentificationKeyAtHeight(id, height)
+	if err != nil {
+		return nil, err
+	}
+	doc.Controller = *controller
+	return doc, nil
+}
+
+// PersistIdentificationKey saves the ID program hash of the new key as the
+// current key of the ID.
+func (c *ChainStore) PersistIdentificationKey(txn *core.Transaction, height uint32, index int) error {
+	payload := txn.Payload.(*core.PayloadRotateIdentificationKey)
+	programHash, err := GetIdentificationProgramHash(payload.PublicKey)
+	if err != nil {
+		return err
+	}
+	c.BatchPut(getIdentificationKeyKey(payload.ID, height, index), programHash.Bytes())
+	return nil
+}
+
+func (c *ChainStore) RollbackIdentificationKey(txn *core.Transaction, height uint32, index int) error {
+	payload := txn.Payload.(*core.PayloadRotateIdentificationKey)
+	c.BatchDelete(getIdentificationKeyKey(payload.ID, height, index))
+	return nil
+}
+
+// GetIdentificationKey returns the ID program hash of the current key of the
+// ID, which is the ID itself if the key has never been rotated.
+func (c *ChainStore) GetIdentificationKey(id string) (*Uint168, error) {
+	return c.GetIdentificationKeyAtHeight(id, math.MaxUint32)
+}
+
+// GetIdentificationKeyAtHeight returns the ID program hash of the key which
+// controls the ID at height.
+func (c *ChainStore) GetIdentificationKeyAtHeight(id string, height uint32) (*Uint168, error) {
+	programHash, err := Uint168FromAddress(id)
+	if err != nil {
+		return nil, err
+	}
+	key := new(bytes.Buffer)
+	key.WriteByte(byte(IX_ID_Key))
+	WriteVarString(key, id)
+	prefix := key.Bytes()
+	iter := c.NewIterator(prefix)
+	defer iter.Release()
+	for iter.Next() {
+		if binary.BigEndian.Uint32(iter.Key()[len(prefix):]) > height {
+			break
+		}
+		programHash, err = Uint168FromBytes(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return programHash, nil
+}
+
+func getIdentificationKeyKey(id string, height uint32, index int) []byte {
+	key := new(bytes.Buffer)
+	key.WriteByte(byte(IX_ID_Key))
+	WriteVarString(key, id)
+	return appendHeightAndIndex(key.Bytes(), height, index)
+}
+
+// GetIdentificationProgramHash returns the ID program hash of the encoded
+// public key, the ID program is the standard program with CHECKREGID.
+func GetIdentificationProgramHash(publicKey []byte) (*Uint168, error) {
+	pk, err := crypto.DecodePoint(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	code, err := crypto.CreateStandardRedeemScript(pk)
+	if err != nil {
+		return nil, err
+	}
+	code[len(code)-1] = REGISTERID
+	return common.ToProgramHash(code)
+}
+
+// getIdentificationUpdateID returns the ID changed by a revoke or rotate key
+// transaction, only one of them is allowed for an ID in a block or in the
+// transaction pool.
+func getIdentificationUpdateID(txn *core.Transaction) (string, bool) {
+	switch payload := txn.Payload.(type) {
+	case *core.PayloadRevokeIdentification:
+		return payload.ID, true
+	case *core.PayloadRotateIdentificationKey:
+		return payload.ID, true
+	}
+	return "", false
+}
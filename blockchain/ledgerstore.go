@@ -1,6 +1,8 @@
 package blockchain
 
 import (
+	"io"
+
 	"github.com/elastos/Elastos.ELA.SideChain/core"
 
 	. "github.com/elastos/Elastos.ELA.Utility/common"
@@ -18,6 +20,20 @@ type IChainStore interface {
 	GetHeader(hash Uint256) (*core.Header, error)
 
 	RollbackBlock(hash Uint256) error
+	Reindex() error
+	IsReindexing() bool
+	BuildWithdrawalIndex() error
+	VerifyChain(blocks uint32) error
+	Prune() error
+	GetPruneHeight() (uint32, bool)
+	IsBlockPruned(hash Uint256) bool
+	ExportSnapshot(w io.Writer, height uint32) error
+	ImportSnapshot(r io.Reader) (uint32, error)
+	GetTxOutSetInfo() (*TxOutSetInfo, error)
+
+	PersistInvalidBlock(hash Uint256) error
+	RemoveInvalidBlock(hash Uint256) error
+	IsBlockInvalid(hash Uint256) bool
 
 	GetTransaction(txId Uint256) (*core.Transaction, uint32, error)
 	GetTxReference(tx *core.Transaction) (map[*core.Input]*core.Output, error)
@@ -25,11 +41,26 @@ type IChainStore interface {
 	PersistAsset(assetid Uint256, asset core.Asset) error
 	GetAsset(hash Uint256) (*core.Asset, error)
 
-	PersistMainchainTx(mainchainTxHash Uint256)
+	PersistMainchainTx(mainchainTxHash Uint256, deposit *Deposit)
 	GetMainchainTx(mainchainTxHash Uint256) (byte, error)
+	GetDeposit(mainchainTxHash Uint256) (*Deposit, error)
+	GetRecharges(mainchainHeight uint32) ([]Uint256, error)
+	PersistDepositReorganized(mainchainTxHash Uint256, mainchainHeight uint32) error
+	IsDepositReorganized(mainchainTxHash Uint256) bool
+	RemoveDepositReorganized(mainchainTxHash Uint256) error
+	GetReorganizedDeposits() ([]Uint256, error)
 
 	PersistRegisterIdentificationTx(idKey []byte, txHash Uint256)
 	GetRegisterIdentificationTx(idKey []byte) ([]byte, error)
+	GetIdentificationHistory(id, path string) ([]*IdentificationVersion, error)
+	GetIdentificationPaths(id string) ([]string, error)
+	GetIdentificationKey(id string) (*Uint168, error)
+	GetIdentificationKeyAtHeight(id string, height uint32) (*Uint168, error)
+	ResolveIdentification(id string, height uint32) (*IdentificationDocument, error)
+
+	GetWithdrawal(txHash Uint256) (*Withdrawal, error)
+	GetWithdrawals(height uint32) ([]*Withdrawal, error)
+
 
 	GetCurrentBlockHash() Uint256
 	GetHeight() uint32
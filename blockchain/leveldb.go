@@ -13,6 +13,10 @@ type LevelDB struct {
 	batch *leveldb.Batch
 }
 
+type LevelDBSnapshot struct {
+	snapshot *leveldb.Snapshot
+}
+
 // used to compute the size of bloom filter bits array .
 // too small will lead to high false positive rate.
 const BITSPERKEY = 10
@@ -77,3 +81,24 @@ func (db *LevelDB) NewIterator(prefix []byte) IIterator {
 	iter := db.db.NewIterator(util.BytesPrefix(prefix), nil)
 	return &Iterator{iter: iter}
 }
+
+func (db *LevelDB) NewSnapshot() (IStoreSnapshot, error) {
+	snapshot, err := db.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &LevelDBSnapshot{snapshot: snapshot}, nil
+}
+
+func (s *LevelDBSnapshot) Get(key []byte) ([]byte, error) {
+	return s.snapshot.Get(key, nil)
+}
+
+func (s *LevelDBSnapshot) NewIterator(prefix []byte) IIterator {
+	iter := s.snapshot.NewIterator(util.BytesPrefix(prefix), nil)
+	return &Iterator{iter: iter}
+}
+
+func (s *LevelDBSnapshot) Release() {
+	s.snapshot.Release()
+}
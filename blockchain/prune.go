@@ -0,0 +1,154 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/elastos/Elastos.ELA.SideChain/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// MinPruneDepth is the minimum number of recent blocks kept by a pruning node,
+// blocks deeper than the prune depth can not be rolled back.
+const MinPruneDepth = 288
+
+// ErrForkPruned is returned when the blocks to disconnect are on top of pruned
+// blocks, the transactions they spend may have been removed.
+var ErrForkPruned = errors.New("[Blockchain], blocks on top of pruned blocks can not be disconnected.")
+
+// The transactions spent by pruned blocks are counted in IX_Prune_Spent, the
+// body of a transaction is removed once all of its outputs are spent by pruned
+// blocks, so it's not needed to roll back blocks or to look up unspents.
+func getPruneSpentKey(txHash Uint256) []byte {
+	return append([]byte{byte(IX_Prune_Spent)}, txHash.Bytes()...)
+}
+
+// GetPruneHeight returns the height of the highest pruned block, and false if
+// no block is pruned.
+func (c *ChainStore) GetPruneHeight() (uint32, bool) {
+	data, err := c.Get([]byte{byte(SYS_PruneHeight)})
+	if err != nil || len(data) != 4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(data), true
+}
+
+// IsBlockPruned returns if the transactions of the block may be removed.
+func (c *ChainStore) IsBlockPruned(hash Uint256) bool {
+	pruneHeight, ok := c.GetPruneHeight()
+	if !ok {
+		return false
+	}
+	header, err := c.GetHeader(hash)
+	if err != nil {
+		return false
+	}
+	return header.Height <= pruneHeight
+}
+
+// Prune prunes the blocks deeper than the prune depth, it's called at startup
+// to catch up when pruning is enabled on an existing chain store.
+func (c *ChainStore) Prune() error {
+	if c.pruneDepth == 0 {
+		return nil
+	}
+	height := c.GetHeight()
+	if height < c.pruneDepth {
+		return nil
+	}
+	from := uint32(0)
+	if pruneHeight, ok := c.GetPruneHeight(); ok {
+		from = pruneHeight + 1
+	}
+	to := height - c.pruneDepth
+	if from > to {
+		return nil
+	}
+
+	log.Infof("[Prune] prune blocks from height %d to %d", from, to)
+	for h := from; h <= to; h++ {
+		c.NewBatch()
+		if err := c.pruneBlock(h, make(map[Uint256]uint16)); err != nil {
+			return err
+		}
+		if err := c.BatchCommit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneBlocks prunes the blocks deeper than the prune depth below the block
+// at height in the current batch.
+func (c *ChainStore) pruneBlocks(height uint32) error {
+	if c.pruneDepth == 0 || height < c.pruneDepth {
+		return nil
+	}
+	from := uint32(0)
+	if pruneHeight, ok := c.GetPruneHeight(); ok {
+		from = pruneHeight + 1
+	}
+
+	// the batch is not visible to reads, so spent counts of the blocks in
+	// the batch are kept in memory.
+	spent := make(map[Uint256]uint16)
+	for h := from; h <= height-c.pruneDepth; h++ {
+		if err := c.pruneBlock(h, spent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ChainStore) pruneBlock(height uint32, spent map[Uint256]uint16) error {
+	hash, err := c.GetBlockHash(height)
+	if err != nil {
+		return err
+	}
+	block, err := c.GetBlock(hash)
+	if err != nil {
+		return err
+	}
+
+	for _, txn := range block.Transactions {
+		if txn.IsCoinBaseTx() {
+			continue
+		}
+		for _, input := range txn.Inputs {
+			txID := input.Previous.TxID
+			count, ok := spent[txID]
+			if !ok {
+				count = c.getPruneSpent(txID)
+			}
+			count++
+			spent[txID] = count
+
+			referTxn, _, err := c.GetTransaction(txID)
+			if err != nil {
+				return err
+			}
+			if int(count) < len(referTxn.Outputs) {
+				var value [2]byte
+				binary.BigEndian.PutUint16(value[:], count)
+				c.BatchPut(getPruneSpentKey(txID), value[:])
+				continue
+			}
+			c.BatchDelete(getPruneSpentKey(txID))
+			c.BatchDelete(append([]byte{byte(DATA_Transaction)}, txID.Bytes()...))
+		}
+	}
+
+	var value [4]byte
+	binary.BigEndian.PutUint32(value[:], height)
+	c.BatchPut([]byte{byte(SYS_PruneHeight)}, value[:])
+	return nil
+}
+
+func (c *ChainStore) getPruneSpent(txHash Uint256) uint16 {
+	data, err := c.Get(getPruneSpentKey(txHash))
+	if err != nil || len(data) != 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(data)
+}
@@ -0,0 +1,249 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA.SideChain/config"
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+	"github.com/elastos/Elastos.ELA.SideChain/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+)
+
+// MaxVerifyChainBlocks is the max number of blocks verified by a verifychain
+// request.
+const MaxVerifyChainBlocks = 10000
+
+// indexPrefix is an index of the chain store besides the blocks.
+type indexPrefix struct {
+	prefix DataEntryPrefix
+	// rebuilt is true for the indexes built from the stored main chain
+	// blocks. Marks written out of blocks, like reorganized deposits and
+	// invalid blocks, can not be rebuilt and are kept by reindex.
+	rebuilt bool
+}
+
+// indexPrefixes are the indexes exported in a snapshot, the rebuilt ones are
+// removed and built again by reindex.
+var indexPrefixes = []indexPrefix{
+	{IX_Unspent, true},
+	{IX_Unspent_UTXO, true},
+	{IX_MainChain_Tx, true},
+	{IX_IDENTIFICATION, true},
+	{IX_ID_History, true},
+	{IX_ID_Key, true},
+	{IX_Withdrawal, true},
+	{IX_Recharge, true},
+	{ST_Info, true},
+	{IX_Reorganized, false},
+	{IX_Invalid_Block, false},
+}
+
+// IsReindexing returns if the last reindex is not finished, the indexes are
+// incomplete and must be rebuilt again.
+func (c *ChainStore) IsReindexing() bool {
+	_, err := c.Get([]byte{byte(SYS_Reindexing)})
+	return err == nil
+}
+
+// Reindex removes the indexes and rebuilds them from the stored main chain
+// blocks. It must be called at startup before blocks are saved, the reindex
+// mark is kept until all indexes are rebuilt.
+func (c *ChainStore) Reindex() error {
+	if _, ok := c.GetPruneHeight(); ok {
+		return errors.New("[Reindex], can not reindex a pruned chain store.")
+	}
+	height := c.GetHeight()
+	log.Infof("[Reindex] rebuild indexes from %d blocks", height+1)
+
+	c.NewBatch()
+	c.BatchPut([]byte{byte(SYS_Reindexing)}, []byte{1})
+	for _, index := range indexPrefixes {
+		if !index.rebuilt {
+			continue
+		}
+		iter := c.NewIterator([]byte{byte(index.prefix)})
+		for iter.Next() {
+			c.BatchDelete(iter.Key())
+		}
+		iter.Release()
+	}
+	if err := c.BatchCommit(); err != nil {
+		return err
+	}
+
+	for h := uint32(0); h <= height; h++ {
+		hash, err := c.GetBlockHash(h)
+		if err != nil {
+			return err
+		}
+		block, err := c.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+
+		c.NewBatch()
+		if err := c.PersistTransactions(block); err != nil {
+			return err
+		}
+		if err := c.PersistUnspendUTXOs(block); err != nil {
+			return err
+		}
+		if err := c.PersistUnspend(block); err != nil {
+			return err
+		}
+		if err := c.BatchCommit(); err != nil {
+			return err
+		}
+
+		if h%10000 == 0 {
+			log.Infof("[Reindex] indexed blocks to height %d", h)
+		}
+	}
+
+	// IX_Withdrawal is rebuilt with other indexes
+	c.NewBatch()
+	c.BatchPut([]byte{byte(SYS_WithdrawalIndex)}, []byte{1})
+	c.BatchDelete([]byte{byte(SYS_Reindexing)})
+	if err := c.BatchCommit(); err != nil {
+		return err
+	}
+
+	log.Info("[Reindex] indexes are rebuilt")
+	return nil
+}
+
+// VerifyChain verifies the last blocks of the main chain, all blocks which
+// are not pruned are verified if blocks is 0. It reads a snapshot of the store
+// while blocks are saved.
+func (c *ChainStore) VerifyChain(blocks uint32) error {
+	view, err := c.newReadOnlyView()
+	if err != nil {
+		return err
+	}
+	defer view.IStore.Close()
+	return view.verifyChain(blocks)
+}
+
+func (c *ChainStore) verifyChain(blocks uint32) error {
+	best := c.GetHeight()
+	start := uint32(0)
+	if blocks > 0 && blocks <= best {
+		start = best - blocks + 1
+	}
+	// transactions of pruned blocks are removed
+	if pruneHeight, ok := c.GetPruneHeight(); ok && start <= pruneHeight {
+		start = pruneHeight + 1
+	}
+	if start > best {
+		return nil
+	}
+	log.Infof("[VerifyChain] verify blocks from height %d to %d", start, best)
+
+	for height := best; ; height-- {
+		if err := c.verifyBlock(height); err != nil {
+			return fmt.Errorf("[VerifyChain], block at height %d: %v", height, err)
+		}
+		if height == start {
+			break
+		}
+	}
+	return nil
+}
+
+// verifyBlock checks the stored block at height is linked to the previous
+// block, has valid aux pow and merkle root, and its transactions match the
+// transaction and UTXO indexes.
+func (c *ChainStore) verifyBlock(height uint32) error {
+	hash, err := c.GetBlockHash(height)
+	if err != nil {
+		return err
+	}
+	block, err := c.GetBlock(hash)
+	if err != nil {
+		return err
+	}
+	header := block.Header
+	if !header.Hash().IsEqual(hash) {
+		return errors.New("block hash mismatch")
+	}
+	if header.Height != height {
+		return errors.New("block height mismatch")
+	}
+
+	if height > 0 {
+		prevHash, err := c.GetBlockHash(height - 1)
+		if err != nil {
+			return err
+		}
+		if !header.Previous.IsEqual(prevHash) {
+			return errors.New("previous block hash mismatch")
+		}
+		if !header.SideAuxPow.SideAuxPowCheck(hash) {
+			return errors.New("aux pow check failed")
+		}
+		if err := CheckProofOfWork(&header, config.Parameters.ChainParam.PowLimit); err != nil {
+			return err
+		}
+	}
+
+	txIds := make([]Uint256, 0, len(block.Transactions))
+	for _, txn := range block.Transactions {
+		txIds = append(txIds, txn.Hash())
+	}
+	root, err := crypto.ComputeRoot(txIds)
+	if err != nil {
+		return err
+	}
+	if !header.MerkleRoot.IsEqual(root) {
+		return errors.New("merkle root mismatch")
+	}
+
+	for _, txn := range block.Transactions {
+		if err := c.verifyTransactionIndexes(txn, height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ChainStore) verifyTransactionIndexes(txn *core.Transaction, height uint32) error {
+	txHash := txn.Hash()
+	if _, txHeight, err := c.GetTransaction(txHash); err != nil || txHeight != height {
+		return fmt.Errorf("transaction %x is not stored at the block height", txHash.Bytes())
+	}
+	if txn.TxType == core.RegisterAsset {
+		return nil
+	}
+
+	// unspent outputs must be in the UTXO index of their program hash
+	for index, output := range txn.Outputs {
+		if unspent, _ := c.ContainsUnspent(txHash, uint16(index)); !unspent {
+			continue
+		}
+		utxos, _ := c.GetUnspentElementFromProgramHash(output.ProgramHash, output.AssetID, height)
+		found := false
+		for _, utxo := range utxos {
+			if utxo.TxId.IsEqual(txHash) && utxo.Index == uint32(index) && utxo.Value == output.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("output %x:%d is missing from the UTXO index", txHash.Bytes(), index)
+		}
+	}
+
+	// outputs spent by the transaction must not be unspent
+	if !txn.IsCoinBaseTx() {
+		for _, input := range txn.Inputs {
+			if unspent, _ := c.ContainsUnspent(input.Previous.TxID, input.Previous.Index); unspent {
+				return fmt.Errorf("spent output %x:%d is in the unspent index",
+					input.Previous.TxID.Bytes(), input.Previous.Index)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package blockchain
+
+import (
+	"encoding/binary"
+
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+	"github.com/elastos/Elastos.ELA.SideChain/events"
+	"github.com/elastos/Elastos.ELA.SideChain/log"
+	"github.com/elastos/Elastos.ELA.SideChain/spv"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// Recharge transactions are kept in IX_Recharge ordered by the height of the
+// main chain block in their merkle proof, so the deposits which depend on the
+// main chain blocks removed by a reorganization can be found.
+func getRechargeKey(mainchainHeight uint32, txHash Uint256) []byte {
+	key := make([]byte, 5, 5+UINT256SIZE)
+	key[0] = byte(IX_Recharge)
+	binary.BigEndian.PutUint32(key[1:], mainchainHeight)
+	return append(key, txHash.Bytes()...)
+}
+
+func getReorganizedKey(mainchainTxHash Uint256) []byte {
+	return append([]byte{byte(IX_Reorganized)}, mainchainTxHash.Bytes()...)
+}
+
+// PersistRecharge adds the recharge transaction to the main chain block it
+// depends on.
+func (c *ChainStore) PersistRecharge(txn *core.Transaction) error {
+	payload := txn.Payload.(*core.PayloadRechargeToSideChain)
+	proof, err := payload.GetMerkleProof()
+	if err != nil {
+		return err
+	}
+	hash, err := payload.GetMainchainTxHash()
+	if err != nil {
+		return err
+	}
+	c.BatchPut(getRechargeKey(proof.Height, txn.Hash()), hash.Bytes())
+	return nil
+}
+
+func (c *ChainStore) RollbackRecharge(txn *core.Transaction) error {
+	payload := txn.Payload.(*core.PayloadRechargeToSideChain)
+	proof, err := payload.GetMerkleProof()
+	if err != nil {
+		return err
+	}
+	hash, err := payload.GetMainchainTxHash()
+	if err != nil {
+		return err
+	}
+	c.BatchDelete(getRechargeKey(proof.Height, txn.Hash()))
+	c.BatchDelete(getReorganizedKey(*hash))
+	return nil
+}
+
+// GetRecharges returns the main chain transaction hashes deposited by recharge
+// transactions which depend on main chain blocks from mainchainHeight.
+func (c *ChainStore) GetRecharges(mainchainHeight uint32) ([]Uint256, error) {
+	var hashes []Uint256
+	iter := c.NewIterator([]byte{byte(IX_Recharge)})
+	defer iter.Release()
+	for ok := iter.Seek(getRechargeKey(mainchainHeight, Uint256{})); ok; ok = iter.Next() {
+		hash, err := Uint256FromBytes(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, *hash)
+	}
+	return hashes, nil
+}
+
+// PersistDepositReorganized marks the confirmed deposit as reorganized by the
+// main chain rollback to mainchainHeight. It's written out of the block batch,
+// and is removed when the recharge transaction is rolled back, or when the
+// deposit is proved by the SPV module again.
+func (c *ChainStore) PersistDepositReorganized(mainchainTxHash Uint256, mainchainHeight uint32) error {
+	var value [4]byte
+	binary.BigEndian.PutUint32(value[:], mainchainHeight)
+	return c.Put(getReorganizedKey(mainchainTxHash), value[:])
+}
+
+func (c *ChainStore) IsDepositReorganized(mainchainTxHash Uint256) bool {
+	_, err := c.Get(getReorganizedKey(mainchainTxHash))
+	return err == nil
+}
+
+// RemoveDepositReorganized removes the reorganized mark of the deposit.
+func (c *ChainStore) RemoveDepositReorganized(mainchainTxHash Uint256) error {
+	return c.Delete(getReorganizedKey(mainchainTxHash))
+}
+
+// GetReorganizedDeposits returns the main chain transaction hashes of the
+// deposits marked as reorganized.
+func (c *ChainStore) GetReorganizedDeposits() ([]Uint256, error) {
+	var hashes []Uint256
+	iter := c.NewIterator([]byte{byte(IX_Reorganized)})
+	defer iter.Release()
+	for iter.Next() {
+		hash, err := Uint256FromBytes(iter.Key()[1:])
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, *hash)
+	}
+	return hashes, nil
+}
+
+// MainChainRollback is called when the main chain blocks from height are
+// removed, the confirmed deposits which depend on them no longer have a valid
+// proof, so they are marked as reorganized and EventDepositReorganized is sent
+// with their main chain transaction hashes.
+func (bc *Blockchain) MainChainRollback(v interface{}) {
+	height, ok := v.(uint32)
+	if !ok {
+		return
+	}
+
+	hashes, err := DefaultLedger.Store.GetRecharges(height)
+	if err != nil {
+		log.Error("[MainChainRollback] get recharges failed:", err)
+		return
+	}
+	for _, hash := range hashes {
+		if err := DefaultLedger.Store.PersistDepositReorganized(hash, height); err != nil {
+			log.Error("[MainChainRollback] mark deposit reorganized failed:", err)
+			continue
+		}
+		log.Warnf("[MainChainRollback] deposit of main chain transaction %s is reorganized"+
+			" by main chain rollback to height %d", BytesToHexString(BytesReverse(hash.Bytes())), height)
+	}
+	if len(hashes) > 0 {
+		bc.BCEvents.Notify(events.EventDepositReorganized, hashes)
+	}
+}
+
+// VerifyReorganizedDeposits verifies the reorganized deposits by the SPV module
+// again when a block is persisted, the reorganized mark is removed once the
+// main chain has a block at the height of the proof and the proof is valid.
+func (bc *Blockchain) VerifyReorganizedDeposits(v interface{}) {
+	hashes, err := DefaultLedger.Store.GetReorganizedDeposits()
+	if err != nil || len(hashes) == 0 {
+		return
+	}
+	bestHeight, err := spv.GetBestHeight()
+	if err != nil {
+		return
+	}
+	for _, hash := range hashes {
+		deposit, err := DefaultLedger.Store.GetDeposit(hash)
+		if err != nil {
+			continue
+		}
+		txn, _, err := DefaultLedger.Store.GetTransaction(deposit.TxHash)
+		if err != nil {
+			continue
+		}
+		proof, err := txn.Payload.(*core.PayloadRechargeToSideChain).GetMerkleProof()
+		if err != nil || proof.Height > bestHeight {
+			continue
+		}
+		if err := spv.VerifyTransaction(txn); err != nil {
+			continue
+		}
+		if err := DefaultLedger.Store.RemoveDepositReorganized(hash); err != nil {
+			log.Error("[VerifyReorganizedDeposits] remove reorganized mark failed:", err)
+			continue
+		}
+		log.Infof("[VerifyReorganizedDeposits] deposit of main chain transaction %s is proved"+
+			" in main chain again", BytesToHexString(BytesReverse(hash.Bytes())))
+	}
+}
+
+// mainChainRollback evicts the recharge transactions in pool which depend on
+// the main chain blocks from height.
+func (pool *TxPool) mainChainRollback(v interface{}) {
+	height, ok := v.(uint32)
+	if !ok {
+		return
+	}
+
+	evicted := make(map[Uint256]*core.Transaction)
+	pool.RLock()
+	for hash, txn := range pool.mainchainTxList {
+		proof, err := txn.Payload.(*core.PayloadRechargeToSideChain).GetMerkleProof()
+		if err != nil || proof.Height >= height {
+			evicted[hash] = txn
+		}
+	}
+	pool.RUnlock()
+
+	for hash, txn := range evicted {
+		pool.delFromTxList(txn.Hash())
+		pool.delMainchainTx(hash)
+		log.Warnf("[MainChainRollback] recharge transaction %s is evicted from pool", BytesToHexString(BytesReverse(txn.Hash().Bytes())))
+	}
+}
@@ -0,0 +1,105 @@
+package blockchain
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA.SideChain/config"
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+
+	"github.com/elastos/Elastos.ELA.SideChain/vm"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+const DefaultMaxSigCacheEntries = 50000
+
+// SigCache holds transactions which signatures have been verified, so the
+// same transaction will not be verified again when it arrives in a block.
+var SigCache = NewSignatureCache(config.Parameters.MaxSigCacheSize)
+
+// SignatureCache is a concurrent safe cache of verified transactions, the key is
+// transaction hash and the value is the hash of it's programs and the script
+// rules they are verified by. Transaction hash do not cover programs, so
+// programs are compared again on lookup.
+type SignatureCache struct {
+	sync.RWMutex
+	validSigs  map[Uint256]Uint256
+	maxEntries uint
+}
+
+func NewSignatureCache(maxEntries uint) *SignatureCache {
+	if maxEntries == 0 {
+		maxEntries = DefaultMaxSigCacheEntries
+	}
+	return &SignatureCache{
+		validSigs:  make(map[Uint256]Uint256),
+		maxEntries: maxEntries,
+	}
+}
+
+// Exists returns if the transaction with the same programs have been verified
+// by the script rules.
+func (c *SignatureCache) Exists(tx *core.Transaction, flags vm.VerifyFlags) bool {
+	programsHash, err := getProgramsHash(tx, flags)
+	if err != nil {
+		return false
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+	hash, ok := c.validSigs[tx.Hash()]
+	return ok && hash == programsHash
+}
+
+// Add puts a verified transaction into cache, when the cache is full a random
+// entry will be evicted.
+func (c *SignatureCache) Add(tx *core.Transaction, flags vm.VerifyFlags) {
+	programsHash, err := getProgramsHash(tx, flags)
+	if err != nil {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if uint(len(c.validSigs)) >= c.maxEntries {
+		// map iteration order is random, so this is a random eviction
+		for txHash := range c.validSigs {
+			delete(c.validSigs, txHash)
+			break
+		}
+	}
+	c.validSigs[tx.Hash()] = programsHash
+}
+
+// Remove deletes transactions from cache, it is called after the transactions
+// have been saved into a block.
+func (c *SignatureCache) Remove(txs []*core.Transaction) {
+	c.Lock()
+	defer c.Unlock()
+	for _, tx := range txs {
+		delete(c.validSigs, tx.Hash())
+	}
+}
+
+func (c *SignatureCache) Count() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.validSigs)
+}
+
+func getProgramsHash(tx *core.Transaction, flags vm.VerifyFlags) (Uint256, error) {
+	buf := new(bytes.Buffer)
+	if err := WriteUint32(buf, uint32(flags)); err != nil {
+		return Uint256{}, err
+	}
+	if err := WriteVarUint(buf, uint64(len(tx.Programs))); err != nil {
+		return Uint256{}, err
+	}
+	for _, program := range tx.Programs {
+		if err := program.Serialize(buf); err != nil {
+			return Uint256{}, err
+		}
+	}
+	return Uint256(Sha256D(buf.Bytes())), nil
+}
@@ -0,0 +1,332 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+	"github.com/elastos/Elastos.ELA.SideChain/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// SnapshotVersion is the version of the snapshot file format.
+const SnapshotVersion = 1
+
+// snapshotBatchSize is the number of records committed at once on import.
+const snapshotBatchSize = 10000
+
+// MaxSnapshotRollbackBlocks is the maximum depth of the block a snapshot is
+// exported at, the blocks above it are rolled back and the blocks in the prune
+// depth are never pruned.
+const MaxSnapshotRollbackBlocks = MinPruneDepth
+
+// SnapshotFile returns the name of the file a snapshot at the height is
+// exported to.
+func SnapshotFile(height uint32) string {
+	return fmt.Sprintf("snapshot_%d.dat", height)
+}
+
+// A snapshot has the headers of the main chain, the indexes of the store and
+// the transactions they reference.
+func isSnapshotPrefix(prefix DataEntryPrefix) bool {
+	switch prefix {
+	case DATA_BlockHash, DATA_Header, DATA_Transaction:
+		return true
+	}
+	for _, index := range indexPrefixes {
+		if index.prefix == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// A snapshot is the version, the height and hash of the best block, and the
+// store records as var bytes key and value pairs ended by an empty key. The
+// sha256 hash of the content before it is appended, it only detects a corrupt
+// file and does not authenticate the snapshot, which must be from a trusted
+// source.
+func writeSnapshotRecord(w io.Writer, key, value []byte) error {
+	if err := WriteVarBytes(w, key); err != nil {
+		return err
+	}
+	return WriteVarBytes(w, value)
+}
+
+// ExportSnapshot writes the headers and indexes of the store at the height to
+// w. It reads a snapshot of the store, the blocks above the height are rolled
+// back in memory, so the height must be in MaxSnapshotRollbackBlocks of the
+// best block.
+func (c *ChainStore) ExportSnapshot(w io.Writer, height uint32) error {
+	view, err := c.newReadOnlyView()
+	if err != nil {
+		return err
+	}
+	defer view.IStore.Close()
+
+	best := view.currentBlockHeight
+	if height > best {
+		return fmt.Errorf("[Snapshot], height %d is above the best block %d.", height, best)
+	}
+	if best-height > MaxSnapshotRollbackBlocks {
+		return fmt.Errorf("[Snapshot], height %d is more than %d blocks below the best block.",
+			height, MaxSnapshotRollbackBlocks)
+	}
+	if pruneHeight, ok := view.GetPruneHeight(); ok && height < pruneHeight {
+		return fmt.Errorf("[Snapshot], blocks to height %d are pruned.", pruneHeight)
+	}
+
+	view.IStore = newOverlayStore(view.IStore)
+	for h := best; h > height; h-- {
+		hash, err := view.GetBlockHash(h)
+		if err != nil {
+			return err
+		}
+		block, err := view.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+		if err := view.rollbackBlock(block); err != nil {
+			return err
+		}
+	}
+	return view.writeSnapshot(w, height)
+}
+
+// rollbackBlock rolls back the best block without updating the chain, it's
+// used on a view of the store.
+func (c *ChainStore) rollbackBlock(b *core.Block) error {
+	c.NewBatch()
+	if err := c.RollbackTrimmedBlock(b); err != nil {
+		return err
+	}
+	if err := c.RollbackBlockHash(b); err != nil {
+		return err
+	}
+	if err := c.RollbackTransactions(b); err != nil {
+		return err
+	}
+	if err := c.RollbackUnspendUTXOs(b); err != nil {
+		return err
+	}
+	if err := c.RollbackUnspend(b); err != nil {
+		return err
+	}
+	if err := c.RollbackCurrentBlock(b); err != nil {
+		return err
+	}
+	if err := c.BatchCommit(); err != nil {
+		return err
+	}
+	c.currentBlockHeight = b.Header.Height - 1
+	return nil
+}
+
+func (c *ChainStore) writeSnapshot(sw io.Writer, height uint32) error {
+	hash, err := c.GetBlockHash(height)
+	if err != nil {
+		return err
+	}
+	log.Infof("[Snapshot] export snapshot at height %d", height)
+
+	hasher := sha256.New()
+	w := io.MultiWriter(sw, hasher)
+	if err := WriteUint32(w, SnapshotVersion); err != nil {
+		return err
+	}
+	if err := WriteUint32(w, height); err != nil {
+		return err
+	}
+	if err := hash.Serialize(w); err != nil {
+		return err
+	}
+
+	// headers of the main chain to load block nodes and to sync from
+	for h := uint32(0); h <= height; h++ {
+		key := new(bytes.Buffer)
+		key.WriteByte(byte(DATA_BlockHash))
+		WriteUint32(key, h)
+		blockHash, err := c.Get(key.Bytes())
+		if err != nil {
+			return err
+		}
+		if err := writeSnapshotRecord(w, key.Bytes(), blockHash); err != nil {
+			return err
+		}
+
+		headerKey := append([]byte{byte(DATA_Header)}, blockHash...)
+		header, err := c.Get(headerKey)
+		if err != nil {
+			return err
+		}
+		if err := writeSnapshotRecord(w, headerKey, header); err != nil {
+			return err
+		}
+	}
+
+	// transactions with unspent outputs are required to spend them, the
+	// transactions referenced by the identification index may be pruned.
+	unspentTxs := make(map[Uint256]bool)
+	for _, index := range indexPrefixes {
+		iter := c.NewIterator([]byte{byte(index.prefix)})
+		for iter.Next() {
+			key, value := iter.Key(), iter.Value()
+			if err := writeSnapshotRecord(w, key, value); err != nil {
+				iter.Release()
+				return err
+			}
+
+			var txHash Uint256
+			switch index.prefix {
+			case IX_Unspent:
+				txHash.Deserialize(bytes.NewReader(key[1:]))
+				unspentTxs[txHash] = true
+			case IX_IDENTIFICATION, IX_ID_History:
+				txHash.Deserialize(bytes.NewReader(value))
+				if _, ok := unspentTxs[txHash]; !ok {
+					unspentTxs[txHash] = false
+				}
+			}
+		}
+		iter.Release()
+	}
+
+	txHashes := make([]Uint256, 0, len(unspentTxs))
+	for txHash := range unspentTxs {
+		txHashes = append(txHashes, txHash)
+	}
+	sort.Slice(txHashes, func(i, j int) bool {
+		return bytes.Compare(txHashes[i].Bytes(), txHashes[j].Bytes()) < 0
+	})
+	for _, txHash := range txHashes {
+		key := append([]byte{byte(DATA_Transaction)}, txHash.Bytes()...)
+		value, err := c.Get(key)
+		if err != nil {
+			if unspentTxs[txHash] {
+				return err
+			}
+			continue
+		}
+		if err := writeSnapshotRecord(w, key, value); err != nil {
+			return err
+		}
+	}
+
+	if err := WriteVarBytes(w, nil); err != nil {
+		return err
+	}
+	_, err = sw.Write(hasher.Sum(nil))
+	return err
+}
+
+// ErrChainStoreNotEmpty is returned when a snapshot is imported into a chain
+// store with other blocks.
+var ErrChainStoreNotEmpty = errors.New("[Snapshot], chain store is not empty.")
+
+// ImportSnapshot loads a snapshot into a fresh chain store before the chain is
+// initialized, and returns the height of it. The blocks to the snapshot height
+// have no transactions and are treated as pruned. The import is skipped if the
+// chain store already has the best block of the snapshot.
+func (c *ChainStore) ImportSnapshot(r io.Reader) (uint32, error) {
+	hasher := sha256.New()
+	tr := io.TeeReader(r, hasher)
+	version, err := ReadUint32(tr)
+	if err != nil {
+		return 0, err
+	}
+	if version != SnapshotVersion {
+		return 0, errors.New("[Snapshot], unsupported snapshot version.")
+	}
+	height, err := ReadUint32(tr)
+	if err != nil {
+		return 0, err
+	}
+	var hash Uint256
+	if err := hash.Deserialize(tr); err != nil {
+		return 0, err
+	}
+
+	// the snapshot imported before is skipped
+	versionKey := []byte{byte(CFG_Version)}
+	if _, err := c.Get(versionKey); err == nil {
+		if best, err := c.GetBlockHash(height); err == nil && best.IsEqual(hash) {
+			log.Infof("[Snapshot] snapshot at height %d is already imported", height)
+			return height, nil
+		}
+		return 0, ErrChainStoreNotEmpty
+	}
+	log.Infof("[Snapshot] import snapshot at height %d", height)
+
+	bestKey := new(bytes.Buffer)
+	bestKey.WriteByte(byte(DATA_BlockHash))
+	WriteUint32(bestKey, height)
+	foundBest := false
+
+	// The records are committed in batches, the version is written after the
+	// content hash is checked, so an incomplete import is removed when the
+	// chain store is initialized.
+	c.NewBatch()
+	for count := 1; ; count++ {
+		key, err := ReadVarBytes(tr)
+		if err != nil {
+			return 0, err
+		}
+		if len(key) == 0 {
+			break
+		}
+		value, err := ReadVarBytes(tr)
+		if err != nil {
+			return 0, err
+		}
+		if !isSnapshotPrefix(DataEntryPrefix(key[0])) {
+			return 0, errors.New("[Snapshot], invalid snapshot record.")
+		}
+		if bytes.Equal(key, bestKey.Bytes()) {
+			foundBest = bytes.Equal(value, hash.Bytes())
+		}
+		c.BatchPut(key, value)
+
+		if count%snapshotBatchSize == 0 {
+			if err := c.BatchCommit(); err != nil {
+				return 0, err
+			}
+			c.NewBatch()
+		}
+	}
+
+	sum := hasher.Sum(nil)
+	var expected [sha256.Size]byte
+	if _, err := io.ReadFull(r, expected[:]); err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(sum, expected[:]) {
+		return 0, errors.New("[Snapshot], snapshot hash mismatch.")
+	}
+	if !foundBest {
+		return 0, errors.New("[Snapshot], snapshot best block not found.")
+	}
+
+	current := new(bytes.Buffer)
+	hash.Serialize(current)
+	WriteUint32(current, height)
+	c.BatchPut([]byte{byte(SYS_CurrentBlock)}, current.Bytes())
+	var pruneHeight [4]byte
+	binary.BigEndian.PutUint32(pruneHeight[:], height)
+	c.BatchPut([]byte{byte(SYS_PruneHeight)}, pruneHeight[:])
+	c.BatchPut([]byte{byte(SYS_WithdrawalIndex)}, []byte{1})
+	if err := c.BatchCommit(); err != nil {
+		return 0, err
+	}
+	if err := c.Put(versionKey, []byte{0x01}); err != nil {
+		return 0, err
+	}
+
+	log.Infof("[Snapshot] snapshot at height %d is imported", height)
+	return height, nil
+}
@@ -11,6 +11,14 @@ type IIterator interface {
 	Release()
 }
 
+// IStoreSnapshot is a read only state of the store at the time it's taken, it
+// must be released after use.
+type IStoreSnapshot interface {
+	Get(key []byte) ([]byte, error)
+	NewIterator(prefix []byte) IIterator
+	Release()
+}
+
 type IStore interface {
 	Put(key []byte, value []byte) error
 	Get(key []byte) ([]byte, error)
@@ -21,4 +29,5 @@ type IStore interface {
 	BatchCommit() error
 	Close() error
 	NewIterator(prefix []byte) IIterator
+	NewSnapshot() (IStoreSnapshot, error)
 }
@@ -0,0 +1,223 @@
+package blockchain
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"sort"
+
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+var errReadOnlyStore = errors.New("[ChainStore], the store is read only.")
+
+// readOnlyStore is an IStore reading from a snapshot of the store, writes
+// fail and Close releases the snapshot.
+type readOnlyStore struct {
+	IStoreSnapshot
+}
+
+func (s *readOnlyStore) Put(key []byte, value []byte) error {
+	return errReadOnlyStore
+}
+
+func (s *readOnlyStore) Delete(key []byte) error {
+	return errReadOnlyStore
+}
+
+func (s *readOnlyStore) NewBatch() {}
+
+func (s *readOnlyStore) BatchPut(key []byte, value []byte) {}
+
+func (s *readOnlyStore) BatchDelete(key []byte) {}
+
+func (s *readOnlyStore) BatchCommit() error {
+	return errReadOnlyStore
+}
+
+func (s *readOnlyStore) Close() error {
+	s.Release()
+	return nil
+}
+
+func (s *readOnlyStore) NewSnapshot() (IStoreSnapshot, error) {
+	return nil, errReadOnlyStore
+}
+
+// newReadOnlyView returns a chain store reading from a snapshot of the store
+// at the current block, it reads a consistent state while blocks are saved so
+// long scans do not run in the task loop. The view must be released by
+// closing its IStore.
+func (c *ChainStore) newReadOnlyView() (*ChainStore, error) {
+	snapshot, err := c.IStore.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	view := &ChainStore{
+		IStore:      &readOnlyStore{snapshot},
+		headerIndex: map[uint32]Uint256{},
+		headerCache: map[Uint256]*core.Header{},
+		headerIdx:   list.New(),
+		pruneDepth:  c.pruneDepth,
+	}
+
+	// value: current block hash || height
+	data, err := view.Get([]byte{byte(SYS_CurrentBlock)})
+	if err != nil {
+		snapshot.Release()
+		return nil, errors.New("[ChainStore], current block not found.")
+	}
+	r := bytes.NewReader(data)
+	var hash Uint256
+	if err := hash.Deserialize(r); err != nil {
+		snapshot.Release()
+		return nil, err
+	}
+	if view.currentBlockHeight, err = ReadUint32(r); err != nil {
+		snapshot.Release()
+		return nil, err
+	}
+	return view, nil
+}
+
+// overlayStore is an IStore keeping the writes in memory over a read only
+// store, blocks are rolled back on it without changing the store.
+type overlayStore struct {
+	IStore
+	batch   map[string][]byte
+	changes map[string][]byte // the value of a deleted key is nil
+}
+
+func newOverlayStore(store IStore) *overlayStore {
+	return &overlayStore{IStore: store, changes: make(map[string][]byte)}
+}
+
+func (s *overlayStore) Get(key []byte) ([]byte, error) {
+	if value, ok := s.changes[string(key)]; ok {
+		if value == nil {
+			return nil, errors.New("[ChainStore], key not found.")
+		}
+		return value, nil
+	}
+	return s.IStore.Get(key)
+}
+
+func (s *overlayStore) Put(key []byte, value []byte) error {
+	s.changes[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+func (s *overlayStore) Delete(key []byte) error {
+	s.changes[string(key)] = nil
+	return nil
+}
+
+func (s *overlayStore) NewBatch() {
+	s.batch = make(map[string][]byte)
+}
+
+func (s *overlayStore) BatchPut(key []byte, value []byte) {
+	s.batch[string(key)] = append([]byte{}, value...)
+}
+
+func (s *overlayStore) BatchDelete(key []byte) {
+	s.batch[string(key)] = nil
+}
+
+func (s *overlayStore) BatchCommit() error {
+	for key, value := range s.batch {
+		s.changes[key] = value
+	}
+	s.batch = nil
+	return nil
+}
+
+func (s *overlayStore) NewIterator(prefix []byte) IIterator {
+	var keys []string
+	for key := range s.changes {
+		if bytes.HasPrefix([]byte(key), prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return &overlayIterator{
+		base:    s.IStore.NewIterator(prefix),
+		keys:    keys,
+		changes: s.changes,
+	}
+}
+
+// overlayIterator iterates the keys of the read only store merged with the
+// changed keys in order, only Next is supported.
+type overlayIterator struct {
+	base    IIterator
+	baseOk  bool
+	started bool
+	keys    []string // the changed keys not iterated
+	changes map[string][]byte
+	key     []byte
+	value   []byte
+}
+
+// nextBase moves the base iterator to the next key which is not changed.
+func (it *overlayIterator) nextBase() {
+	for it.baseOk = it.base.Next(); it.baseOk; it.baseOk = it.base.Next() {
+		if _, ok := it.changes[string(it.base.Key())]; !ok {
+			return
+		}
+	}
+}
+
+func (it *overlayIterator) Next() bool {
+	if !it.started {
+		it.started = true
+		it.nextBase()
+	}
+	for {
+		if it.baseOk && (len(it.keys) == 0 || bytes.Compare(it.base.Key(), []byte(it.keys[0])) < 0) {
+			it.key = append([]byte{}, it.base.Key()...)
+			it.value = append([]byte{}, it.base.Value()...)
+			it.nextBase()
+			return true
+		}
+		if len(it.keys) == 0 {
+			return false
+		}
+		key := it.keys[0]
+		it.keys = it.keys[1:]
+		if value := it.changes[key]; value != nil {
+			it.key, it.value = []byte(key), value
+			return true
+		}
+	}
+}
+
+func (it *overlayIterator) Prev() bool {
+	return false
+}
+
+func (it *overlayIterator) First() bool {
+	return false
+}
+
+func (it *overlayIterator) Last() bool {
+	return false
+}
+
+func (it *overlayIterator) Seek(key []byte) bool {
+	return false
+}
+
+func (it *overlayIterator) Key() []byte {
+	return it.key
+}
+
+func (it *overlayIterator) Value() []byte {
+	return it.value
+}
+
+func (it *overlayIterator) Release() {
+	it.base.Release()
+}
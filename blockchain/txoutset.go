@@ -0,0 +1,103 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"sort"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// TxOutSetInfo is the statistics of the unspent outputs at the best block.
+type TxOutSetInfo struct {
+	Height    uint32
+	BestBlock Uint256
+	// Transactions is the number of transactions with unspent outputs, and
+	// TxOuts is the number of unspent outputs.
+	Transactions uint64
+	TxOuts       uint64
+	// Supply is the total value of unspent outputs of each asset, outputs
+	// to the destroy address are not counted.
+	Supply map[Uint256]Fixed64
+	// SetHash is the sha256 hash of the unspent outputs ordered by
+	// transaction hash and output index.
+	SetHash Uint256
+}
+
+// GetTxOutSetInfo returns the statistics of the unspent outputs, it scans a
+// snapshot of the store while blocks are saved.
+func (c *ChainStore) GetTxOutSetInfo() (*TxOutSetInfo, error) {
+	view, err := c.newReadOnlyView()
+	if err != nil {
+		return nil, err
+	}
+	defer view.IStore.Close()
+
+	info := new(TxOutSetInfo)
+	if err := view.txOutSetInfo(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (c *ChainStore) txOutSetInfo(info *TxOutSetInfo) error {
+	info.Height = c.GetHeight()
+	hash, err := c.GetBlockHash(info.Height)
+	if err != nil {
+		return err
+	}
+	info.BestBlock = hash
+	info.Supply = make(map[Uint256]Fixed64)
+	for assetID := range c.GetAssets() {
+		info.Supply[assetID] = 0
+	}
+
+	// each unspent output is hashed as the transaction hash, output index,
+	// height and the serialized output.
+	hasher := sha256.New()
+	iter := c.NewIterator([]byte{byte(IX_Unspent)})
+	defer iter.Release()
+	for iter.Next() {
+		var txHash Uint256
+		if err := txHash.Deserialize(bytes.NewReader(iter.Key()[1:])); err != nil {
+			return err
+		}
+		indexes, err := GetUint16Array(iter.Value())
+		if err != nil {
+			return err
+		}
+		if len(indexes) == 0 {
+			continue
+		}
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+		txn, height, err := c.GetTransaction(txHash)
+		if err != nil {
+			return err
+		}
+		info.Transactions++
+		for _, index := range indexes {
+			if int(index) >= len(txn.Outputs) {
+				return errors.New("[TxOutSetInfo], unspent output index out of range.")
+			}
+			output := txn.Outputs[index]
+			info.TxOuts++
+			if !output.ProgramHash.IsEqual(Uint168{}) {
+				info.Supply[output.AssetID] += output.Value
+			}
+
+			buf := new(bytes.Buffer)
+			txHash.Serialize(buf)
+			WriteUint16(buf, index)
+			WriteUint32(buf, height)
+			if err := output.Serialize(buf); err != nil {
+				return err
+			}
+			hasher.Write(buf.Bytes())
+		}
+	}
+
+	copy(info.SetHash[:], hasher.Sum(nil))
+	return nil
+}
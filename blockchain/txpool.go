@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"sync"
 
-	"github.com/elastos/Elastos.ELA.SideChain/config"
 	"github.com/elastos/Elastos.ELA.SideChain/core"
 	. "github.com/elastos/Elastos.ELA.SideChain/errors"
 	"github.com/elastos/Elastos.ELA.SideChain/events"
@@ -22,9 +21,14 @@ type TxPool struct {
 	//issueSummary  map[Uint256]Fixed64           // transaction which pass the verify will summary the amout to this map
 	inputUTXOList   map[string]*core.Transaction  // transaction which pass the verify will add the UTXO to this map
 	mainchainTxList map[Uint256]*core.Transaction // mainchain tx pool
+	withdrawalList  map[Uint256]*core.Transaction // withdrawals processed by withdraw tx in pool
+	identityList    map[string]*core.Transaction  // IDs revoked or rotated by tx in pool
+	events          *events.Event                 // blockchain events
 }
 
-func (pool *TxPool) Init() {
+// Init initializes the pool, the pool sends EventNewTransactionPutInPool and
+// handles EventMainChainRollback by bcEvents.
+func (pool *TxPool) Init(bcEvents *events.Event) {
 	pool.Lock()
 	defer pool.Unlock()
 	pool.txnCnt = 0
@@ -32,6 +36,10 @@ func (pool *TxPool) Init() {
 	//pool.issueSummary = make(map[Uint256]Fixed64)
 	pool.txnList = make(map[Uint256]*core.Transaction)
 	pool.mainchainTxList = make(map[Uint256]*core.Transaction)
+	pool.withdrawalList = make(map[Uint256]*core.Transaction)
+	pool.identityList = make(map[string]*core.Transaction)
+	pool.events = bcEvents
+	pool.events.Subscribe(events.EventMainChainRollback, pool.mainChainRollback)
 }
 
 //append transaction to txnpool when check ok.
@@ -52,7 +60,7 @@ func (pool *TxPool) AppendToTxnPool(txn *core.Transaction) ErrCode {
 		return errCode
 	}
 
-	txn.Fee = GetTxFee(txn, DefaultLedger.Blockchain.AssetID)
+	txn.Fee = GetTxFee(txn, DefaultLedger.Blockchain.AssetID, DefaultLedger.Store.GetHeight()+1)
 	buf := new(bytes.Buffer)
 	txn.Serialize(buf)
 	txn.FeePerKB = txn.Fee * 1000 / Fixed64(len(buf.Bytes()))
@@ -87,6 +95,8 @@ func (pool *TxPool) CleanSubmittedTransactions(block *core.Block) error {
 	pool.cleanTransactionList(block.Transactions)
 	pool.cleanUTXOList(block.Transactions)
 	pool.cleanMainchainTx(block.Transactions)
+	pool.cleanWithdrawals(block.Transactions)
+	pool.cleanIdentities(block.Transactions)
 	return nil
 }
 
@@ -107,12 +117,31 @@ func (pool *TxPool) verifyTransactionWithTxnPool(txn *core.Transaction) ErrCode
 		}
 	}
 
+	if txn.IsWithdrawFromSideChainTx() {
+		// check if the withdrawals are processed by another withdraw tx in pool
+		if err := pool.verifyDuplicateWithdrawal(txn); err != nil {
+			log.Warn(err)
+			return ErrWithdrawal
+		}
+	}
+
+	if txn.IsRevokeIdentificationTx() || txn.IsRotateIdentificationKeyTx() {
+		// check if the ID is revoked or rotated by another tx in pool
+		if err := pool.verifyIdentityConflict(txn); err != nil {
+			log.Warn(err)
+			return ErrIdentification
+		}
+	}
+
 	// check if the transaction includes double spent UTXO inputs
 	if err := pool.verifyDoubleSpend(txn); err != nil {
 		log.Info(err)
 		return ErrDoubleSpend
 	}
 
+	// the transaction is accepted, register it to the conflict maps
+	pool.addWithdrawals(txn)
+	pool.addIdentity(txn)
 	return Success
 }
 
@@ -120,6 +149,8 @@ func (pool *TxPool) verifyTransactionWithTxnPool(txn *core.Transaction) ErrCode
 func (pool *TxPool) removeTransaction(txn *core.Transaction) {
 	//1.remove from txnList
 	pool.delFromTxList(txn.Hash())
+	pool.delWithdrawals(txn)
+	pool.delIdentity(txn)
 	//2.remove from UTXO list map
 	result, err := DefaultLedger.Store.GetTxReference(txn)
 	if err != nil {
@@ -162,6 +193,15 @@ func (pool *TxPool) IsDuplicateMainchainTx(mainchainTxHash Uint256) bool {
 	return false
 }
 
+// GetMainchainTxInPool returns the recharge transaction in pool which deposits
+// the main chain transaction.
+func (pool *TxPool) GetMainchainTxInPool(mainchainTxHash Uint256) (*core.Transaction, bool) {
+	pool.RLock()
+	defer pool.RUnlock()
+	txn, ok := pool.mainchainTxList[mainchainTxHash]
+	return txn, ok
+}
+
 //check and add to mainchain tx pool
 func (pool *TxPool) verifyDuplicateMainchainTx(txn *core.Transaction) error {
 	rechargePayload, ok := txn.Payload.(*core.PayloadRechargeToSideChain)
@@ -183,6 +223,42 @@ func (pool *TxPool) verifyDuplicateMainchainTx(txn *core.Transaction) error {
 	return nil
 }
 
+//check if the withdrawals are processed by another withdraw tx in pool
+func (pool *TxPool) verifyDuplicateWithdrawal(txn *core.Transaction) error {
+	withdrawPayload, ok := txn.Payload.(*core.PayloadWithdrawFromSideChain)
+	if !ok {
+		return errors.New("convert the payload of withdraw tx failed")
+	}
+	_, mainchainPayload, err := withdrawPayload.GetMainchainPayload()
+	if err != nil {
+		return err
+	}
+
+	pool.RLock()
+	defer pool.RUnlock()
+	for _, hash := range mainchainPayload.SideChainTransactionHashes {
+		if _, exist := pool.withdrawalList[hash]; exist {
+			return errors.New("duplicate withdrawal detected")
+		}
+	}
+	return nil
+}
+
+//check if the ID of revoke or rotate key tx is changed by another tx in pool
+func (pool *TxPool) verifyIdentityConflict(txn *core.Transaction) error {
+	id, ok := getIdentificationUpdateID(txn)
+	if !ok {
+		return errors.New("convert the payload of identification tx failed")
+	}
+
+	pool.RLock()
+	defer pool.RUnlock()
+	if _, exist := pool.identityList[id]; exist {
+		return errors.New("conflict identification tx detected, ID: " + id)
+	}
+	return nil
+}
+
 //clean txnpool utxo map
 func (pool *TxPool) cleanUTXOList(txs []*core.Transaction) {
 	for _, txn := range txs {
@@ -239,6 +315,31 @@ func (pool *TxPool) cleanMainchainTx(txs []*core.Transaction) {
 	}
 }
 
+// clean the withdrawal pool, withdraw txs in pool which process the same
+// withdrawals as the block are removed.
+func (pool *TxPool) cleanWithdrawals(txs []*core.Transaction) {
+	for _, txn := range txs {
+		if !txn.IsWithdrawFromSideChainTx() {
+			continue
+		}
+		withdrawPayload := txn.Payload.(*core.PayloadWithdrawFromSideChain)
+		_, mainchainPayload, err := withdrawPayload.GetMainchainPayload()
+		if err != nil {
+			log.Error("get payload failed when clean withdrawals:", txn.Hash())
+			continue
+		}
+		for _, hash := range mainchainPayload.SideChainTransactionHashes {
+			pool.RLock()
+			poolTx := pool.withdrawalList[hash]
+			pool.RUnlock()
+			if poolTx != nil {
+				pool.delFromTxList(poolTx.Hash())
+				pool.delWithdrawals(poolTx)
+			}
+		}
+	}
+}
+
 func (pool *TxPool) addToTxList(txn *core.Transaction) bool {
 	pool.Lock()
 	defer pool.Unlock()
@@ -247,7 +348,9 @@ func (pool *TxPool) addToTxList(txn *core.Transaction) bool {
 		return false
 	}
 	pool.txnList[txnHash] = txn
-	DefaultLedger.Blockchain.BCEvents.Notify(events.EventNewTransactionPutInPool, txn)
+	if pool.events != nil {
+		pool.events.Notify(events.EventNewTransactionPutInPool, txn)
+	}
 	return true
 }
 
@@ -308,6 +411,27 @@ func (pool *TxPool) delInputUTXOList(input *core.Input) bool {
 	return true
 }
 
+// clean the identity pool, revoke or rotate key txs in pool which change the
+// same IDs as the block are removed.
+func (pool *TxPool) cleanIdentities(txs []*core.Transaction) {
+	for _, txn := range txs {
+		id, ok := getIdentificationUpdateID(txn)
+		if !ok {
+			continue
+		}
+		pool.RLock()
+		poolTx := pool.identityList[id]
+		pool.RUnlock()
+		if poolTx != nil {
+			pool.delFromTxList(poolTx.Hash())
+			for _, input := range poolTx.Inputs {
+				pool.delInputUTXOList(input)
+			}
+			pool.delIdentity(poolTx)
+		}
+	}
+}
+
 func (pool *TxPool) addMainchainTx(txn *core.Transaction) {
 	pool.Lock()
 	defer pool.Unlock()
@@ -330,6 +454,62 @@ func (pool *TxPool) delMainchainTx(hash Uint256) bool {
 	return true
 }
 
+func (pool *TxPool) addWithdrawals(txn *core.Transaction) {
+	withdrawPayload, ok := txn.Payload.(*core.PayloadWithdrawFromSideChain)
+	if !ok {
+		return
+	}
+	_, mainchainPayload, err := withdrawPayload.GetMainchainPayload()
+	if err != nil {
+		return
+	}
+	pool.Lock()
+	defer pool.Unlock()
+	for _, hash := range mainchainPayload.SideChainTransactionHashes {
+		pool.withdrawalList[hash] = txn
+	}
+}
+
+func (pool *TxPool) delWithdrawals(txn *core.Transaction) {
+	withdrawPayload, ok := txn.Payload.(*core.PayloadWithdrawFromSideChain)
+	if !ok {
+		return
+	}
+	pool.Lock()
+	defer pool.Unlock()
+	_, mainchainPayload, err := withdrawPayload.GetMainchainPayload()
+	if err != nil {
+		return
+	}
+	for _, hash := range mainchainPayload.SideChainTransactionHashes {
+		if pool.withdrawalList[hash] == txn {
+			delete(pool.withdrawalList, hash)
+		}
+	}
+}
+
+func (pool *TxPool) addIdentity(txn *core.Transaction) {
+	id, ok := getIdentificationUpdateID(txn)
+	if !ok {
+		return
+	}
+	pool.Lock()
+	defer pool.Unlock()
+	pool.identityList[id] = txn
+}
+
+func (pool *TxPool) delIdentity(txn *core.Transaction) {
+	id, ok := getIdentificationUpdateID(txn)
+	if !ok {
+		return
+	}
+	pool.Lock()
+	defer pool.Unlock()
+	if pool.identityList[id] == txn {
+		delete(pool.identityList, id)
+	}
+}
+
 func (pool *TxPool) MaybeAcceptTransaction(txn *core.Transaction) error {
 	txHash := txn.Hash()
 
@@ -369,8 +549,10 @@ func (pool *TxPool) RemoveTransaction(txn *core.Transaction) {
 	}
 }
 
-func GetTxFee(tx *core.Transaction, assetId Uint256) Fixed64 {
-	feeMap, err := GetTxFeeMap(tx)
+// GetTxFee returns the fee of the asset paid by the transaction, height is the
+// height of block the transaction is in.
+func GetTxFee(tx *core.Transaction, assetId Uint256, height uint32) Fixed64 {
+	feeMap, err := GetTxFeeMap(tx, height)
 	if err != nil {
 		return 0
 	}
@@ -378,7 +560,7 @@ func GetTxFee(tx *core.Transaction, assetId Uint256) Fixed64 {
 	return feeMap[assetId]
 }
 
-func GetTxFeeMap(tx *core.Transaction) (map[Uint256]Fixed64, error) {
+func GetTxFeeMap(tx *core.Transaction, height uint32) (map[Uint256]Fixed64, error) {
 	feeMap := make(map[Uint256]Fixed64)
 
 	if tx.IsRechargeToSideChainTx() {
@@ -399,12 +581,16 @@ func GetTxFeeMap(tx *core.Transaction) (map[Uint256]Fixed64, error) {
 				}
 				if targetAddress == crossChainPayload.CrossChainAddresses[i] {
 					mcAmount := mainChainTransaction.Outputs[crossChainPayload.OutputIndexes[i]].Value
+					scAmount, err := ExchangeAmount(mcAmount, height)
+					if err != nil {
+						return nil, err
+					}
 
 					amount, ok := feeMap[v.AssetID]
 					if ok {
-						feeMap[v.AssetID] = amount + Fixed64(float64(mcAmount)*config.Parameters.ExchangeRate) - v.Value
+						feeMap[v.AssetID] = amount + scAmount - v.Value
 					} else {
-						feeMap[v.AssetID] = Fixed64(float64(mcAmount)*config.Parameters.ExchangeRate) - v.Value
+						feeMap[v.AssetID] = scAmount - v.Value
 					}
 				}
 			}
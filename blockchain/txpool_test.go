@@ -0,0 +1,98 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	ela "github.com/elastos/Elastos.ELA/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxPoolWithdrawals(t *testing.T) {
+	pool := &TxPool{
+		txnList:        make(map[common.Uint256]*core.Transaction),
+		withdrawalList: make(map[common.Uint256]*core.Transaction),
+	}
+	newWithdrawTx := func(blockHeight uint32, hashes ...common.Uint256) *core.Transaction {
+		mainchainTx := &ela.Transaction{
+			TxType: ela.WithdrawFromSideChain,
+			Payload: &ela.PayloadWithdrawFromSideChain{
+				BlockHeight:                blockHeight,
+				SideChainTransactionHashes: hashes,
+			},
+		}
+		buf := new(bytes.Buffer)
+		mainchainTx.Serialize(buf)
+		return &core.Transaction{
+			TxType:  core.WithdrawFromSideChain,
+			Payload: &core.PayloadWithdrawFromSideChain{MainChainTransaction: buf.Bytes()},
+		}
+	}
+	withdraw := newWithdrawTx(100, common.Uint256{1}, common.Uint256{2})
+	duplicate := newWithdrawTx(101, common.Uint256{2})
+
+	// the withdrawals are not registered by the check
+	assert.NoError(t, pool.verifyDuplicateWithdrawal(withdraw))
+	assert.Equal(t, 0, len(pool.withdrawalList))
+
+	pool.addWithdrawals(withdraw)
+	pool.txnList[withdraw.Hash()] = withdraw
+	assert.EqualError(t, pool.verifyDuplicateWithdrawal(duplicate), "duplicate withdrawal detected")
+	assert.NoError(t, pool.verifyDuplicateWithdrawal(newWithdrawTx(102, common.Uint256{3})))
+
+	// removing the tx releases its withdrawals only
+	pool.delWithdrawals(duplicate)
+	assert.Equal(t, 2, len(pool.withdrawalList))
+	pool.delWithdrawals(withdraw)
+	assert.Equal(t, 0, len(pool.withdrawalList))
+	assert.NoError(t, pool.verifyDuplicateWithdrawal(duplicate))
+
+	// a withdraw tx in block removes the tx in pool processing the same withdrawals
+	pool.addWithdrawals(withdraw)
+	pool.cleanWithdrawals([]*core.Transaction{duplicate})
+	assert.Nil(t, pool.GetTransaction(withdraw.Hash()))
+	assert.Equal(t, 0, len(pool.withdrawalList))
+}
+
+func TestTxPoolIdentityConflict(t *testing.T) {
+	pool := &TxPool{
+		txnList:       make(map[common.Uint256]*core.Transaction),
+		inputUTXOList: make(map[string]*core.Transaction),
+		identityList:  make(map[string]*core.Transaction),
+	}
+	id := "ij8rfb6A4Ri7c5CRE1nDVdVCUMuUxkk2c6"
+	revoke := &core.Transaction{
+		TxType:  core.RevokeIdentification,
+		Payload: &core.PayloadRevokeIdentification{ID: id, Paths: []string{"kyc/person/phone"}},
+	}
+	rotate := &core.Transaction{
+		TxType:  core.RotateIdentificationKey,
+		Payload: &core.PayloadRotateIdentificationKey{ID: id, PublicKey: []byte{1}},
+	}
+	other := &core.Transaction{
+		TxType:  core.RotateIdentificationKey,
+		Payload: &core.PayloadRotateIdentificationKey{ID: "other", PublicKey: []byte{1}},
+	}
+
+	assert.NoError(t, pool.verifyIdentityConflict(revoke))
+	pool.addIdentity(revoke)
+	pool.txnList[revoke.Hash()] = revoke
+	assert.EqualError(t, pool.verifyIdentityConflict(rotate),
+		"conflict identification tx detected, ID: "+id)
+	assert.NoError(t, pool.verifyIdentityConflict(other))
+
+	// a conflicting tx in block removes the tx in pool
+	pool.cleanIdentities([]*core.Transaction{rotate})
+	assert.Nil(t, pool.GetTransaction(revoke.Hash()))
+	assert.NoError(t, pool.verifyIdentityConflict(rotate))
+
+	// other tx of the ID is not removed
+	pool.addIdentity(rotate)
+	pool.delIdentity(revoke)
+	assert.Error(t, pool.verifyIdentityConflict(revoke))
+	pool.delIdentity(rotate)
+	assert.NoError(t, pool.verifyIdentityConflict(revoke))
+}
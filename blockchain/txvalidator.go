@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
 
 	"github.com/elastos/Elastos.ELA.SideChain/common"
 	"github.com/elastos/Elastos.ELA.SideChain/config"
@@ -48,6 +49,9 @@ func CheckTransactionSanity(txn *core.Transaction) ErrCode {
 
 	if err := CheckTransactionPayload(txn); err != nil {
 		log.Warn("[CheckTransactionPayload],", err)
+		if txn.IsIdentificationTx() {
+			return ErrIdentification
+		}
 		return ErrTransactionPayload
 	}
 
@@ -61,29 +65,51 @@ func CheckTransactionSanity(txn *core.Transaction) ErrCode {
 
 // CheckTransactionContext verifys a transaction with history transaction in ledger
 func CheckTransactionContext(txn *core.Transaction) ErrCode {
+	return checkTransactionContext(txn, DefaultLedger.Store.GetHeight()+1, true)
+}
+
+// checkTransactionContext verifys the transaction in the block at height, the
+// signature is not verified if verifySignature is false, which is for blocks
+// verified by VerifyBlockSignatures or assumed valid.
+func checkTransactionContext(txn *core.Transaction, height uint32, verifySignature bool) ErrCode {
 	// check if duplicated with transaction in ledger
 	if exist := DefaultLedger.Store.IsTxHashDuplicate(txn.Hash()); exist {
 		log.Info("[CheckTransactionContext] duplicate transaction check faild.")
 		return ErrTxHashDuplicate
 	}
 
+	if err := CheckOutputActivation(txn, height); err != nil {
+		log.Warn("[CheckOutputActivation],", err)
+		return ErrInvalidOutput
+	}
+
 	if txn.IsCoinBaseTx() {
 		return Success
 	}
 
-	if err := CheckTransactionSignature(txn); err != nil {
-		log.Warn("[CheckTransactionSignature],", err)
-		return ErrTransactionSignature
+	if verifySignature {
+		if err := CheckTransactionSignature(txn, height); err != nil {
+			log.Warn("[CheckTransactionSignature],", err)
+			return ErrTransactionSignature
+		}
 	}
 
 	if txn.IsRechargeToSideChainTx() {
-		if err := CheckRechargeToSideChainTransaction(txn); err != nil {
+		if err := CheckRechargeToSideChainTransaction(txn, height); err != nil {
 			log.Warn("[CheckRechargeToSideChainTransaction],", err)
 			return ErrRechargeToSideChain
 		}
 		return Success
 	}
 
+	if txn.IsWithdrawFromSideChainTx() {
+		if err := CheckWithdrawFromSideChainTransaction(txn); err != nil {
+			log.Warn("[CheckWithdrawFromSideChainTransaction],", err)
+			return ErrWithdrawal
+		}
+		return Success
+	}
+
 	if txn.IsTransferCrossChainAssetTx() {
 		if err := CheckTransferCrossChainAssetTransaction(txn); err != nil {
 			log.Warn("[CheckTransferCrossChainAssetTransaction],", err)
@@ -91,6 +117,17 @@ func CheckTransactionContext(txn *core.Transaction) ErrCode {
 		}
 	}
 
+	if txn.IsIdentificationTx() {
+		if err := CheckIdentificationActivation(txn, height); err != nil {
+			log.Warn("[CheckIdentificationActivation],", err)
+			return ErrIdentification
+		}
+		if err := CheckIdentificationTransaction(txn); err != nil {
+			log.Warn("[CheckIdentificationTransaction],", err)
+			return ErrIdentification
+		}
+	}
+
 	// check double spent transaction
 	if DefaultLedger.IsDoubleSpend(txn) {
 		log.Info("[CheckTransactionContext] IsDoubleSpend check faild.")
@@ -102,7 +139,7 @@ func CheckTransactionContext(txn *core.Transaction) ErrCode {
 		return ErrUTXOLocked
 	}
 
-	if err := CheckTransactionBalance(txn); err != nil {
+	if err := CheckTransactionBalance(txn, height); err != nil {
 		log.Warn("[CheckTransactionBalance],", err)
 		return ErrTransactionBalance
 	}
@@ -154,6 +191,13 @@ func CheckTransactionInput(txn *core.Transaction) error {
 		return nil
 	}
 
+	if txn.IsWithdrawFromSideChainTx() {
+		if len(txn.Inputs) != 0 {
+			return errors.New("withdraw transaction must has no inputs")
+		}
+		return nil
+	}
+
 	if len(txn.Inputs) <= 0 {
 		return errors.New("transaction has no inputs")
 	}
@@ -199,6 +243,13 @@ func CheckTransactionOutput(txn *core.Transaction) error {
 		return nil
 	}
 
+	if txn.IsWithdrawFromSideChainTx() {
+		if len(txn.Outputs) != 0 {
+			return errors.New("withdraw transaction must has no outputs")
+		}
+		return nil
+	}
+
 	if len(txn.Outputs) < 1 {
 		return errors.New("transaction has no outputs")
 	}
@@ -224,12 +275,46 @@ func CheckOutputProgramHash(programHash Uint168) bool {
 		prefix == PrefixMultisig ||
 		prefix == PrefixCrossChain ||
 		prefix == PrefixRegisterId ||
+		prefix == common.PrefixSchnorr ||
 		programHash == empty {
 		return true
 	}
 	return false
 }
 
+// CheckOutputActivation checks the outputs of the transaction in the block at
+// height do not pay to program hashes activated after height.
+func CheckOutputActivation(txn *core.Transaction, height uint32) error {
+	if height >= config.Parameters.ChainParam.SchnorrHeight {
+		return nil
+	}
+	for _, output := range txn.Outputs {
+		if output.ProgramHash[0] == common.PrefixSchnorr {
+			return errors.New("schnorr output address is not activated")
+		}
+	}
+	return nil
+}
+
+// CheckIdentificationActivation checks the identification transaction in the
+// block at height by the rules activated at height, the payload of
+// RegisterIdentification is checked, and revoke and rotate key transactions
+// are valid from IdentificationHeight.
+func CheckIdentificationActivation(txn *core.Transaction, height uint32) error {
+	activated := height >= config.Parameters.ChainParam.IdentificationHeight
+	switch payload := txn.Payload.(type) {
+	case *core.PayloadRegisterIdentification:
+		if activated {
+			return CheckRegisterIdentificationPayload(txn, payload)
+		}
+	case *core.PayloadRevokeIdentification, *core.PayloadRotateIdentificationKey:
+		if !activated {
+			return errors.New("[Identification], transaction type is not activated.")
+		}
+	}
+	return nil
+}
+
 func CheckTransactionUTXOLock(txn *core.Transaction) error {
 	if txn.IsCoinBaseTx() {
 		return nil
@@ -290,13 +375,15 @@ func CheckAssetPrecision(txn *core.Transaction) error {
 	return nil
 }
 
-func CheckTransactionBalance(txn *core.Transaction) error {
+// CheckTransactionBalance checks the fee of the transaction in the block at
+// height.
+func CheckTransactionBalance(txn *core.Transaction, height uint32) error {
 	for _, v := range txn.Outputs {
 		if v.Value < Fixed64(0) {
 			return errors.New("Invalide transaction UTXO output.")
 		}
 	}
-	results, err := GetTxFeeMap(txn)
+	results, err := GetTxFeeMap(txn, height)
 	if err != nil {
 		return err
 	}
@@ -324,7 +411,7 @@ func CheckAttributeProgram(tx *core.Transaction) error {
 		if program.Parameter == nil {
 			return fmt.Errorf("invalid program parameter nil")
 		}
-		_, err := crypto.ToProgramHash(program.Code)
+		_, err := common.ToProgramHash(program.Code)
 		if err != nil {
 			return fmt.Errorf("invalid program code %x", program.Code)
 		}
@@ -332,8 +419,22 @@ func CheckAttributeProgram(tx *core.Transaction) error {
 	return nil
 }
 
-func CheckTransactionSignature(txn *core.Transaction) error {
-	return VerifySignature(txn)
+func CheckTransactionSignature(txn *core.Transaction, height uint32) error {
+	// recharge and withdraw transactions are verified by SPV module which
+	// depends on main chain state, so do not cache their results
+	if txn.IsRechargeToSideChainTx() || txn.IsWithdrawFromSideChainTx() {
+		return VerifySignature(txn, height)
+	}
+
+	flags := scriptFlags(height)
+	if SigCache.Exists(txn, flags) {
+		return nil
+	}
+	if err := VerifySignature(txn, height); err != nil {
+		return err
+	}
+	SigCache.Add(txn, flags)
+	return nil
 }
 
 func checkAmountPrecise(amount Fixed64, precision byte) bool {
@@ -353,15 +454,170 @@ func CheckTransactionPayload(txn *core.Transaction) error {
 	case *core.PayloadRecord:
 	case *core.PayloadCoinBase:
 	case *core.PayloadRechargeToSideChain:
+	case *core.PayloadWithdrawFromSideChain:
+		if err := CheckWithdrawFromSideChainPayload(pld); err != nil {
+			return err
+		}
 	case *core.PayloadTransferCrossChainAsset:
 	case *core.PayloadRegisterIdentification:
+	case *core.PayloadRevokeIdentification:
+		if err := CheckRevokeIdentificationPayload(txn, pld); err != nil {
+			return err
+		}
+	case *core.PayloadRotateIdentificationKey:
+		if err := CheckRotateIdentificationKeyPayload(txn, pld); err != nil {
+			return err
+		}
 	default:
 		return errors.New("[txValidator],invalidate transaction payload type.")
 	}
 	return nil
 }
 
-func CheckRechargeToSideChainTransaction(txn *core.Transaction) error {
+// CheckRegisterIdentificationPayload checks the payload contents, and the Sign
+// of payload is signed by the key of the ID program in transaction, the ID
+// program must be the current key of the ID which is checked in context.
+func CheckRegisterIdentificationPayload(txn *core.Transaction, payload *core.PayloadRegisterIdentification) error {
+	if err := payload.CheckContents(); err != nil {
+		return err
+	}
+	if err := checkIdentificationID(payload.ID); err != nil {
+		return err
+	}
+	programHash, ok := getIdentificationOutput(txn)
+	if !ok {
+		return errors.New("[RegisterIdentification], no output to the ID.")
+	}
+
+	var code []byte
+	for _, program := range txn.Programs {
+		hash, err := common.ToProgramHash(program.Code)
+		if err == nil && hash.IsEqual(*programHash) {
+			code = program.Code
+			break
+		}
+	}
+	// the ID program is the public key followed by CHECKREGID
+	if len(code) != crypto.PublicKeyScriptLength || code[len(code)-1] != REGISTERID {
+		return errors.New("[RegisterIdentification], ID program not found.")
+	}
+	publicKey, err := crypto.DecodePoint(code[1 : len(code)-1])
+	if err != nil {
+		return errors.New("[RegisterIdentification], invalid ID public key.")
+	}
+	if err := crypto.Verify(*publicKey, payload.SignData(), payload.Sign); err != nil {
+		return errors.New("[RegisterIdentification], invalid Sign.")
+	}
+	return nil
+}
+
+func CheckRevokeIdentificationPayload(txn *core.Transaction, payload *core.PayloadRevokeIdentification) error {
+	if err := checkIdentificationID(payload.ID); err != nil {
+		return err
+	}
+	if len(payload.Paths) == 0 {
+		return errors.New("[RevokeIdentification], paths is empty.")
+	}
+	paths := make(map[string]struct{}, len(payload.Paths))
+	for _, path := range payload.Paths {
+		if err := core.CheckIdentificationPath(path); err != nil {
+			return err
+		}
+		if _, ok := paths[path]; ok {
+			return errors.New("[RevokeIdentification], duplicated path " + path + ".")
+		}
+		paths[path] = struct{}{}
+	}
+	if _, ok := getIdentificationOutput(txn); !ok {
+		return errors.New("[RevokeIdentification], no output to the ID.")
+	}
+	return nil
+}
+
+func CheckRotateIdentificationKeyPayload(txn *core.Transaction, payload *core.PayloadRotateIdentificationKey) error {
+	if err := checkIdentificationID(payload.ID); err != nil {
+		return err
+	}
+	newProgramHash, err := GetIdentificationProgramHash(payload.PublicKey)
+	if err != nil {
+		return errors.New("[RotateIdentificationKey], invalid public key.")
+	}
+	programHash, ok := getIdentificationOutput(txn)
+	if !ok {
+		return errors.New("[RotateIdentificationKey], no output to the ID.")
+	}
+	if programHash.IsEqual(*newProgramHash) {
+		return errors.New("[RotateIdentificationKey], the new key is the current key.")
+	}
+	return nil
+}
+
+// CheckIdentificationTransaction checks the ID program signed the transaction
+// is the current key of the ID, and the revoked paths are registered.
+func CheckIdentificationTransaction(txn *core.Transaction) error {
+	var id string
+	switch payload := txn.Payload.(type) {
+	case *core.PayloadRegisterIdentification:
+		id = payload.ID
+	case *core.PayloadRevokeIdentification:
+		id = payload.ID
+	case *core.PayloadRotateIdentificationKey:
+		id = payload.ID
+	default:
+		return errors.New("[Identification], invalid identification payload type.")
+	}
+
+	programHash, ok := getIdentificationOutput(txn)
+	if !ok {
+		return errors.New("[Identification], no output to the ID.")
+	}
+	current, err := DefaultLedger.Store.GetIdentificationKey(id)
+	if err != nil {
+		return err
+	}
+	if !programHash.IsEqual(*current) {
+		return errors.New("[Identification], not signed by the current key of the ID.")
+	}
+
+	if payload, ok := txn.Payload.(*core.PayloadRevokeIdentification); ok {
+		for _, path := range payload.Paths {
+			versions, err := DefaultLedger.Store.GetIdentificationHistory(id, path)
+			if err != nil {
+				return err
+			}
+			if len(versions) == 0 {
+				return errors.New("[RevokeIdentification], path " + path + " is not registered.")
+			}
+			if versions[len(versions)-1].Revoked {
+				return errors.New("[RevokeIdentification], path " + path + " is already revoked.")
+			}
+		}
+	}
+	return nil
+}
+
+func checkIdentificationID(id string) error {
+	programHash, err := Uint168FromAddress(id)
+	if err != nil || programHash[0] != PrefixRegisterId {
+		return errors.New("[Identification], invalid ID.")
+	}
+	return nil
+}
+
+// getIdentificationOutput returns the ID program hash of the transaction, it's
+// the first output to an ID as VerifySignature does.
+func getIdentificationOutput(txn *core.Transaction) (*Uint168, bool) {
+	for _, output := range txn.Outputs {
+		if output.ProgramHash[0] == PrefixRegisterId {
+			return &output.ProgramHash, true
+		}
+	}
+	return nil, false
+}
+
+// CheckRechargeToSideChainTransaction checks the recharge transaction in the
+// block at height, the deposit is exchanged by the exchange rate at height.
+func CheckRechargeToSideChainTransaction(txn *core.Transaction, height uint32) error {
 	proof := new(MerkleProof)
 	mainChainTransaction := new(ela.Transaction)
 
@@ -370,10 +626,6 @@ func CheckRechargeToSideChainTransaction(txn *core.Transaction) error {
 		return errors.New("Invalid recharge to side chain payload type")
 	}
 
-	if config.Parameters.ExchangeRate <= 0 {
-		return errors.New("Invalid config exchange rate")
-	}
-
 	reader := bytes.NewReader(payloadRecharge.MerkleProof)
 	if err := proof.Deserialize(reader); err != nil {
 		return errors.New("RechargeToSideChain payload deserialize failed")
@@ -408,7 +660,10 @@ func CheckRechargeToSideChainTransaction(txn *core.Transaction) error {
 				return errors.New("Invalid transaction cross chain amount")
 			}
 
-			crossChainAmount := Fixed64(float64(payloadObj.CrossChainAmounts[i]) * config.Parameters.ExchangeRate)
+			crossChainAmount, err := ExchangeAmount(payloadObj.CrossChainAmounts[i], height)
+			if err != nil {
+				return err
+			}
 			oriOutputTotalAmount += crossChainAmount
 
 			programHash, err := Uint168FromAddress(payloadObj.CrossChainAddresses[i])
@@ -443,6 +698,74 @@ func CheckRechargeToSideChainTransaction(txn *core.Transaction) error {
 	return nil
 }
 
+// CheckWithdrawFromSideChainPayload checks the main chain transaction in
+// payload is a withdraw transaction of this side chain.
+func CheckWithdrawFromSideChainPayload(payload *core.PayloadWithdrawFromSideChain) error {
+	_, mainchainPayload, err := payload.GetMainchainPayload()
+	if err != nil {
+		return err
+	}
+	if len(mainchainPayload.SideChainTransactionHashes) == 0 {
+		return errors.New("[WithdrawFromSideChain], no side chain transaction.")
+	}
+	hashes := make(map[Uint256]struct{})
+	for _, hash := range mainchainPayload.SideChainTransactionHashes {
+		if _, ok := hashes[hash]; ok {
+			return errors.New("[WithdrawFromSideChain], duplicated side chain transaction.")
+		}
+		hashes[hash] = struct{}{}
+	}
+	return nil
+}
+
+// CheckWithdrawFromSideChainTransaction checks the withdrawals processed by the
+// main chain transaction are pending withdrawals of this side chain.
+func CheckWithdrawFromSideChainTransaction(txn *core.Transaction) error {
+	payload, ok := txn.Payload.(*core.PayloadWithdrawFromSideChain)
+	if !ok {
+		return errors.New("Invalid withdraw from side chain payload type")
+	}
+	_, mainchainPayload, err := payload.GetMainchainPayload()
+	if err != nil {
+		return err
+	}
+
+	genesisHash, _ := DefaultLedger.Store.GetBlockHash(uint32(0))
+	genesisAddress, err := common.GetGenesisAddress(genesisHash)
+	if err != nil {
+		return errors.New("Genesis block bytes to address failed")
+	}
+	if mainchainPayload.GenesisBlockAddress != genesisAddress {
+		return errors.New("[WithdrawFromSideChain], invalid genesis block address.")
+	}
+
+	for _, hash := range mainchainPayload.SideChainTransactionHashes {
+		withdrawal, err := DefaultLedger.Store.GetWithdrawal(hash)
+		if err != nil {
+			return fmt.Errorf("[WithdrawFromSideChain], unknown withdrawal %s.", BytesToHexString(BytesReverse(hash.Bytes())))
+		}
+		if withdrawal.Processed {
+			return fmt.Errorf("[WithdrawFromSideChain], withdrawal %s is already processed.", BytesToHexString(BytesReverse(hash.Bytes())))
+		}
+	}
+	return nil
+}
+
+// ExchangeAmount converts a main chain amount to side chain amount by the
+// exchange rate at height, the result is rounded down.
+func ExchangeAmount(amount Fixed64, height uint32) (Fixed64, error) {
+	rate := config.Parameters.ChainParam.GetExchangeRate(height)
+	if rate <= 0 {
+		return 0, errors.New("Invalid exchange rate")
+	}
+	result := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(rate))
+	result.Quo(result, big.NewInt(config.ExchangeRatePrecision))
+	if !result.IsInt64() {
+		return 0, errors.New("Exchange amount overflow")
+	}
+	return Fixed64(result.Int64()), nil
+}
+
 func CheckTransferCrossChainAssetTransaction(txn *core.Transaction) error {
 	payloadObj, ok := txn.Payload.(*core.PayloadTransferCrossChainAsset)
 	if !ok {
@@ -7,11 +7,14 @@ import (
 	"math"
 	"testing"
 
+	sidecommon "github.com/elastos/Elastos.ELA.SideChain/common"
 	"github.com/elastos/Elastos.ELA.SideChain/config"
 	"github.com/elastos/Elastos.ELA.SideChain/core"
 	"github.com/elastos/Elastos.ELA.SideChain/log"
 
 	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	ela "github.com/elastos/Elastos.ELA/core"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -79,6 +82,10 @@ func TestCheckOutputProgramHash(t *testing.T) {
 	programHash[0] = common.PrefixCrossChain
 	assert.Equal(t, true, CheckOutputProgramHash(programHash))
 
+	// prefix schnorr program hash should pass
+	programHash[0] = sidecommon.PrefixSchnorr
+	assert.Equal(t, true, CheckOutputProgramHash(programHash))
+
 	// other prefix program hash should not pass
 	programHash[0] = 0x34
 	assert.Equal(t, false, CheckOutputProgramHash(programHash))
@@ -86,6 +93,19 @@ func TestCheckOutputProgramHash(t *testing.T) {
 	t.Log("[TestCheckOutputProgramHash] PASSED")
 }
 
+func TestCheckOutputActivation(t *testing.T) {
+	origin := config.Parameters.ChainParam.SchnorrHeight
+	defer func() { config.Parameters.ChainParam.SchnorrHeight = origin }()
+	config.Parameters.ChainParam.SchnorrHeight = 100
+
+	tx := &core.Transaction{Outputs: []*core.Output{{ProgramHash: common.Uint168{common.PrefixStandard}}}}
+	assert.NoError(t, CheckOutputActivation(tx, 99))
+
+	tx.Outputs = append(tx.Outputs, &core.Output{ProgramHash: common.Uint168{sidecommon.PrefixSchnorr}})
+	assert.EqualError(t, CheckOutputActivation(tx, 99), "schnorr output address is not activated")
+	assert.NoError(t, CheckOutputActivation(tx, 100))
+}
+
 func TestCheckTransactionInput(t *testing.T) {
 	// coinbase transaction
 	tx := NewCoinBaseTransaction(new(core.PayloadCoinBase), 0)
@@ -395,11 +415,325 @@ func TestCheckTransactionPayload(t *testing.T) {
 	t.Log("[TestCheckTransactionPayload] PASSED")
 }
 
+func TestCheckRegisterIdentificationPayload(t *testing.T) {
+	origin := config.Parameters.ChainParam.IdentificationHeight
+	defer func() { config.Parameters.ChainParam.IdentificationHeight = origin }()
+	config.Parameters.ChainParam.IdentificationHeight = 100
+
+	newID := func() ([]byte, []byte, *common.Uint168) {
+		privateKey, publicKey, _ := crypto.GenerateKeyPair()
+		code, _ := crypto.CreateStandardRedeemScript(publicKey)
+		code[len(code)-1] = common.REGISTERID
+		programHash, _ := sidecommon.ToProgramHash(code)
+		return privateKey, code, programHash
+	}
+	privateKey, code, programHash := newID()
+	otherKey, otherCode, otherProgramHash := newID()
+	id, _ := programHash.ToAddress()
+	standardHash := *programHash
+	standardHash[0] = common.PrefixStandard
+	standardID, _ := standardHash.ToAddress()
+
+	buildTx := func(id string, outputHash common.Uint168, code []byte, key []byte) *core.Transaction {
+		payload := &core.PayloadRegisterIdentification{
+			ID: id,
+			Contents: []core.RegisterIdentificationContent{{
+				Path:   "kyc/person/identityCard",
+				Values: []core.RegisterIdentificationValue{{DataHash: common.Uint256{1}, Proof: "proof"}},
+			}},
+		}
+		payload.Sign, _ = crypto.Sign(key, payload.SignData())
+		return &core.Transaction{
+			TxType:   core.RegisterIdentification,
+			Payload:  payload,
+			Outputs:  []*core.Output{{ProgramHash: outputHash}},
+			Programs: []*core.Program{{Code: code}},
+		}
+	}
+
+	tests := []struct {
+		name string
+		tx   *core.Transaction
+		err  string
+	}{
+		{"valid", buildTx(id, *programHash, code, privateKey), ""},
+		{"invalid ID", buildTx("invalid", *programHash, code, privateKey),
+			"[Identification], invalid ID."},
+		{"not an ID address", buildTx(standardID, standardHash, code, privateKey),
+			"[Identification], invalid ID."},
+		{"no output to ID", buildTx(id, standardHash, code, privateKey),
+			"[RegisterIdentification], no output to the ID."},
+		{"output to other ID", buildTx(id, *otherProgramHash, code, privateKey),
+			"[RegisterIdentification], ID program not found."},
+		{"no ID program", buildTx(id, *programHash, otherCode, privateKey),
+			"[RegisterIdentification], ID program not found."},
+		{"signed by other key", buildTx(id, *programHash, code, otherKey),
+			"[RegisterIdentification], invalid Sign."},
+		// the key of ID is checked in context
+		{"signed by other ID program", buildTx(id, *otherProgramHash, otherCode, otherKey), ""},
+	}
+	for _, test := range tests {
+		assert.NoError(t, CheckTransactionPayload(test.tx), test.name)
+		// the payload is not checked before IdentificationHeight
+		assert.NoError(t, CheckIdentificationActivation(test.tx, 99), test.name)
+		err := CheckIdentificationActivation(test.tx, 100)
+		if test.err == "" {
+			assert.NoError(t, err, test.name)
+		} else {
+			assert.EqualError(t, err, test.err, test.name)
+		}
+	}
+
+	// Sign does not match modified contents
+	tx := buildTx(id, *programHash, code, privateKey)
+	tx.Payload.(*core.PayloadRegisterIdentification).Contents[0].Path = "kyc/person/phone"
+	assert.EqualError(t, CheckIdentificationActivation(tx, 100), "[RegisterIdentification], invalid Sign.")
+
+	// contents are checked as well
+	tx.Payload.(*core.PayloadRegisterIdentification).Contents = nil
+	assert.EqualError(t, CheckIdentificationActivation(tx, 100), "[RegisterIdentification], contents is empty.")
+}
+
+func TestCheckIdentificationTransaction(t *testing.T) {
+	newKey := func() ([]byte, []byte, *common.Uint168) {
+		privateKey, publicKey, _ := crypto.GenerateKeyPair()
+		encoded, _ := publicKey.EncodePoint(true)
+		programHash, _ := GetIdentificationProgramHash(encoded)
+		return privateKey, encoded, programHash
+	}
+	_, _, programHash := newKey()
+	_, newPublicKey, newProgramHash := newKey()
+	id, _ := programHash.ToAddress()
+	path := "kyc/person/phone"
+
+	newTx := func(txType core.TransactionType, payload core.Payload, signer common.Uint168) *core.Transaction {
+		return &core.Transaction{
+			TxType:  txType,
+			Payload: payload,
+			Outputs: []*core.Output{{ProgramHash: signer}},
+		}
+	}
+	register := func(signer common.Uint168) *core.Transaction {
+		return newTx(core.RegisterIdentification, &core.PayloadRegisterIdentification{
+			ID: id,
+			Contents: []core.RegisterIdentificationContent{{
+				Path:   path,
+				Values: []core.RegisterIdentificationValue{{DataHash: common.Uint256{1}, Proof: "proof"}},
+			}},
+		}, signer)
+	}
+	revoke := func(signer common.Uint168) *core.Transaction {
+		return newTx(core.RevokeIdentification,
+			&core.PayloadRevokeIdentification{ID: id, Paths: []string{path}}, signer)
+	}
+	rotate := newTx(core.RotateIdentificationKey,
+		&core.PayloadRotateIdentificationKey{ID: id, PublicKey: newPublicKey}, *programHash)
+
+	store := DefaultLedger.Store.(*ChainStore)
+	var blocks []*core.Block
+	persist := func(tx *core.Transaction) {
+		block := &core.Block{Header: core.Header{Height: uint32(100 + len(blocks))}, Transactions: []*core.Transaction{tx}}
+		store.NewBatch()
+		assert.NoError(t, store.PersistTransactions(block))
+		store.BatchCommit()
+		blocks = append(blocks, block)
+	}
+
+	// payload checks
+	assert.NoError(t, CheckTransactionPayload(revoke(*programHash)))
+	assert.NoError(t, CheckTransactionPayload(rotate))
+	assert.EqualError(t, CheckTransactionPayload(revoke(common.Uint168{})),
+		"[RevokeIdentification], no output to the ID.")
+	assert.EqualError(t, CheckTransactionPayload(newTx(core.RevokeIdentification,
+		&core.PayloadRevokeIdentification{ID: id, Paths: []string{path, path}}, *programHash)),
+		"[RevokeIdentification], duplicated path "+path+".")
+	assert.EqualError(t, CheckTransactionPayload(newTx(core.RevokeIdentification,
+		&core.PayloadRevokeIdentification{ID: id}, *programHash)),
+		"[RevokeIdentification], paths is empty.")
+	assert.EqualError(t, CheckTransactionPayload(newTx(core.RotateIdentificationKey,
+		&core.PayloadRotateIdentificationKey{ID: id, PublicKey: []byte{1, 2, 3}}, *programHash)),
+		"[RotateIdentificationKey], invalid public key.")
+	assert.EqualError(t, CheckTransactionPayload(newTx(core.RotateIdentificationKey,
+		&core.PayloadRotateIdentificationKey{ID: id, PublicKey: newPublicKey}, *newProgramHash)),
+		"[RotateIdentificationKey], the new key is the current key.")
+
+	// revoke and rotate key are valid from IdentificationHeight
+	origin := config.Parameters.ChainParam.IdentificationHeight
+	config.Parameters.ChainParam.IdentificationHeight = 100
+	assert.EqualError(t, CheckIdentificationActivation(revoke(*programHash), 99),
+		"[Identification], transaction type is not activated.")
+	assert.EqualError(t, CheckIdentificationActivation(rotate, 99),
+		"[Identification], transaction type is not activated.")
+	assert.NoError(t, CheckIdentificationActivation(revoke(*programHash), 100))
+	assert.NoError(t, CheckIdentificationActivation(rotate, 100))
+	config.Parameters.ChainParam.IdentificationHeight = origin
+
+	// the path must be registered before revoked
+	assert.NoError(t, CheckIdentificationTransaction(register(*programHash)))
+	assert.EqualError(t, CheckIdentificationTransaction(revoke(*programHash)),
+		"[RevokeIdentification], path "+path+" is not registered.")
+	persist(register(*programHash))
+	assert.NoError(t, CheckIdentificationTransaction(revoke(*programHash)))
+	persist(revoke(*programHash))
+	assert.EqualError(t, CheckIdentificationTransaction(revoke(*programHash)),
+		"[RevokeIdentification], path "+path+" is already revoked.")
+
+	// only the current key controls the ID
+	assert.EqualError(t, CheckIdentificationTransaction(register(*newProgramHash)),
+		"[Identification], not signed by the current key of the ID.")
+	assert.NoError(t, CheckIdentificationTransaction(rotate))
+	persist(rotate)
+	current, err := store.GetIdentificationKey(id)
+	assert.NoError(t, err)
+	assert.Equal(t, *newProgramHash, *current)
+	previous, err := store.GetIdentificationKeyAtHeight(id, blocks[len(blocks)-1].Height-1)
+	assert.NoError(t, err)
+	assert.Equal(t, *programHash, *previous)
+	assert.NoError(t, CheckIdentificationTransaction(register(*newProgramHash)))
+	assert.EqualError(t, CheckIdentificationTransaction(register(*programHash)),
+		"[Identification], not signed by the current key of the ID.")
+
+	// rollback all above
+	for i := len(blocks) - 1; i >= 0; i-- {
+		store.NewBatch()
+		assert.NoError(t, store.RollbackTransactions(blocks[i]))
+		store.BatchCommit()
+	}
+	current, err = store.GetIdentificationKey(id)
+	assert.NoError(t, err)
+	assert.Equal(t, *programHash, *current)
+	versions, err := store.GetIdentificationHistory(id, path)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(versions))
+}
+
+func TestCheckWithdrawFromSideChainTransaction(t *testing.T) {
+	store := DefaultLedger.Store.(*ChainStore)
+	persist := func(height uint32, tx *core.Transaction) *core.Block {
+		block := &core.Block{Header: core.Header{Height: height}, Transactions: []*core.Transaction{tx}}
+		store.NewBatch()
+		assert.NoError(t, store.PersistTransactions(block))
+		store.BatchCommit()
+		return block
+	}
+	rollback := func(block *core.Block) {
+		store.NewBatch()
+		assert.NoError(t, store.RollbackTransactions(block))
+		store.BatchCommit()
+	}
+
+	transfer := &core.Transaction{
+		TxType: core.TransferCrossChainAsset,
+		Payload: &core.PayloadTransferCrossChainAsset{
+			CrossChainAddresses: []string{"EQoascGFzdA6ePjDR5i6ggAR7S1LoRs2np"},
+			OutputIndexes:       []uint64{0},
+			CrossChainAmounts:   []common.Fixed64{common.Fixed64(ELA)},
+		},
+		Outputs: []*core.Output{{Value: common.Fixed64(ELA) + common.Fixed64(config.Parameters.MinCrossChainTxFee)}},
+	}
+	transferBlock := persist(300, transfer)
+	defer rollback(transferBlock)
+
+	genesisHash, _ := store.GetBlockHash(0)
+	genesisAddress, _ := sidecommon.GetGenesisAddress(genesisHash)
+	newWithdrawTx := func(address string, hashes ...common.Uint256) *core.Transaction {
+		mainchainTx := &ela.Transaction{
+			TxType: ela.WithdrawFromSideChain,
+			Payload: &ela.PayloadWithdrawFromSideChain{
+				BlockHeight:                100,
+				GenesisBlockAddress:        address,
+				SideChainTransactionHashes: hashes,
+			},
+		}
+		buf := new(bytes.Buffer)
+		mainchainTx.Serialize(buf)
+		return &core.Transaction{
+			TxType:  core.WithdrawFromSideChain,
+			Payload: &core.PayloadWithdrawFromSideChain{MainChainTransaction: buf.Bytes()},
+		}
+	}
+	withdraw := newWithdrawTx(genesisAddress, transfer.Hash())
+
+	// sanity checks
+	assert.NoError(t, CheckTransactionInput(withdraw))
+	assert.NoError(t, CheckTransactionOutput(withdraw))
+	assert.NoError(t, CheckTransactionPayload(withdraw))
+	assert.EqualError(t, CheckTransactionPayload(newWithdrawTx(genesisAddress)),
+		"[WithdrawFromSideChain], no side chain transaction.")
+	assert.EqualError(t, CheckTransactionPayload(newWithdrawTx(genesisAddress, transfer.Hash(), transfer.Hash())),
+		"[WithdrawFromSideChain], duplicated side chain transaction.")
+	withOutput := newWithdrawTx(genesisAddress, transfer.Hash())
+	withOutput.Outputs = transfer.Outputs
+	assert.Error(t, CheckTransactionOutput(withOutput))
+
+	// context checks
+	assert.NoError(t, CheckWithdrawFromSideChainTransaction(withdraw))
+	assert.EqualError(t, CheckWithdrawFromSideChainTransaction(newWithdrawTx("invalid", transfer.Hash())),
+		"[WithdrawFromSideChain], invalid genesis block address.")
+	assert.Error(t, CheckWithdrawFromSideChainTransaction(newWithdrawTx(genesisAddress, common.Uint256{1})))
+
+	withdrawal, err := store.GetWithdrawal(transfer.Hash())
+	assert.NoError(t, err)
+	assert.False(t, withdrawal.Processed)
+	assert.Equal(t, uint32(300), withdrawal.Height)
+	assert.Equal(t, "EQoascGFzdA6ePjDR5i6ggAR7S1LoRs2np", withdrawal.Outputs[0].Address)
+	assert.Equal(t, common.Fixed64(ELA), withdrawal.Outputs[0].Amount)
+
+	withdrawBlock := persist(301, withdraw)
+	withdrawal, err = store.GetWithdrawal(transfer.Hash())
+	assert.NoError(t, err)
+	assert.True(t, withdrawal.Processed)
+	assert.Equal(t, uint32(301), withdrawal.ProcessedHeight)
+	assert.Equal(t, withdraw.Hash(), withdrawal.ProcessedTxHash)
+	assert.Error(t, CheckWithdrawFromSideChainTransaction(withdraw))
+
+	withdrawals, err := store.GetWithdrawals(300)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(withdrawals))
+	assert.Equal(t, transfer.Hash(), withdrawals[0].TxHash)
+	withdrawals, err = store.GetWithdrawals(301)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(withdrawals))
+
+	// the withdrawal is pending again after rollback
+	rollback(withdrawBlock)
+	withdrawal, err = store.GetWithdrawal(transfer.Hash())
+	assert.NoError(t, err)
+	assert.False(t, withdrawal.Processed)
+	assert.NoError(t, CheckWithdrawFromSideChainTransaction(withdraw))
+}
+
+func TestExchangeAmount(t *testing.T) {
+	origin := config.Parameters.ChainParam.ExchangeRates
+	defer func() { config.Parameters.ChainParam.ExchangeRates = origin }()
+
+	config.Parameters.ChainParam.ExchangeRates = []config.ExchangeRate{
+		{Height: 0, Rate: config.ExchangeRatePrecision},
+		{Height: 100, Rate: config.ExchangeRatePrecision / 3},
+		{Height: 200, Rate: 0},
+	}
+	amount, err := ExchangeAmount(common.Fixed64(10*ELA), 99)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Fixed64(10*ELA), amount)
+
+	// rounded down
+	amount, err = ExchangeAmount(common.Fixed64(10*ELA), 100)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Fixed64(333333330), amount)
+
+	_, err = ExchangeAmount(common.Fixed64(10*ELA), 200)
+	assert.EqualError(t, err, "Invalid exchange rate")
+
+	config.Parameters.ChainParam.ExchangeRates = []config.ExchangeRate{{Height: 0, Rate: 10 * config.ExchangeRatePrecision}}
+	_, err = ExchangeAmount(common.Fixed64(math.MaxInt64/2), 0)
+	assert.EqualError(t, err, "Exchange amount overflow")
+}
+
 func TestCheckTransactionBalance(t *testing.T) {
 	// WithdrawFromSideChain will pass check in any condition
 	tx := new(core.Transaction)
 	tx.TxType = core.WithdrawFromSideChain
-	err := CheckTransactionBalance(tx)
+	err := CheckTransactionBalance(tx, 0)
 	assert.NoError(t, err)
 
 	// deposit 100 ELA to foundation account
@@ -420,7 +754,7 @@ func TestCheckTransactionBalance(t *testing.T) {
 		{AssetID: DefaultLedger.Blockchain.AssetID, ProgramHash: FoundationAddress, Value: common.Fixed64(-20 * ELA)},
 		{AssetID: DefaultLedger.Blockchain.AssetID, ProgramHash: common.Uint168{}, Value: common.Fixed64(-60 * ELA)},
 	}
-	err = CheckTransactionBalance(tx)
+	err = CheckTransactionBalance(tx, 0)
 	assert.EqualError(t, err, "Invalide transaction UTXO output.")
 
 	// invalid transaction fee
@@ -429,7 +763,7 @@ func TestCheckTransactionBalance(t *testing.T) {
 		{AssetID: DefaultLedger.Blockchain.AssetID, ProgramHash: FoundationAddress, Value: common.Fixed64(30 * ELA)},
 		{AssetID: DefaultLedger.Blockchain.AssetID, ProgramHash: common.Uint168{}, Value: common.Fixed64(70 * ELA)},
 	}
-	err = CheckTransactionBalance(tx)
+	err = CheckTransactionBalance(tx, 0)
 	assert.EqualError(t, err, "Transaction fee not enough")
 
 	// rollback deposit above
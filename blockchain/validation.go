@@ -3,18 +3,23 @@ package blockchain
 import (
 	"errors"
 	"fmt"
+	"runtime"
 	"sort"
+	"sync"
 
+	"github.com/elastos/Elastos.ELA.SideChain/common"
+	"github.com/elastos/Elastos.ELA.SideChain/config"
 	"github.com/elastos/Elastos.ELA.SideChain/core"
 	"github.com/elastos/Elastos.ELA.SideChain/spv"
 	"github.com/elastos/Elastos.ELA.SideChain/vm"
 
 	. "github.com/elastos/Elastos.ELA.Utility/common"
-	"github.com/elastos/Elastos.ELA.Utility/crypto"
 )
 
-func VerifySignature(tx *core.Transaction) error {
-	if tx.IsRechargeToSideChainTx() {
+// VerifySignature verifies the programs of the transaction by the script rules
+// of the block at height.
+func VerifySignature(tx *core.Transaction, height uint32) error {
+	if tx.IsRechargeToSideChainTx() || tx.IsWithdrawFromSideChainTx() {
 		if err := spv.VerifyTransaction(tx); err != nil {
 			return err
 		}
@@ -27,7 +32,7 @@ func VerifySignature(tx *core.Transaction) error {
 	}
 
 	// Add ID program hash to hashes
-	if tx.IsRegisterIdentificationTx() {
+	if tx.IsIdentificationTx() {
 		for _, output := range tx.Outputs {
 			if output.ProgramHash[0] == PrefixRegisterId {
 				hashes = append(hashes, output.ProgramHash)
@@ -42,10 +47,87 @@ func VerifySignature(tx *core.Transaction) error {
 		return err
 	}
 
-	return RunPrograms(tx, hashes, tx.Programs)
+	return RunPrograms(tx, hashes, tx.Programs, height)
 }
 
-func RunPrograms(tx *core.Transaction, hashes []Uint168, programs []*core.Program) error {
+// scriptFlags returns the script rules of the transactions in the block at
+// height.
+func scriptFlags(height uint32) vm.VerifyFlags {
+	var flags vm.VerifyFlags
+	if height >= config.Parameters.ChainParam.VMUpgradeHeight {
+		flags |= vm.VerifyStrict
+	}
+	if height >= config.Parameters.ChainParam.SchnorrHeight {
+		flags |= vm.VerifySchnorr
+	}
+	return flags
+}
+
+// VerifyBlockSignatures verifies signatures of all the non-coinbase transactions
+// in block concurrently, the first failure cancels the rest work and returns.
+func VerifyBlockSignatures(block *core.Block) error {
+	return verifySignatures(block.Transactions, func(tx *core.Transaction) error {
+		return CheckTransactionSignature(tx, block.Header.Height)
+	})
+}
+
+func verifySignatures(txs []*core.Transaction, verify func(*core.Transaction) error) error {
+	workers := runtime.NumCPU()
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+
+	var once sync.Once
+	var failure error
+	quit := make(chan struct{})
+	fail := func(err error) {
+		once.Do(func() {
+			failure = err
+			close(quit)
+		})
+	}
+
+	jobs := make(chan *core.Transaction)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case tx, ok := <-jobs:
+					if !ok {
+						return
+					}
+					if err := verify(tx); err != nil {
+						fail(fmt.Errorf("transaction %s verify signature failed, %s",
+							tx.Hash().String(), err.Error()))
+					}
+				case <-quit:
+					return
+				}
+			}
+		}()
+	}
+
+out:
+	for _, tx := range txs {
+		if tx.IsCoinBaseTx() {
+			continue
+		}
+		select {
+		case jobs <- tx:
+		case <-quit:
+			break out
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return failure
+}
+
+func RunPrograms(tx *core.Transaction, hashes []Uint168, programs []*core.Program, height uint32) error {
 	if tx == nil {
 		return errors.New("invalid data content nil transaction")
 	}
@@ -54,7 +136,7 @@ func RunPrograms(tx *core.Transaction, hashes []Uint168, programs []*core.Progra
 	}
 
 	for i := 0; i < len(programs); i++ {
-		programHash, err := crypto.ToProgramHash(programs[i].Code)
+		programHash, err := common.ToProgramHash(programs[i].Code)
 		if err != nil {
 			return err
 		}
@@ -63,7 +145,8 @@ func RunPrograms(tx *core.Transaction, hashes []Uint168, programs []*core.Progra
 			return errors.New("The data hashes is different with corresponding program code.")
 		}
 		//execute program on VM
-		se := vm.NewExecutionEngine(tx.GetDataContainer(programHash), new(vm.CryptoECDsa), vm.MAXSTEPS, nil, nil)
+		se := vm.NewExecutionEngine(tx.GetDataContainer(programHash), new(vm.CryptoSchnorr), vm.MAXSTEPS, nil, nil)
+		se.SetFlags(scriptFlags(height))
 		se.LoadScript(programs[i].Code, false)
 		se.LoadScript(programs[i].Parameter, true)
 		se.Execute()
@@ -136,11 +219,11 @@ type byHash []*core.Program
 func (p byHash) Len() int      { return len(p) }
 func (p byHash) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
 func (p byHash) Less(i, j int) bool {
-	hashi, err := crypto.ToProgramHash(p[i].Code)
+	hashi, err := common.ToProgramHash(p[i].Code)
 	if err != nil {
 		panic(p[i].Code)
 	}
-	hashj, err := crypto.ToProgramHash(p[j].Code)
+	hashj, err := common.ToProgramHash(p[j].Code)
 	if err != nil {
 		panic(p[j].Code)
 	}
@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	math "math/rand"
 	"sort"
+	"sync"
 	"testing"
 
+	sidecommon "github.com/elastos/Elastos.ELA.SideChain/common"
 	"github.com/elastos/Elastos.ELA.SideChain/core"
+	"github.com/elastos/Elastos.ELA.SideChain/vm"
 
 	"github.com/elastos/Elastos.ELA.Utility/common"
 	"github.com/elastos/Elastos.ELA.Utility/crypto"
@@ -78,25 +82,25 @@ func TestCheckCheckSigSignature(t *testing.T) {
 
 	// Normal
 	tx.Programs = []*core.Program{{Code: act.redeemScript, Parameter: signature}}
-	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.NoError(t, err)
 
 	// invalid signature length
 	var fakeSignature = make([]byte, crypto.SignatureScriptLength-1)
 	rand.Read(fakeSignature)
 	tx.Programs = []*core.Program{{Code: act.redeemScript, Parameter: fakeSignature}}
-	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.EqualError(t, err, "[VM] Finish State not equal to HALT.", "Invalid signature length")
 
 	// invalid signature content
 	fakeSignature = make([]byte, crypto.SignatureScriptLength)
 	tx.Programs = []*core.Program{{Code: act.redeemScript, Parameter: fakeSignature}}
-	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.EqualError(t, err, "[VM] Execute Engine Stack Count Error.", "[Validation], Verify failed.")
 
 	// invalid data content
 	tx.Programs = []*core.Program{{Code: act.redeemScript, Parameter: fakeSignature}}
-	err = RunPrograms(nil, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(nil, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.EqualError(t, err, "invalid data content nil transaction", "[Validation], Verify failed.")
 
 	t.Log("TestCheckChecksigSignature passed")
@@ -121,14 +125,14 @@ func TestCheckMultiSigSignature(t *testing.T) {
 	copy(fakeCode, act.redeemScript)
 	fakeCode[0] = fakeCode[0] - fakeCode[0] + crypto.PUSH1 - 1
 	tx.Programs = []*core.Program{{Code: fakeCode, Parameter: signature}}
-	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.EqualError(t, err, "The data hashes is different with corresponding program code.", "invalid multi sign script code")
 
 	// invalid redeem script M > N
 	copy(fakeCode, act.redeemScript)
 	fakeCode[0] = fakeCode[len(fakeCode)-2] - crypto.PUSH1 + 2
 	tx.Programs = []*core.Program{{Code: fakeCode, Parameter: signature}}
-	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.EqualError(t, err, "The data hashes is different with corresponding program code.", "invalid multi sign script code")
 
 	// invalid redeem script length not enough
@@ -137,7 +141,7 @@ func TestCheckMultiSigSignature(t *testing.T) {
 		fakeCode = append(fakeCode[:1], fakeCode[crypto.PublicKeyScriptLength:]...)
 	}
 	tx.Programs = []*core.Program{{Code: fakeCode, Parameter: signature}}
-	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.EqualError(t, err, "[ToProgramHash] error, not a valid multisig script", "not a valid multi sign transaction code, length not enough")
 
 	// invalid redeem script N not equal to public keys count
@@ -145,7 +149,7 @@ func TestCheckMultiSigSignature(t *testing.T) {
 	copy(fakeCode, act.redeemScript)
 	fakeCode[len(fakeCode)-2] = fakeCode[len(fakeCode)-2] + 1
 	tx.Programs = []*core.Program{{Code: fakeCode, Parameter: signature}}
-	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.EqualError(t, err, "The data hashes is different with corresponding program code.", "invalid multi sign public key script count")
 
 	// invalid redeem script wrong public key
@@ -153,37 +157,37 @@ func TestCheckMultiSigSignature(t *testing.T) {
 	copy(fakeCode, act.redeemScript)
 	fakeCode[2] = 0x01
 	tx.Programs = []*core.Program{{Code: fakeCode, Parameter: signature}}
-	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.EqualError(t, err, "The data hashes is different with corresponding program code.", "The encodeData format is error")
 
 	// invalid signature length not match
 	tx.Programs = []*core.Program{{Code: fakeCode, Parameter: signature[math.Intn(64):]}}
-	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.EqualError(t, err, "The data hashes is different with corresponding program code.", "invalid multi sign signatures, length not match")
 
 	// invalid signature not enough
 	cut := len(signature)/crypto.SignatureScriptLength - int(act.redeemScript[0]-crypto.PUSH1)
 	tx.Programs = []*core.Program{{Code: act.redeemScript, Parameter: signature[65*cut:]}}
-	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.EqualError(t, err, "[VM] Finish State not equal to HALT.", "invalid signatures, not enough signatures")
 
 	// invalid signature too many
 	tx.Programs = []*core.Program{{Code: act.redeemScript,
 		Parameter: append(signature[:65], signature...)}}
-	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.EqualError(t, err, "[VM] Finish State not equal to HALT.", "invalid signatures, too many signatures")
 
 	// invalid signature duplicate
 	tx.Programs = []*core.Program{{Code: act.redeemScript,
 		Parameter: append(signature[:65], signature[:len(signature)-65]...)}}
-	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.EqualError(t, err, "[VM] Check Sig FALSE.", "duplicated signatures")
 
 	// invalid signature fake signature
 	signature, err = newMultiAccount(math.Intn(2)+3, t).Sign(data)
 	assert.NoError(t, err, "Generate signature failed, error %v", err)
 	tx.Programs = []*core.Program{{Code: act.redeemScript, Parameter: signature}}
-	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs)
+	err = RunPrograms(tx, []common.Uint168{*act.programHash}, tx.Programs, 0)
 	assert.EqualError(t, err, "[VM] Check Sig FALSE.", "matched signatures not enough")
 
 	t.Log("TestCheckMultisigSignature passed")
@@ -231,7 +235,7 @@ func TestRunPrograms(t *testing.T) {
 			break
 		}
 	}
-	err = RunPrograms(tx, []common.Uint168{hashes[index]}, []*core.Program{programs[index]})
+	err = RunPrograms(tx, []common.Uint168{hashes[index]}, []*core.Program{programs[index]}, 0)
 	assert.NoError(t, err, "[RunProgram] passed with 1 checksig program")
 
 	// 1 loop multisig
@@ -242,24 +246,24 @@ func TestRunPrograms(t *testing.T) {
 			break
 		}
 	}
-	err = RunPrograms(tx, []common.Uint168{hashes[index]}, []*core.Program{programs[index]})
+	err = RunPrograms(tx, []common.Uint168{hashes[index]}, []*core.Program{programs[index]}, 0)
 	assert.NoError(t, err, "[RunProgram] passed with 1 multisig program")
 
 	// multiple programs
-	err = RunPrograms(tx, hashes, programs)
+	err = RunPrograms(tx, hashes, programs, 0)
 	assert.NoError(t, err, "[RunProgram] passed with multiple programs")
 
 	// hashes count not equal to programs count
 	init()
 	removeIndex := math.Intn(num)
 	hashes = append(hashes[:removeIndex], hashes[removeIndex+1:]...)
-	err = RunPrograms(tx, hashes, programs)
+	err = RunPrograms(tx, hashes, programs, 0)
 	assert.Equal(t, "The number of data hashes is different with number of programs.", err.Error())
 
 	// With no programs
 	init()
 	programs = []*core.Program{}
-	err = RunPrograms(tx, hashes, programs)
+	err = RunPrograms(tx, hashes, programs, 0)
 	assert.Equal(t, "The number of data hashes is different with number of programs.", err.Error())
 
 	// With unmatched hashes
@@ -267,14 +271,14 @@ func TestRunPrograms(t *testing.T) {
 	for i := 0; i < num; i++ {
 		rand.Read(hashes[math.Intn(num)][:])
 	}
-	err = RunPrograms(tx, hashes, programs)
+	err = RunPrograms(tx, hashes, programs, 0)
 	assert.Equal(t, "The data hashes is different with corresponding program code.", err.Error())
 
 	// With disordered hashes
 	init()
 	common.SortProgramHashes(hashes)
 	sort.Sort(sort.Reverse(byHash(programs)))
-	err = RunPrograms(tx, hashes, programs)
+	err = RunPrograms(tx, hashes, programs, 0)
 	assert.EqualError(t, err, "The data hashes is different with corresponding program code.")
 
 	// With random no code
@@ -282,8 +286,8 @@ func TestRunPrograms(t *testing.T) {
 	for i := 0; i < num; i++ {
 		programs[math.Intn(num)].Code = nil
 	}
-	err = RunPrograms(tx, hashes, programs)
-	assert.EqualError(t, err,"[ToProgramHash] failed, empty program code")
+	err = RunPrograms(tx, hashes, programs, 0)
+	assert.EqualError(t, err, "[ToProgramHash] failed, empty program code")
 
 	// With random no parameter
 	init()
@@ -291,7 +295,7 @@ func TestRunPrograms(t *testing.T) {
 		index := math.Intn(num)
 		programs[index].Parameter = nil
 	}
-	err = RunPrograms(tx, hashes, programs)
+	err = RunPrograms(tx, hashes, programs, 0)
 	assert.Error(t, err, "[RunProgram] passed with random no parameter")
 
 	t.Log("TestRunPrograms passed")
@@ -406,7 +410,8 @@ func TestSortPrograms(t *testing.T) {
 	NEXT:
 		rand.Read(code)
 		switch code[len(code)-1] {
-		case common.STANDARD, common.MULTISIG, common.CROSSCHAIN:
+		case common.STANDARD, common.MULTISIG, common.CROSSCHAIN,
+			sidecommon.SCHNORR, sidecommon.SCHNORRMULTISIG:
 			goto NEXT
 		}
 		return code
@@ -465,3 +470,75 @@ func TestSortPrograms(t *testing.T) {
 		t.Logf("Hash[%02d] %s match with ProgramHash[%02d] %s", i, hex.EncodeToString(hash[:]), i, hex.EncodeToString(programsHash[:]))
 	}
 }
+
+func TestVerifySignatures(t *testing.T) {
+	txs := make([]*core.Transaction, 0, 100)
+	coinbase := buildTx()
+	coinbase.TxType = core.CoinBase
+	txs = append(txs, coinbase)
+	for i := 0; i < 99; i++ {
+		txs = append(txs, buildTx())
+	}
+
+	// all passed, coinbase skipped
+	var lock sync.Mutex
+	verified := make(map[common.Uint256]bool)
+	err := verifySignatures(txs, func(tx *core.Transaction) error {
+		lock.Lock()
+		defer lock.Unlock()
+		verified[tx.Hash()] = true
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, len(txs)-1, len(verified))
+	assert.False(t, verified[coinbase.Hash()])
+
+	// one failed
+	fake := txs[math.Intn(len(txs)-1)+1]
+	err = verifySignatures(txs, func(tx *core.Transaction) error {
+		if tx == fake {
+			return errors.New("[VM] Check Sig FALSE.")
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "transaction "+fake.Hash().String()+
+		" verify signature failed, [VM] Check Sig FALSE.")
+
+	// empty block
+	err = verifySignatures(nil, func(tx *core.Transaction) error {
+		return errors.New("should not be called")
+	})
+	assert.NoError(t, err)
+}
+
+func TestSignatureCache(t *testing.T) {
+	cache := NewSignatureCache(10)
+
+	tx := buildTx()
+	act := newAccount(t)
+	signature, err := act.Sign(getData(tx))
+	assert.NoError(t, err)
+	tx.Programs = []*core.Program{{Code: act.redeemScript, Parameter: signature}}
+
+	assert.False(t, cache.Exists(tx, vm.VerifyStrict))
+	cache.Add(tx, vm.VerifyStrict)
+	assert.True(t, cache.Exists(tx, vm.VerifyStrict))
+
+	// verified by other script rules
+	assert.False(t, cache.Exists(tx, 0))
+
+	// same transaction hash with different programs
+	fake := *tx
+	fake.Programs = []*core.Program{{Code: act.redeemScript, Parameter: signature[1:]}}
+	assert.Equal(t, tx.Hash(), fake.Hash())
+	assert.False(t, cache.Exists(&fake, vm.VerifyStrict))
+
+	cache.Remove([]*core.Transaction{tx})
+	assert.False(t, cache.Exists(tx, vm.VerifyStrict))
+
+	// cache size limit
+	for i := 0; i < 20; i++ {
+		cache.Add(buildTx(), vm.VerifyStrict)
+	}
+	assert.Equal(t, 10, cache.Count())
+}
@@ -0,0 +1,279 @@
+package blockchain
+
+import (
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain/config"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+const (
+	// VersionBitsTopBits are the top bits of block versions which signal
+	// deployments by version bits.
+	VersionBitsTopBits = 0x20000000
+
+	// VersionBitsTopMask is the mask of the top bits of block versions.
+	VersionBitsTopMask = 0xe0000000
+)
+
+// ThresholdState is the state of a version bits deployment, it changes only at
+// the boundaries of deployment windows.
+type ThresholdState byte
+
+const (
+	// ThresholdDefined is the first state of a deployment.
+	ThresholdDefined ThresholdState = iota
+
+	// ThresholdStarted is the state once the median time past reaches the
+	// start time, miners signal the deployment bit.
+	ThresholdStarted
+
+	// ThresholdLockedIn is the state for the window after a window with
+	// enough signalling blocks.
+	ThresholdLockedIn
+
+	// ThresholdActive is the state after the locked in window, the
+	// consensus change is enforced.
+	ThresholdActive
+
+	// ThresholdFailed is the state once the median time past reaches the
+	// expire time before the deployment is locked in.
+	ThresholdFailed
+)
+
+func (s ThresholdState) String() string {
+	switch s {
+	case ThresholdDefined:
+		return "defined"
+	case ThresholdStarted:
+		return "started"
+	case ThresholdLockedIn:
+		return "locked_in"
+	case ThresholdActive:
+		return "active"
+	case ThresholdFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// DeploymentInfo is the state of a deployment for the next block, and the
+// signalling of the current window.
+type DeploymentInfo struct {
+	Deployment config.Deployment
+	Window     uint32
+	State      ThresholdState
+	// Elapsed is the number of blocks of the current window, and Count is
+	// the number of them signalling the deployment bit.
+	Elapsed uint32
+	Count   uint32
+}
+
+// deploymentWindow returns the number of blocks of a deployment window, it's
+// the difficulty retarget window.
+func deploymentWindow(params *config.ChainParams) uint32 {
+	return uint32(params.TargetTimespan / params.TargetTimePerBlock)
+}
+
+// isSignalling returns if the block version signals the deployment bit.
+func isSignalling(version uint32, deployment *config.Deployment) bool {
+	return version&VersionBitsTopMask == VersionBitsTopBits &&
+		version&(uint32(1)<<deployment.Bit) != 0
+}
+
+// CalcNextBlockVersion returns the version of the block after the best chain,
+// it signals the deployments which are started or locked in. The write lock is
+// held since the nodes not in memory are loaded into the chain.
+func (bc *Blockchain) CalcNextBlockVersion() (uint32, error) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return bc.calcNextBlockVersion(config.Parameters.ChainParam, bc.BestChain)
+}
+
+func (bc *Blockchain) calcNextBlockVersion(params *config.ChainParams, prevNode *BlockNode) (uint32, error) {
+	version := uint32(VersionBitsTopBits)
+	for i := range params.Deployments {
+		deployment := &params.Deployments[i]
+		state, err := bc.thresholdState(params, prevNode, deployment)
+		if err != nil {
+			return 0, err
+		}
+		if state == ThresholdStarted || state == ThresholdLockedIn {
+			version |= uint32(1) << deployment.Bit
+		}
+	}
+	return version, nil
+}
+
+// GetDeploymentInfo returns the states of the deployments for the block after
+// the best chain. The write lock is held since the nodes not in memory are
+// loaded into the chain.
+func (bc *Blockchain) GetDeploymentInfo() ([]*DeploymentInfo, error) {
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	return bc.getDeploymentInfo(config.Parameters.ChainParam, bc.BestChain)
+}
+
+func (bc *Blockchain) getDeploymentInfo(params *config.ChainParams, prevNode *BlockNode) ([]*DeploymentInfo, error) {
+	window := deploymentWindow(params)
+	var infos []*DeploymentInfo
+	for i := range params.Deployments {
+		deployment := &params.Deployments[i]
+		state, err := bc.thresholdState(params, prevNode, deployment)
+		if err != nil {
+			return nil, err
+		}
+		info := &DeploymentInfo{Deployment: *deployment, Window: window, State: state}
+		if prevNode != nil {
+			info.Elapsed = (prevNode.Height + 1) % window
+			info.Count, err = bc.countSignalling(prevNode, info.Elapsed, deployment)
+			if err != nil {
+				return nil, err
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// thresholdState returns the state of the deployment for the block after
+// prevNode. The state after each window is cached by the hash of the last
+// block of the window.
+func (bc *Blockchain) thresholdState(params *config.ChainParams, prevNode *BlockNode, deployment *config.Deployment) (ThresholdState, error) {
+	window := deploymentWindow(params)
+	if prevNode == nil || prevNode.Height+1 < window {
+		return ThresholdDefined, nil
+	}
+
+	bc.versionBitsLock.Lock()
+	defer bc.versionBitsLock.Unlock()
+	if bc.versionBitsCache == nil {
+		bc.versionBitsCache = make(map[string]map[Uint256]ThresholdState)
+	}
+	cache, ok := bc.versionBitsCache[deployment.Name]
+	if !ok {
+		cache = make(map[Uint256]ThresholdState)
+		bc.versionBitsCache[deployment.Name] = cache
+	}
+
+	// Walk back the window boundaries until a cached state, or a window
+	// before the start time which must be defined.
+	boundary, err := bc.getAncestor(prevNode, (prevNode.Height+1)/window*window-1)
+	if err != nil {
+		return 0, err
+	}
+	state := ThresholdDefined
+	var boundaries []*BlockNode
+	for boundary != nil {
+		if cached, ok := cache[*boundary.Hash]; ok {
+			state = cached
+			break
+		}
+		medianTime, err := bc.pastMedianTime(boundary)
+		if err != nil {
+			return 0, err
+		}
+		if medianTime.Unix() < deployment.StartTime {
+			cache[*boundary.Hash] = ThresholdDefined
+			break
+		}
+		boundaries = append(boundaries, boundary)
+		if boundary.Height < window {
+			break
+		}
+		if boundary, err = bc.getAncestor(boundary, boundary.Height-window); err != nil {
+			return 0, err
+		}
+	}
+
+	// Walk forward the windows to the state of the block after prevNode.
+	for i := len(boundaries) - 1; i >= 0; i-- {
+		boundary := boundaries[i]
+		medianTime, err := bc.pastMedianTime(boundary)
+		if err != nil {
+			return 0, err
+		}
+
+		switch state {
+		case ThresholdDefined:
+			if medianTime.Unix() >= deployment.ExpireTime {
+				state = ThresholdFailed
+			} else if medianTime.Unix() >= deployment.StartTime {
+				state = ThresholdStarted
+			}
+
+		case ThresholdStarted:
+			if medianTime.Unix() >= deployment.ExpireTime {
+				state = ThresholdFailed
+				break
+			}
+			count, err := bc.countSignalling(boundary, window, deployment)
+			if err != nil {
+				return 0, err
+			}
+			if count >= deployment.Threshold {
+				state = ThresholdLockedIn
+			}
+
+		case ThresholdLockedIn:
+			state = ThresholdActive
+		}
+		cache[*boundary.Hash] = state
+	}
+
+	return state, nil
+}
+
+// removeThresholdStates removes the states cached by the hash of the node, it's
+// called when the node is pruned from the node index.
+func (bc *Blockchain) removeThresholdStates(hash *Uint256) {
+	bc.versionBitsLock.Lock()
+	defer bc.versionBitsLock.Unlock()
+	for _, cache := range bc.versionBitsCache {
+		delete(cache, *hash)
+	}
+}
+
+// countSignalling returns the number of blocks signalling the deployment in
+// the count blocks to node.
+func (bc *Blockchain) countSignalling(node *BlockNode, count uint32, deployment *config.Deployment) (uint32, error) {
+	var signalling uint32
+	for i := uint32(0); i < count && node != nil; i++ {
+		if isSignalling(node.Version, deployment) {
+			signalling++
+		}
+		if i+1 == count || node.Height == 0 {
+			break
+		}
+		var err error
+		if node, err = bc.GetPrevNodeFromNode(node); err != nil {
+			return 0, err
+		}
+	}
+	return signalling, nil
+}
+
+// getAncestor returns the ancestor of node at height, the nodes not in memory
+// are loaded from the store.
+func (bc *Blockchain) getAncestor(node *BlockNode, height uint32) (*BlockNode, error) {
+	for node != nil && node.Height > height {
+		var err error
+		if node, err = bc.GetPrevNodeFromNode(node); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// pastMedianTime returns the median time past of node, the ancestors used are
+// loaded from the store if they are not in memory.
+func (bc *Blockchain) pastMedianTime(node *BlockNode) (time.Time, error) {
+	if node.Height >= medianTimeBlocks-1 {
+		if _, err := bc.getAncestor(node, node.Height-medianTimeBlocks+1); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return CalcPastMedianTime(node), nil
+}
@@ -0,0 +1,105 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain/config"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// newVersionBitsTestChain returns the nodes of a chain with a block every 10
+// seconds, the blocks at heights in signalling signal the bit.
+func newVersionBitsTestChain(count int, bit uint8, signalling func(height uint32) bool) []*BlockNode {
+	nodes := make([]*BlockNode, count)
+	var parent *BlockNode
+	for i := range nodes {
+		height := uint32(i)
+		version := uint32(VersionBitsTopBits)
+		if signalling(height) {
+			version |= uint32(1) << bit
+		}
+		nodes[i] = &BlockNode{
+			Hash:      &common.Uint256{byte(i), byte(i >> 8)},
+			Height:    height,
+			Version:   version,
+			Timestamp: height * 10,
+			Parent:    parent,
+		}
+		parent = nodes[i]
+	}
+	return nodes
+}
+
+func TestThresholdState(t *testing.T) {
+	deployment := config.Deployment{Name: "test", Bit: 28, StartTime: 200, ExpireTime: 1000, Threshold: 8}
+	params := &config.ChainParams{
+		TargetTimespan:     time.Second * 10 * 10,
+		TargetTimePerBlock: time.Second * 10,
+		Deployments:        []config.Deployment{deployment},
+	}
+
+	// the deployment starts in the window after median time past 200, and 8
+	// blocks of the window from height 30 signal
+	bc := &Blockchain{}
+	nodes := newVersionBitsTestChain(60, deployment.Bit, func(height uint32) bool {
+		return height >= 30 && height < 38
+	})
+	tests := []struct {
+		prevHeight uint32
+		expected   ThresholdState
+	}{
+		{0, ThresholdDefined},
+		{19, ThresholdDefined},
+		{28, ThresholdDefined},
+		{29, ThresholdStarted},
+		{38, ThresholdStarted},
+		{39, ThresholdLockedIn},
+		{48, ThresholdLockedIn},
+		{49, ThresholdActive},
+		{59, ThresholdActive},
+	}
+	for _, test := range tests {
+		state, err := bc.thresholdState(params, nodes[test.prevHeight], &deployment)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, state, "height %d", test.prevHeight+1)
+	}
+
+	// miners signal the started and locked in deployment
+	version, err := bc.calcNextBlockVersion(params, nodes[29])
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(VersionBitsTopBits|1<<28), version)
+	version, err = bc.calcNextBlockVersion(params, nodes[49])
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(VersionBitsTopBits), version)
+
+	infos, err := bc.getDeploymentInfo(params, nodes[35])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(infos))
+	assert.Equal(t, ThresholdStarted, infos[0].State)
+	assert.Equal(t, uint32(6), infos[0].Elapsed)
+	assert.Equal(t, uint32(6), infos[0].Count)
+
+	// the cached states are removed with the pruned nodes
+	assert.Equal(t, 5, len(bc.versionBitsCache[deployment.Name]))
+	nodes[19].Parent, nodes[19].ParentHash = nil, nodes[18].Hash
+	assert.NoError(t, bc.RemoveBlockNode(nodes[19]))
+	assert.Equal(t, 4, len(bc.versionBitsCache[deployment.Name]))
+	_, ok := bc.versionBitsCache[deployment.Name][*nodes[19].Hash]
+	assert.False(t, ok)
+
+	// the deployment fails at the expire time without enough signalling
+	bc = &Blockchain{}
+	deployment.ExpireTime = 300
+	nodes = newVersionBitsTestChain(50, deployment.Bit, func(height uint32) bool {
+		return height >= 30 && height < 37
+	})
+	state, err := bc.thresholdState(params, nodes[39], &deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, ThresholdFailed, state)
+	state, err = bc.thresholdState(params, nodes[49], &deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, ThresholdFailed, state)
+}
@@ -0,0 +1,256 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+	"github.com/elastos/Elastos.ELA.SideChain/log"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+// Withdrawal is a TransferCrossChainAsset transaction to the main chain, it is
+// pending until a WithdrawFromSideChain transaction proves the main chain has
+// processed it. Withdrawals are kept in IX_Withdrawal ordered by height.
+type Withdrawal struct {
+	TxHash          Uint256
+	Height          uint32
+	Outputs         []*WithdrawalOutput
+	Processed       bool
+	ProcessedHeight uint32
+	ProcessedTxHash Uint256 // the WithdrawFromSideChain transaction
+	MainChainTxHash Uint256
+}
+
+// WithdrawalOutput is the main chain address and amount of a withdrawal.
+type WithdrawalOutput struct {
+	Address string
+	Amount  Fixed64
+}
+
+func (w *Withdrawal) Serialize(writer io.Writer) error {
+	if err := WriteVarUint(writer, uint64(len(w.Outputs))); err != nil {
+		return err
+	}
+	for _, output := range w.Outputs {
+		if err := WriteVarString(writer, output.Address); err != nil {
+			return err
+		}
+		if err := output.Amount.Serialize(writer); err != nil {
+			return err
+		}
+	}
+	if err := WriteElements(writer, w.Processed, w.ProcessedHeight); err != nil {
+		return err
+	}
+	if err := w.ProcessedTxHash.Serialize(writer); err != nil {
+		return err
+	}
+	return w.MainChainTxHash.Serialize(writer)
+}
+
+func (w *Withdrawal) Deserialize(reader io.Reader) error {
+	count, err := ReadVarUint(reader, 0)
+	if err != nil {
+		return err
+	}
+	w.Outputs = make([]*WithdrawalOutput, 0, count)
+	for i := uint64(0); i < count; i++ {
+		output := new(WithdrawalOutput)
+		if output.Address, err = ReadVarString(reader); err != nil {
+			return err
+		}
+		if err := output.Amount.Deserialize(reader); err != nil {
+			return err
+		}
+		w.Outputs = append(w.Outputs, output)
+	}
+	if err := ReadElements(reader, &w.Processed, &w.ProcessedHeight); err != nil {
+		return err
+	}
+	if err := w.ProcessedTxHash.Deserialize(reader); err != nil {
+		return err
+	}
+	return w.MainChainTxHash.Deserialize(reader)
+}
+
+func getWithdrawalKey(height uint32, txHash Uint256) []byte {
+	key := make([]byte, 5, 5+UINT256SIZE)
+	key[0] = byte(IX_Withdrawal)
+	binary.BigEndian.PutUint32(key[1:], height)
+	return append(key, txHash.Bytes()...)
+}
+
+func (c *ChainStore) putWithdrawal(withdrawal *Withdrawal) error {
+	value := new(bytes.Buffer)
+	if err := withdrawal.Serialize(value); err != nil {
+		return err
+	}
+	c.BatchPut(getWithdrawalKey(withdrawal.Height, withdrawal.TxHash), value.Bytes())
+	return nil
+}
+
+// PersistWithdrawal adds a pending withdrawal of the TransferCrossChainAsset
+// transaction.
+func (c *ChainStore) PersistWithdrawal(txn *core.Transaction, height uint32) error {
+	payload := txn.Payload.(*core.PayloadTransferCrossChainAsset)
+	withdrawal := &Withdrawal{TxHash: txn.Hash(), Height: height}
+	for i, address := range payload.CrossChainAddresses {
+		withdrawal.Outputs = append(withdrawal.Outputs, &WithdrawalOutput{
+			Address: address,
+			Amount:  payload.CrossChainAmounts[i],
+		})
+	}
+	return c.putWithdrawal(withdrawal)
+}
+
+func (c *ChainStore) RollbackWithdrawal(txn *core.Transaction, height uint32) error {
+	c.BatchDelete(getWithdrawalKey(height, txn.Hash()))
+	return nil
+}
+
+// PersistWithdrawalsProcessed marks the withdrawals in the main chain withdraw
+// transaction as processed by the WithdrawFromSideChain transaction.
+func (c *ChainStore) PersistWithdrawalsProcessed(txn *core.Transaction, height uint32) error {
+	payload := txn.Payload.(*core.PayloadWithdrawFromSideChain)
+	mainchainTx, mainchainPayload, err := payload.GetMainchainPayload()
+	if err != nil {
+		return err
+	}
+	for _, hash := range mainchainPayload.SideChainTransactionHashes {
+		withdrawal, err := c.GetWithdrawal(hash)
+		if err != nil {
+			return err
+		}
+		withdrawal.Processed = true
+		withdrawal.ProcessedHeight = height
+		withdrawal.ProcessedTxHash = txn.Hash()
+		withdrawal.MainChainTxHash = mainchainTx.Hash()
+		if err := c.putWithdrawal(withdrawal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ChainStore) RollbackWithdrawalsProcessed(txn *core.Transaction) error {
+	payload := txn.Payload.(*core.PayloadWithdrawFromSideChain)
+	_, mainchainPayload, err := payload.GetMainchainPayload()
+	if err != nil {
+		return err
+	}
+	for _, hash := range mainchainPayload.SideChainTransactionHashes {
+		withdrawal, err := c.GetWithdrawal(hash)
+		if err != nil {
+			return err
+		}
+		withdrawal.Processed = false
+		withdrawal.ProcessedHeight = 0
+		withdrawal.ProcessedTxHash = Uint256{}
+		withdrawal.MainChainTxHash = Uint256{}
+		if err := c.putWithdrawal(withdrawal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BuildWithdrawalIndex adds the withdrawals of blocks saved before IX_Withdrawal
+// is introduced, it does nothing if the index is built. It must be called at
+// startup before blocks are saved.
+func (c *ChainStore) BuildWithdrawalIndex() error {
+	if _, err := c.Get([]byte{byte(SYS_WithdrawalIndex)}); err == nil {
+		return nil
+	}
+	height := c.GetHeight()
+	if height > 0 {
+		if _, ok := c.GetPruneHeight(); ok {
+			return errors.New("[Withdrawal], can not build the withdrawal index of a pruned chain store.")
+		}
+		log.Infof("[Withdrawal] build withdrawal index from %d blocks", height+1)
+	}
+
+	// withdrawals of the blocks are removed first in case some of them are
+	// persisted already
+	c.NewBatch()
+	iter := c.NewIterator([]byte{byte(IX_Withdrawal)})
+	for iter.Next() {
+		c.BatchDelete(iter.Key())
+	}
+	iter.Release()
+	if err := c.BatchCommit(); err != nil {
+		return err
+	}
+
+	for h := uint32(0); h <= height; h++ {
+		hash, err := c.GetBlockHash(h)
+		if err != nil {
+			return err
+		}
+		block, err := c.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+
+		c.NewBatch()
+		for _, txn := range block.Transactions {
+			switch txn.TxType {
+			case core.TransferCrossChainAsset:
+				err = c.PersistWithdrawal(txn, h)
+			case core.WithdrawFromSideChain:
+				err = c.PersistWithdrawalsProcessed(txn, h)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := c.BatchCommit(); err != nil {
+			return err
+		}
+	}
+
+	c.NewBatch()
+	c.BatchPut([]byte{byte(SYS_WithdrawalIndex)}, []byte{1})
+	return c.BatchCommit()
+}
+
+// GetWithdrawal returns the withdrawal of the TransferCrossChainAsset
+// transaction.
+func (c *ChainStore) GetWithdrawal(txHash Uint256) (*Withdrawal, error) {
+	_, height, err := c.GetTransaction(txHash)
+	if err != nil {
+		return nil, err
+	}
+	data, err := c.Get(getWithdrawalKey(height, txHash))
+	if err != nil {
+		return nil, errors.New("[Withdrawal], withdrawal not found.")
+	}
+	withdrawal := &Withdrawal{TxHash: txHash, Height: height}
+	if err := withdrawal.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return withdrawal, nil
+}
+
+// GetWithdrawals returns the withdrawals from height to the current height.
+func (c *ChainStore) GetWithdrawals(height uint32) ([]*Withdrawal, error) {
+	var withdrawals []*Withdrawal
+	iter := c.NewIterator([]byte{byte(IX_Withdrawal)})
+	defer iter.Release()
+	for ok := iter.Seek(getWithdrawalKey(height, Uint256{})); ok; ok = iter.Next() {
+		key := iter.Key()
+		if len(key) != 5+UINT256SIZE {
+			continue
+		}
+		withdrawal := &Withdrawal{Height: binary.BigEndian.Uint32(key[1:])}
+		copy(withdrawal.TxHash[:], key[5:])
+		if err := withdrawal.Deserialize(bytes.NewReader(iter.Value())); err != nil {
+			return nil, err
+		}
+		withdrawals = append(withdrawals, withdrawal)
+	}
+	return withdrawals, nil
+}
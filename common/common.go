@@ -2,6 +2,7 @@ package common
 
 import (
 	"bytes"
+	"errors"
 
 	"github.com/elastos/Elastos.ELA.Utility/common"
 	"github.com/elastos/Elastos.ELA.Utility/crypto"
@@ -23,3 +24,77 @@ func GetGenesisProgramHash(genesisHash common.Uint256) (*common.Uint168, error)
 
 	return crypto.ToProgramHash(buf.Bytes())
 }
+
+const (
+	// SCHNORR and SCHNORRMULTISIG are the signature types of programs end with
+	// CHECKSCHNORRSIG and CHECKAGGSIG opcodes.
+	SCHNORR         = 0xB0
+	SCHNORRMULTISIG = 0xB1
+
+	// PrefixSchnorr is the program hash prefix of Schnorr programs, addresses
+	// with this prefix start with "S".
+	PrefixSchnorr = 0x3F
+)
+
+// ToProgramHash extends crypto.ToProgramHash with Schnorr programs. A Schnorr
+// program has the same layout as a standard or multisig program, so it is
+// hashed as the program of that layout and marked by PrefixSchnorr.
+func ToProgramHash(code []byte) (*common.Uint168, error) {
+	if len(code) < 1 {
+		return nil, errors.New("[ToProgramHash] failed, empty program code")
+	}
+
+	var signType byte
+	switch code[len(code)-1] {
+	case SCHNORR:
+		signType = common.STANDARD
+	case SCHNORRMULTISIG:
+		signType = common.MULTISIG
+	default:
+		return crypto.ToProgramHash(code)
+	}
+
+	layout := make([]byte, len(code))
+	copy(layout, code)
+	layout[len(layout)-1] = signType
+	programHash, err := crypto.ToProgramHash(layout)
+	if err != nil {
+		return nil, err
+	}
+	programHash[0] = PrefixSchnorr
+	return programHash, nil
+}
+
+// CreateSchnorrRedeemScript creates the program code of a single x-only Schnorr
+// public key.
+func CreateSchnorrRedeemScript(publicKey []byte) ([]byte, error) {
+	if len(publicKey) != 32 {
+		return nil, errors.New("[CreateSchnorrRedeemScript] failed, invalid public key length")
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteByte(byte(len(publicKey)))
+	buf.Write(publicKey)
+	buf.WriteByte(SCHNORR)
+	return buf.Bytes(), nil
+}
+
+// CreateSchnorrMultiSignRedeemScript creates the program code which requires m
+// of the compressed public keys to sign one aggregated Schnorr signature.
+func CreateSchnorrMultiSignRedeemScript(m int, publicKeys [][]byte) ([]byte, error) {
+	n := len(publicKeys)
+	if m < 1 || m > n || n > 24 {
+		return nil, errors.New("[CreateSchnorrMultiSignRedeemScript] failed, invalid m or n")
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteByte(byte(crypto.PUSH1 + m - 1))
+	for _, publicKey := range publicKeys {
+		if len(publicKey) != 33 {
+			return nil, errors.New("[CreateSchnorrMultiSignRedeemScript] failed, invalid public key length")
+		}
+		buf.WriteByte(byte(len(publicKey)))
+		buf.Write(publicKey)
+	}
+	buf.WriteByte(byte(crypto.PUSH1 + n - 1))
+	buf.WriteByte(SCHNORRMULTISIG)
+	return buf.Bytes(), nil
+}
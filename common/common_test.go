@@ -0,0 +1,63 @@
+package common
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToProgramHash(t *testing.T) {
+	publicKey, _ := hex.DecodeString("f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9")
+
+	code, err := CreateSchnorrRedeemScript(publicKey)
+	assert.NoError(t, err)
+	programHash, err := ToProgramHash(code)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(PrefixSchnorr), programHash[0])
+	address, err := programHash.ToAddress()
+	assert.NoError(t, err)
+	assert.Equal(t, byte('S'), address[0])
+
+	// standard program of the same key has a different program hash
+	standard := append(code[:len(code)-1:len(code)-1], common.STANDARD)
+	standardHash, err := ToProgramHash(standard)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(common.PrefixStandard), standardHash[0])
+	assert.NotEqual(t, *programHash, *standardHash)
+
+	// multi sign program
+	var publicKeys [][]byte
+	for _, key := range []string{
+		"036b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c296",
+		"0291f91fd2a3c8010e319c70f2a229bb1b1c6ec80a70d684ea7417dc3c557e5755",
+		"03a9d5766a5af225048983c72c7c0bd49e6cb3a4ebac3a1e47daec8b71d24b0f61",
+	} {
+		publicKey, _ := hex.DecodeString(key)
+		publicKeys = append(publicKeys, publicKey)
+	}
+	code, err = CreateSchnorrMultiSignRedeemScript(2, publicKeys)
+	assert.NoError(t, err)
+	programHash, err = ToProgramHash(code)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(PrefixSchnorr), programHash[0])
+
+	_, err = CreateSchnorrMultiSignRedeemScript(4, publicKeys)
+	assert.Error(t, err)
+
+	// x-only keys are only for single Schnorr programs
+	_, err = CreateSchnorrRedeemScript(publicKeys[0])
+	assert.Error(t, err)
+	_, err = CreateSchnorrMultiSignRedeemScript(1, [][]byte{publicKey})
+	assert.Error(t, err)
+
+	// other programs are hashed by crypto.ToProgramHash
+	hash, err := crypto.ToProgramHash(standard)
+	assert.NoError(t, err)
+	assert.Equal(t, *hash, *standardHash)
+
+	_, err = ToProgramHash(nil)
+	assert.EqualError(t, err, "[ToProgramHash] failed, empty program code")
+}
@@ -3,8 +3,11 @@ package config
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"math/big"
 	"os"
 	"time"
@@ -12,12 +15,25 @@ import (
 
 const (
 	DefaultConfigFilename = "./config.json"
+
+	// ExchangeRatePrecision is the fixed point precision of exchange rates,
+	// a rate of ExchangeRatePrecision is 1 side chain coin per main chain coin.
+	ExchangeRatePrecision = 100000000
+
+	// genesisHash is the hash of the genesis block shared by all networks.
+	genesisHash = "56be936978c261b2e649d58dbfaf3f23d4a868274f5522cd2adb4308a955c4a3"
 )
 
 var (
 	Parameters configParams
 	Version    string
-	mainNet    = &ChainParams{
+
+	// removedKeys are the configurations replaced by chain parameters, they
+	// are refused instead of being ignored silently.
+	removedKeys = map[string]string{
+		"ExchangeRate": "ExchangeRates of chain parameters",
+	}
+	mainNet = &ChainParams{
 		Name:               "MainNet",
 		PowLimit:           new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(1)),
 		PowLimitBits:       0x1f0008ff,
@@ -27,6 +43,21 @@ var (
 		MaxOrphanBlocks:    10000,
 		MinMemoryNodes:     20160,
 		SpendCoinbaseSpan:  100,
+		ExchangeRates: []ExchangeRate{
+			{Height: 0, Rate: 10 * ExchangeRatePrecision},
+		},
+		MainChainAnchorHeight: math.MaxUint32,
+		// only the genesis block is checkpointed, checkpoints and the assume
+		// valid block of recent blocks are added at each release.
+		Checkpoints: []Checkpoint{
+			{Height: 0, Hash: genesisHash},
+		},
+		DifficultyAlgorithm:        LWMAAlgorithm,
+		DifficultyActivationHeight: math.MaxUint32,
+		LWMAWindow:                 45,
+		VMUpgradeHeight:            math.MaxUint32,
+		SchnorrHeight:              math.MaxUint32,
+		IdentificationHeight:       math.MaxUint32,
 	}
 	testNet = &ChainParams{
 		Name:               "TestNet",
@@ -38,6 +69,21 @@ var (
 		MaxOrphanBlocks:    10000,
 		MinMemoryNodes:     20160,
 		SpendCoinbaseSpan:  100,
+		ExchangeRates: []ExchangeRate{
+			{Height: 0, Rate: 10 * ExchangeRatePrecision},
+		},
+		MainChainAnchorHeight: 100000,
+		// only the genesis block is checkpointed, checkpoints and the assume
+		// valid block of recent blocks are added at each release.
+		Checkpoints: []Checkpoint{
+			{Height: 0, Hash: genesisHash},
+		},
+		DifficultyAlgorithm:        LWMAAlgorithm,
+		DifficultyActivationHeight: math.MaxUint32,
+		LWMAWindow:                 90,
+		VMUpgradeHeight:            math.MaxUint32,
+		SchnorrHeight:              math.MaxUint32,
+		IdentificationHeight:       math.MaxUint32,
 	}
 	regNet = &ChainParams{
 		Name:               "RegNet",
@@ -49,6 +95,17 @@ var (
 		MaxOrphanBlocks:    10000,
 		MinMemoryNodes:     20160,
 		SpendCoinbaseSpan:  100,
+		ExchangeRates: []ExchangeRate{
+			{Height: 0, Rate: 10 * ExchangeRatePrecision},
+		},
+		MainChainAnchorHeight: 0,
+		Checkpoints: []Checkpoint{
+			{Height: 0, Hash: genesisHash},
+		},
+		NoRetargeting: true,
+		Deployments: []Deployment{
+			{Name: "testdummy", Bit: 28, StartTime: 0, ExpireTime: math.MaxInt64, Threshold: 8},
+		},
 	}
 )
 
@@ -73,7 +130,7 @@ type Configuration struct {
 	SpvMinOutbound             int              `json:"SpvMinOutbound"`
 	SpvMaxConnections          int              `json:"SpvMaxConnections"`
 	SpvPrintLevel              int              `json:"SpvPrintLevel"`
-	ExchangeRate               float64          `json:"ExchangeRate"`
+	SpvLocalVerifierFile       string           `json:"SpvLocalVerifierFile"`
 	MinCrossChainTxFee         int              `json:"MinCrossChainTxFee"`
 	HttpRestPort               int              `json:"HttpRestPort"`
 	RestCertPath               string           `json:"RestCertPath"`
@@ -94,6 +151,7 @@ type Configuration struct {
 	KeyPath                    string           `json:"KeyPath"`
 	CAPath                     string           `json:"CAPath"`
 	MultiCoreNum               uint             `json:"MultiCoreNum"`
+	MaxSigCacheSize            uint             `json:"MaxSigCacheSize"`
 	MaxLogsSize                int64            `json:"MaxLogsSize"`
 	MaxPerLogSize              int64            `json:"MaxPerLogSize"`
 	MaxTxInBlock               int              `json:"MaxTransactionInBlock"`
@@ -101,6 +159,12 @@ type Configuration struct {
 	PowConfiguration           PowConfiguration `json:"PowConfiguration"`
 	FoundationAddress          string           `json:"FoundationAddress"`
 	MainChainFoundationAddress string           `json:"MainChainFoundationAddress"`
+	WalletPath                 string           `json:"WalletPath"`
+	WalletFeePerKB             int              `json:"WalletFeePerKB"`
+	Reindex                    bool             `json:"Reindex"`
+	VerifyChainBlocks          uint32           `json:"VerifyChainBlocks"`
+	PruneDepth                 uint32           `json:"PruneDepth"`
+	ImportSnapshot             string           `json:"ImportSnapshot"`
 }
 
 type ConfigFile struct {
@@ -117,6 +181,134 @@ type ChainParams struct {
 	MaxOrphanBlocks    int
 	MinMemoryNodes     uint32
 	SpendCoinbaseSpan  uint32
+	// ExchangeRates are the exchange rates from main chain to side chain,
+	// ordered by the heights they become effective.
+	ExchangeRates []ExchangeRate
+	// MainChainAnchorHeight is the height from which the main chain block
+	// header in the aux pow of a block must be verified by the SPV module.
+	MainChainAnchorHeight uint32
+	// Checkpoints are blocks known in the chain ordered by height, forks
+	// below the latest checkpoint of the best chain are rejected.
+	Checkpoints []Checkpoint
+	// AssumeValid is a checkpoint which is assumed to have valid ancestors,
+	// signatures of the blocks on the header chain leading to it are not
+	// verified during headers-first sync.
+	AssumeValid Checkpoint
+	// NoRetargeting disables the difficulty adjustment, all blocks use
+	// PowLimitBits.
+	NoRetargeting bool
+	// DifficultyAlgorithm is the difficulty adjustment algorithm used from
+	// DifficultyActivationHeight, RetargetAlgorithm is used before it.
+	DifficultyAlgorithm        DifficultyAlgorithm
+	DifficultyActivationHeight uint32
+	// LWMAWindow is the number of solve times averaged by LWMAAlgorithm.
+	LWMAWindow uint32
+	// Deployments are the consensus changes deployed by version bits, their
+	// states change every TargetTimespan worth of blocks.
+	Deployments []Deployment
+	// VMUpgradeHeight is the height from which programs are executed by the
+	// strict VM rules.
+	VMUpgradeHeight uint32
+	// SchnorrHeight is the height from which the Schnorr signature opcodes
+	// and outputs to Schnorr program hashes are valid.
+	SchnorrHeight uint32
+	// IdentificationHeight is the height from which the payload sign, paths
+	// and proofs of RegisterIdentification transactions are validated, and
+	// the revoke and rotate key transactions are valid.
+	IdentificationHeight uint32
+}
+
+// Deployment is a BIP9 style consensus change deployment. Miners signal Bit in
+// block versions from StartTime, it's locked in when Threshold blocks of a
+// window signal, or fails at ExpireTime. The times are unix times compared
+// with the median time past of blocks.
+type Deployment struct {
+	Name       string
+	Bit        uint8
+	StartTime  int64
+	ExpireTime int64
+	Threshold  uint32
+}
+
+// DifficultyAlgorithm is a difficulty adjustment algorithm.
+type DifficultyAlgorithm byte
+
+const (
+	// RetargetAlgorithm adjusts the difficulty every TargetTimespan worth of
+	// blocks by the time they took, limited by AdjustmentFactor.
+	RetargetAlgorithm DifficultyAlgorithm = iota
+
+	// LWMAAlgorithm adjusts the difficulty every block by the linearly
+	// weighted moving average of the last LWMAWindow solve times.
+	LWMAAlgorithm
+)
+
+// GetDifficultyAlgorithm returns the difficulty adjustment algorithm of the
+// block at height.
+func (p *ChainParams) GetDifficultyAlgorithm(height uint32) DifficultyAlgorithm {
+	if height >= p.DifficultyActivationHeight {
+		return p.DifficultyAlgorithm
+	}
+	return RetargetAlgorithm
+}
+
+// Checkpoint is the hash of the block at Height, the hash is the reversed hex
+// string as shown by RPC.
+type Checkpoint struct {
+	Height uint32
+	Hash   string
+}
+
+// ExchangeRate is the fixed point exchange rate effective since Height.
+type ExchangeRate struct {
+	Height uint32
+	Rate   int64
+}
+
+// GetExchangeRate returns the exchange rate effective at height.
+func (p *ChainParams) GetExchangeRate(height uint32) int64 {
+	var rate int64
+	for _, r := range p.ExchangeRates {
+		if r.Height > height {
+			break
+		}
+		rate = r.Rate
+	}
+	return rate
+}
+
+// checkExchangeRates checks the exchange rates are positive and ordered by
+// increasing heights, and the first one is effective from the genesis block.
+func (p *ChainParams) checkExchangeRates() error {
+	if len(p.ExchangeRates) == 0 || p.ExchangeRates[0].Height != 0 {
+		return errors.New("the first exchange rate must be effective at height 0")
+	}
+	for i, r := range p.ExchangeRates {
+		if r.Rate <= 0 {
+			return fmt.Errorf("exchange rate at height %d is not positive", r.Height)
+		}
+		if i > 0 && r.Height <= p.ExchangeRates[i-1].Height {
+			return fmt.Errorf("exchange rate at height %d is not ordered by height", r.Height)
+		}
+	}
+	return nil
+}
+
+// checkRemovedKeys returns error if the configuration file contains removed
+// configurations.
+func checkRemovedKeys(file []byte) error {
+	var raw struct {
+		Configuration map[string]json.RawMessage `json:"Configuration"`
+	}
+	if err := json.Unmarshal(file, &raw); err != nil {
+		return err
+	}
+	for key, replacement := range removedKeys {
+		if _, ok := raw.Configuration[key]; ok {
+			return fmt.Errorf("%s is removed, it's replaced by %s", key, replacement)
+		}
+	}
+	return nil
 }
 
 type configParams struct {
@@ -139,6 +331,10 @@ func init() {
 		log.Fatalf("Unmarshal json file erro %v", e)
 		os.Exit(1)
 	}
+	if e = checkRemovedKeys(file); e != nil {
+		log.Fatalf("Invalid configuration %v", e)
+		os.Exit(1)
+	}
 	//	Parameters = &(config.ConfigFile)
 	Parameters.Configuration = &(config.ConfigFile)
 	if Parameters.PowConfiguration.ActiveNet == "MainNet" {
@@ -148,4 +344,16 @@ func init() {
 	} else if Parameters.PowConfiguration.ActiveNet == "RegNet" {
 		Parameters.ChainParam = regNet
 	}
+	// the local verifier has no main chain peers to send the main chain
+	// rollback, it's only allowed on the regnet without a main chain
+	if Parameters.SpvLocalVerifierFile != "" && Parameters.PowConfiguration.ActiveNet != "RegNet" {
+		log.Fatalf("Invalid configuration SpvLocalVerifierFile is only allowed on RegNet")
+		os.Exit(1)
+	}
+	if Parameters.ChainParam != nil {
+		if e = Parameters.ChainParam.checkExchangeRates(); e != nil {
+			log.Fatalf("Invalid chain parameters %v", e)
+			os.Exit(1)
+		}
+	}
 }
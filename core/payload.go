@@ -30,10 +30,16 @@ func GetPayload(txType TransactionType) (Payload, error) {
 		p = new(PayloadRecord)
 	case RechargeToSideChain:
 		p = new(PayloadRechargeToSideChain)
+	case WithdrawFromSideChain:
+		p = new(PayloadWithdrawFromSideChain)
 	case TransferCrossChainAsset:
 		p = new(PayloadTransferCrossChainAsset)
 	case RegisterIdentification:
 		p = new(PayloadRegisterIdentification)
+	case RevokeIdentification:
+		p = new(PayloadRevokeIdentification)
+	case RotateIdentificationKey:
+		p = new(PayloadRotateIdentificationKey)
 	default:
 		return nil, errors.New("[Transaction], invalid transaction type.")
 	}
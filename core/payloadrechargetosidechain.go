@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 
+	"github.com/elastos/Elastos.ELA/bloom"
 	ela "github.com/elastos/Elastos.ELA/core"
 	"github.com/elastos/Elastos.ELA.Utility/common"
 )
@@ -58,4 +59,14 @@ func (t *PayloadRechargeToSideChain) GetMainchainTxHash() (*common.Uint256, erro
 
 	hash := mainchainTx.Hash()
 	return &hash, nil
-}
\ No newline at end of file
+}
+
+// GetMerkleProof returns the merkle proof of the main chain transaction, which
+// has the hash and height of the main chain block.
+func (t *PayloadRechargeToSideChain) GetMerkleProof() (*bloom.MerkleProof, error) {
+	proof := new(bloom.MerkleProof)
+	if err := proof.Deserialize(bytes.NewReader(t.MerkleProof)); err != nil {
+		return nil, errors.New("RechargeToSideChain merkle proof deserialize failed")
+	}
+	return proof, nil
+}
@@ -4,12 +4,21 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"strings"
 
 	"github.com/elastos/Elastos.ELA.Utility/common"
 )
 
 const RegisterIdentificationVersion = 0x00
 
+const (
+	// MaxIdentificationPathLength is the max length of a content path.
+	MaxIdentificationPathLength = 256
+
+	// MaxIdentificationProofLength is the max length of a value proof.
+	MaxIdentificationProofLength = 1024
+)
+
 type RegisterIdentificationValue struct {
 	DataHash common.Uint256
 	Proof    string
@@ -88,6 +97,68 @@ func (a *PayloadRegisterIdentification) GetData() []byte {
 	return a.Data(RegisterIdentificationVersion)
 }
 
+// SignData returns the data signed by Sign, which is the payload without Sign.
+func (a *PayloadRegisterIdentification) SignData() []byte {
+	buf := new(bytes.Buffer)
+	common.WriteVarString(buf, a.ID)
+	common.WriteVarUint(buf, uint64(len(a.Contents)))
+	for _, content := range a.Contents {
+		content.Serialize(buf, RegisterIdentificationVersion)
+	}
+	return buf.Bytes()
+}
+
+// CheckContents checks contents are not empty, paths are valid and unique,
+// and each path has values with proofs in size limit.
+func (a *PayloadRegisterIdentification) CheckContents() error {
+	if len(a.Contents) == 0 {
+		return errors.New("[RegisterIdentification], contents is empty.")
+	}
+
+	paths := make(map[string]struct{}, len(a.Contents))
+	for _, content := range a.Contents {
+		if err := CheckIdentificationPath(content.Path); err != nil {
+			return err
+		}
+		if _, ok := paths[content.Path]; ok {
+			return errors.New("[RegisterIdentification], duplicated path " + content.Path + ".")
+		}
+		paths[content.Path] = struct{}{}
+
+		if len(content.Values) == 0 {
+			return errors.New("[RegisterIdentification], values of path " + content.Path + " is empty.")
+		}
+		for _, value := range content.Values {
+			if len(value.Proof) > MaxIdentificationProofLength {
+				return errors.New("[RegisterIdentification], proof is too long.")
+			}
+		}
+	}
+	return nil
+}
+
+// CheckIdentificationPath checks a path is segments of letters, digits, "_"
+// or "-" separated by "/", like "kyc/person/identityCard".
+func CheckIdentificationPath(path string) error {
+	if len(path) == 0 {
+		return errors.New("[RegisterIdentification], path is empty.")
+	}
+	if len(path) > MaxIdentificationPathLength {
+		return errors.New("[RegisterIdentification], path is too long.")
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if len(segment) == 0 {
+			return errors.New("[RegisterIdentification], path has empty segment.")
+		}
+		for _, c := range segment {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+				return errors.New("[RegisterIdentification], invalid character in path.")
+			}
+		}
+	}
+	return nil
+}
+
 func (a *RegisterIdentificationContent) Serialize(w io.Writer, version byte) error {
 	if err := common.WriteVarString(w, a.Path); err != nil {
 		return errors.New("[RegisterIdentificationContent], path serialize failed.")
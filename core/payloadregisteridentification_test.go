@@ -2,6 +2,7 @@ package core
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/elastos/Elastos.ELA.Utility/common"
@@ -63,3 +64,64 @@ func TestPayloadRegisterIdentification_Deserialize(t *testing.T) {
 		t.Error("ID content values proof deserialize error!")
 	}
 }
+
+func TestPayloadRegisterIdentification_CheckContents(t *testing.T) {
+	value := RegisterIdentificationValue{DataHash: common.Uint256{1}, Proof: "proof"}
+	longProof := RegisterIdentificationValue{Proof: string(make([]byte, MaxIdentificationProofLength+1))}
+	tests := []struct {
+		name     string
+		contents []RegisterIdentificationContent
+		valid    bool
+	}{
+		{"valid", []RegisterIdentificationContent{
+			{Path: "kyc/person/identityCard", Values: []RegisterIdentificationValue{value}},
+			{Path: "kyc/person/phone_number-1", Values: []RegisterIdentificationValue{value, value}},
+		}, true},
+		{"empty contents", nil, false},
+		{"empty path", []RegisterIdentificationContent{
+			{Path: "", Values: []RegisterIdentificationValue{value}},
+		}, false},
+		{"duplicated path", []RegisterIdentificationContent{
+			{Path: "kyc/person/phone", Values: []RegisterIdentificationValue{value}},
+			{Path: "kyc/person/phone", Values: []RegisterIdentificationValue{value}},
+		}, false},
+		{"leading slash", []RegisterIdentificationContent{
+			{Path: "/kyc/person", Values: []RegisterIdentificationValue{value}},
+		}, false},
+		{"trailing slash", []RegisterIdentificationContent{
+			{Path: "kyc/person/", Values: []RegisterIdentificationValue{value}},
+		}, false},
+		{"empty segment", []RegisterIdentificationContent{
+			{Path: "kyc//person", Values: []RegisterIdentificationValue{value}},
+		}, false},
+		{"invalid character", []RegisterIdentificationContent{
+			{Path: "kyc/person/../phone", Values: []RegisterIdentificationValue{value}},
+		}, false},
+		{"space in path", []RegisterIdentificationContent{
+			{Path: "kyc/person phone", Values: []RegisterIdentificationValue{value}},
+		}, false},
+		{"path too long", []RegisterIdentificationContent{
+			{Path: strings.Repeat("a", MaxIdentificationPathLength+1), Values: []RegisterIdentificationValue{value}},
+		}, false},
+		{"max path length", []RegisterIdentificationContent{
+			{Path: strings.Repeat("a", MaxIdentificationPathLength), Values: []RegisterIdentificationValue{value}},
+		}, true},
+		{"empty values", []RegisterIdentificationContent{
+			{Path: "kyc/person/phone"},
+		}, false},
+		{"proof too long", []RegisterIdentificationContent{
+			{Path: "kyc/person/phone", Values: []RegisterIdentificationValue{longProof}},
+		}, false},
+	}
+
+	for _, test := range tests {
+		payload := &PayloadRegisterIdentification{ID: "ij8rfb6A4Ri7c5CRE1nDVdVCUMuUxkk2c6", Contents: test.contents}
+		err := payload.CheckContents()
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error %s", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expect error", test.name)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+const RevokeIdentificationVersion = 0x00
+
+// PayloadRevokeIdentification revokes registered paths of an ID, the
+// transaction must be signed by the current key of the ID.
+type PayloadRevokeIdentification struct {
+	ID    string
+	Paths []string
+}
+
+func (a *PayloadRevokeIdentification) Data(version byte) []byte {
+	buf := new(bytes.Buffer)
+	a.Serialize(buf, version)
+	return buf.Bytes()
+}
+
+func (a *PayloadRevokeIdentification) Serialize(w io.Writer, version byte) error {
+	if err := common.WriteVarString(w, a.ID); err != nil {
+		return errors.New("[RevokeIdentification], ID serialize failed.")
+	}
+
+	if err := common.WriteVarUint(w, uint64(len(a.Paths))); err != nil {
+		return errors.New("[RevokeIdentification], Paths size serialize failed.")
+	}
+
+	for _, path := range a.Paths {
+		if err := common.WriteVarString(w, path); err != nil {
+			return errors.New("[RevokeIdentification], path serialize failed.")
+		}
+	}
+
+	return nil
+}
+
+func (a *PayloadRevokeIdentification) Deserialize(r io.Reader, version byte) error {
+	var err error
+	a.ID, err = common.ReadVarString(r)
+	if err != nil {
+		return errors.New("[RevokeIdentification], ID deserialize failed.")
+	}
+
+	size, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return errors.New("[RevokeIdentification], Paths size deserialize failed.")
+	}
+
+	// size is not trusted, paths are appended as they are read
+	a.Paths = nil
+	for i := uint64(0); i < size; i++ {
+		path, err := common.ReadVarString(r)
+		if err != nil {
+			return errors.New("[RevokeIdentification], path deserialize failed.")
+		}
+		a.Paths = append(a.Paths, path)
+	}
+
+	return nil
+}
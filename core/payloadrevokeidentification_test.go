@@ -0,0 +1,39 @@
+package core
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+func TestPayloadRevokeIdentification_Deserialize(t *testing.T) {
+	payload := &PayloadRevokeIdentification{
+		ID:    "ij8rfb6A4Ri7c5CRE1nDVdVCUMuUxkk2c6",
+		Paths: []string{"kyc/person/identityCard", "kyc/person/phone"},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := payload.Serialize(buf, RevokeIdentificationVersion); err != nil {
+		t.Error("ID serialize error!")
+	}
+
+	payload2 := PayloadRevokeIdentification{}
+	if err := payload2.Deserialize(bytes.NewReader(buf.Bytes()), RevokeIdentificationVersion); err != nil {
+		t.Error("ID deserialize error!")
+	}
+	if payload2.ID != payload.ID || len(payload2.Paths) != 2 ||
+		payload2.Paths[0] != payload.Paths[0] || payload2.Paths[1] != payload.Paths[1] {
+		t.Error("ID paths deserialize error!")
+	}
+
+	// a huge paths size must not be allocated before paths are read
+	buf = new(bytes.Buffer)
+	common.WriteVarString(buf, payload.ID)
+	common.WriteVarUint(buf, math.MaxUint64)
+	common.WriteVarString(buf, payload.Paths[0])
+	if err := payload2.Deserialize(bytes.NewReader(buf.Bytes()), RevokeIdentificationVersion); err == nil {
+		t.Error("truncated paths deserialized without error!")
+	}
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+const RotateIdentificationKeyVersion = 0x00
+
+// PayloadRotateIdentificationKey changes the key controls an ID, the ID keeps
+// unchanged while later transactions of the ID must be signed by the new key.
+type PayloadRotateIdentificationKey struct {
+	ID string
+	// PublicKey is the encoded public key of the new key
+	PublicKey []byte
+}
+
+func (a *PayloadRotateIdentificationKey) Data(version byte) []byte {
+	buf := new(bytes.Buffer)
+	a.Serialize(buf, version)
+	return buf.Bytes()
+}
+
+func (a *PayloadRotateIdentificationKey) Serialize(w io.Writer, version byte) error {
+	if err := common.WriteVarString(w, a.ID); err != nil {
+		return errors.New("[RotateIdentificationKey], ID serialize failed.")
+	}
+
+	if err := common.WriteVarBytes(w, a.PublicKey); err != nil {
+		return errors.New("[RotateIdentificationKey], PublicKey serialize failed.")
+	}
+
+	return nil
+}
+
+func (a *PayloadRotateIdentificationKey) Deserialize(r io.Reader, version byte) error {
+	var err error
+	a.ID, err = common.ReadVarString(r)
+	if err != nil {
+		return errors.New("[RotateIdentificationKey], ID deserialize failed.")
+	}
+
+	a.PublicKey, err = common.ReadVarBytes(r)
+	if err != nil {
+		return errors.New("[RotateIdentificationKey], PublicKey deserialize failed.")
+	}
+
+	return nil
+}
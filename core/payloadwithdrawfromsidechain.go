@@ -0,0 +1,78 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	ela "github.com/elastos/Elastos.ELA/core"
+)
+
+const WithdrawFromSideChainPayloadVersion byte = 0x00
+
+// PayloadWithdrawFromSideChain proves the main chain withdraw transaction by
+// merkle proof, the side chain transactions in it's payload are processed.
+type PayloadWithdrawFromSideChain struct {
+	MerkleProof          []byte
+	MainChainTransaction []byte
+}
+
+func (t *PayloadWithdrawFromSideChain) Data(version byte) []byte {
+	buf := new(bytes.Buffer)
+	if err := t.Serialize(buf, version); err != nil {
+		return []byte{0}
+	}
+
+	return buf.Bytes()
+}
+
+func (t *PayloadWithdrawFromSideChain) Serialize(w io.Writer, version byte) error {
+	err := common.WriteVarBytes(w, t.MerkleProof)
+	if err != nil {
+		return errors.New("[PayloadWithdrawFromSideChain], MerkleProof serialize failed.")
+	}
+	err = common.WriteVarBytes(w, t.MainChainTransaction)
+	if err != nil {
+		return errors.New("[PayloadWithdrawFromSideChain], MainChainTransaction serialize failed.")
+	}
+	return nil
+}
+
+func (t *PayloadWithdrawFromSideChain) Deserialize(r io.Reader, version byte) error {
+	var err error
+	if t.MerkleProof, err = common.ReadVarBytes(r); err != nil {
+		return errors.New("[PayloadWithdrawFromSideChain], MerkleProof deserialize failed.")
+	}
+
+	if t.MainChainTransaction, err = common.ReadVarBytes(r); err != nil {
+		return errors.New("[PayloadWithdrawFromSideChain], MainChainTransaction deserialize failed.")
+	}
+	return nil
+}
+
+func (t *PayloadWithdrawFromSideChain) GetMainchainTxHash() (*common.Uint256, error) {
+	mainchainTx, _, err := t.GetMainchainPayload()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := mainchainTx.Hash()
+	return &hash, nil
+}
+
+// GetMainchainPayload returns the main chain withdraw transaction and it's
+// payload.
+func (t *PayloadWithdrawFromSideChain) GetMainchainPayload() (*ela.Transaction, *ela.PayloadWithdrawFromSideChain, error) {
+	mainchainTx := new(ela.Transaction)
+	reader := bytes.NewReader(t.MainChainTransaction)
+	if err := mainchainTx.Deserialize(reader); err != nil {
+		return nil, nil, errors.New("WithdrawFromSideChain mainChainTransaction deserialize failed")
+	}
+
+	payload, ok := mainchainTx.Payload.(*ela.PayloadWithdrawFromSideChain)
+	if !ok {
+		return nil, nil, errors.New("Invalid payload ela.PayloadWithdrawFromSideChain")
+	}
+	return mainchainTx, payload, nil
+}
@@ -26,6 +26,8 @@ const (
 	WithdrawFromSideChain   TransactionType = 0x07
 	TransferCrossChainAsset TransactionType = 0x08
 	RegisterIdentification  TransactionType = 0x09
+	RevokeIdentification    TransactionType = 0x0a
+	RotateIdentificationKey TransactionType = 0x0b
 )
 
 func (self TransactionType) Name() string {
@@ -50,6 +52,10 @@ func (self TransactionType) Name() string {
 		return "TransferCrossChainAsset"
 	case RegisterIdentification:
 		return "RegisterIdentification"
+	case RevokeIdentification:
+		return "RevokeIdentification"
+	case RotateIdentificationKey:
+		return "RotateIdentificationKey"
 	default:
 		return "Unknown"
 	}
@@ -291,6 +297,10 @@ func (tx *Transaction) IsRechargeToSideChainTx() bool {
 	return tx.TxType == RechargeToSideChain
 }
 
+func (tx *Transaction) IsWithdrawFromSideChainTx() bool {
+	return tx.TxType == WithdrawFromSideChain
+}
+
 func (tx *Transaction) IsTransferCrossChainAssetTx() bool {
 	return tx.TxType == TransferCrossChainAsset
 }
@@ -299,6 +309,20 @@ func (tx *Transaction) IsRegisterIdentificationTx() bool {
 	return tx.TxType == RegisterIdentification
 }
 
+func (tx *Transaction) IsRevokeIdentificationTx() bool {
+	return tx.TxType == RevokeIdentification
+}
+
+func (tx *Transaction) IsRotateIdentificationKeyTx() bool {
+	return tx.TxType == RotateIdentificationKey
+}
+
+// IsIdentificationTx returns if the transaction is signed by an ID program.
+func (tx *Transaction) IsIdentificationTx() bool {
+	return tx.IsRegisterIdentificationTx() || tx.IsRevokeIdentificationTx() ||
+		tx.IsRotateIdentificationKeyTx()
+}
+
 func NewTrimmedTx(hash Uint256) *Transaction {
 	tx := new(Transaction)
 	tx.hash, _ = Uint256FromBytes(hash[:])
@@ -23,10 +23,14 @@ const (
 	ErrIneffectiveCoinbase  ErrCode = 45018
 	ErrUTXOLocked           ErrCode = 45019
 	ErrRechargeToSideChain  ErrCode = 45020
+	ErrIdentification       ErrCode = 45021
+	ErrWithdrawal           ErrCode = 45022
 
 	SessionExpired          ErrCode = 41001
 	IllegalDataFormat       ErrCode = 41003
 	PowServiceNotStarted    ErrCode = 41004
+	WalletNotEnabled        ErrCode = 41005
+	WalletLocked            ErrCode = 41006
 	InvalidMethod           ErrCode = 42001
 	InvalidParams           ErrCode = 42002
 	InvalidToken            ErrCode = 42003
@@ -44,6 +48,8 @@ var ErrMap = map[ErrCode]string{
 	SessionExpired:          "Session expired",
 	IllegalDataFormat:       "Illegal Dataformat",
 	PowServiceNotStarted:    "pow service not started",
+	WalletNotEnabled:        "wallet not enabled",
+	WalletLocked:            "wallet is locked, unlock it by walletpassphrase",
 	InvalidMethod:           "Invalid method",
 	InvalidParams:           "Invalid Params",
 	InvalidToken:            "Verify token error",
@@ -68,6 +74,8 @@ var ErrMap = map[ErrCode]string{
 	ErrUnknownReferedTxn:    "INTERNAL ERROR, ErrUnknownReferedTxn",
 	ErrInvalidReferedTxn:    "INTERNAL ERROR, ErrInvalidReferedTxn",
 	ErrIneffectiveCoinbase:  "INTERNAL ERROR, ErrIneffectiveCoinbase",
+	ErrIdentification:       "INTERNAL ERROR, ErrIdentification",
+	ErrWithdrawal:           "INTERNAL ERROR, ErrWithdrawal",
 }
 
 func (code ErrCode) Message() string {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"os"
 	"runtime"
 
@@ -56,6 +57,19 @@ func startConsensus(noder protocol.Noder) {
 	}
 }
 
+// importSnapshot loads the snapshot file into the fresh chain store, it is
+// skipped once the snapshot is imported.
+func importSnapshot(store blockchain.IChainStore, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = store.ImportSnapshot(bufio.NewReader(file))
+	return err
+}
+
 func main() {
 	//var blockChain *ledger.Blockchain
 	var err error
@@ -68,15 +82,53 @@ func main() {
 		goto ERROR
 	}
 	defer chainStore.Close()
+	if path := config.Parameters.ImportSnapshot; path != "" {
+		if err := importSnapshot(chainStore, path); err == blockchain.ErrChainStoreNotEmpty {
+			log.Warn("Snapshot is not imported, the chain store has other blocks")
+		} else if err != nil {
+			log.Fatal(err, "Import snapshot failed")
+			goto ERROR
+		}
+	}
 
 	err = blockchain.Init(chainStore)
 	if err != nil {
 		log.Fatal(err, "BlockChain initialize failed")
 		goto ERROR
 	}
+	// an unfinished reindex is restarted
+	if config.Parameters.Reindex || chainStore.IsReindexing() {
+		if err := chainStore.Reindex(); err != nil {
+			log.Fatal(err, "Reindex failed")
+			goto ERROR
+		}
+	}
+	if err := chainStore.BuildWithdrawalIndex(); err != nil {
+		log.Fatal(err, "Build withdrawal index failed")
+		goto ERROR
+	}
+	if blocks := config.Parameters.VerifyChainBlocks; blocks > 0 {
+		if err := chainStore.VerifyChain(blocks); err != nil {
+			log.Fatal(err, "Verify chain failed")
+			goto ERROR
+		}
+	}
+	if err := chainStore.Prune(); err != nil {
+		log.Fatal(err, "Prune blocks failed")
+		goto ERROR
+	}
 
 	log.Info("2. SPV module init")
-	if err := spv.SpvInit(); err != nil {
+	if path := config.Parameters.SpvLocalVerifierFile; path != "" {
+		// verify by the local main chain data instead of main chain peers,
+		// the config allows it only on RegNet
+		verifier, err := spv.LoadLocalVerifier(path)
+		if err != nil {
+			log.Fatal(err, "SPV local verifier load failed")
+			goto ERROR
+		}
+		spv.SetVerifier(verifier)
+	} else if err := spv.SpvInit(blockchain.DefaultLedger.Blockchain.BCEvents); err != nil {
 		log.Fatal(err, "SPV module initialize failed")
 		goto ERROR
 	}
@@ -88,6 +140,14 @@ func main() {
 	servers.NodeForServers = noder
 	startConsensus(noder)
 
+	if config.Parameters.WalletPath != "" {
+		log.Info("Open wallet ", config.Parameters.WalletPath)
+		if err := servers.InitWallet(); err != nil {
+			log.Fatal(err, "Wallet initialize failed")
+			goto ERROR
+		}
+	}
+
 	log.Info("4. --Start the RPC service")
 	go httpjsonrpc.StartRPCServer()
 	go httprestful.StartServer()
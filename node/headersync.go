@@ -0,0 +1,426 @@
+package node
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	chain "github.com/elastos/Elastos.ELA.SideChain/blockchain"
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+	"github.com/elastos/Elastos.ELA.SideChain/log"
+	. "github.com/elastos/Elastos.ELA.SideChain/protocol"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/p2p"
+	"github.com/elastos/Elastos.ELA.Utility/p2p/msg"
+)
+
+// maxHeadersAhead is the max number of checked headers waiting for their
+// blocks, more headers are requested once the blocks are downloaded.
+const maxHeadersAhead = MaxHeadersPerMsg * 5
+
+type blockRequest struct {
+	header *chain.BlockNode
+	peer   Noder
+	time   time.Time
+}
+
+// headerSync is the state of headers-first sync. The header chain is requested
+// from the sync peer and checked first, then the blocks of the checked headers
+// are requested from the peers in parallel within the download window.
+type headerSync struct {
+	sync.Mutex
+	syncPeer   Noder
+	headerTime time.Time // the time of the getheaders not responded
+	headerDone bool      // the sync peer has no more headers
+	tip        *chain.BlockNode
+	queue      []*chain.BlockNode // the headers of the blocks to request
+	// fork is the tip of the headers from the sync peer forking from the
+	// chain of the tip, its blocks are requested once it has more work.
+	fork      *chain.BlockNode
+	forkQueue []*chain.BlockNode
+	requested map[common.Uint256]*blockRequest
+	stalled   map[uint64]time.Time // the time peers stalled a block
+}
+
+func (node *node) IsHeaderFirstMode() bool {
+	node.headerSync.Lock()
+	defer node.headerSync.Unlock()
+	return node.headerFirstMode
+}
+
+// bestHeadersNoder returns the highest peer which serves headers except
+// the peer to exclude.
+func (node *node) bestHeadersNoder(exclude Noder) Noder {
+	var best Noder
+	for _, n := range node.GetNeighborNoder() {
+		if n.State() != p2p.ESTABLISH || n.IsSyncFailed() ||
+			n.Services()&HeadersService == 0 {
+			continue
+		}
+		if exclude != nil && n.ID() == exclude.ID() {
+			continue
+		}
+		if best == nil || n.Height() > best.Height() {
+			best = n
+		}
+	}
+	return best
+}
+
+// startHeaderSync starts headers-first sync from the best chain with the peer.
+func (node *node) startHeaderSync(peer Noder) {
+	node.headerSync.Lock()
+	defer node.headerSync.Unlock()
+
+	log.Info("Start headers-first sync with peer ", peer.ID())
+	node.headerFirstMode = true
+	node.headerSync.tip = chain.DefaultLedger.Blockchain.BestChain
+	node.headerSync.queue = nil
+	node.headerSync.fork = nil
+	node.headerSync.forkQueue = nil
+	node.headerSync.requested = make(map[common.Uint256]*blockRequest)
+	node.headerSync.stalled = make(map[uint64]time.Time)
+	node.ResetRequestedBlock()
+	node.SetSyncHeaders(true)
+	node.setSyncPeer(peer)
+}
+
+// stopHeaderSync stops headers-first sync, it must be called with the lock.
+func (node *node) stopHeaderSync() {
+	log.Info("Stop headers-first sync at height ", chain.DefaultLedger.Blockchain.GetBestHeight())
+	if node.headerSync.syncPeer != nil {
+		node.headerSync.syncPeer.SetSyncHeaders(false)
+		node.headerSync.syncPeer = nil
+	}
+	node.headerFirstMode = false
+	node.headerSync.tip = nil
+	node.headerSync.queue = nil
+	node.headerSync.fork = nil
+	node.headerSync.forkQueue = nil
+	node.headerSync.requested = nil
+	node.headerSync.stalled = nil
+	node.ResetRequestedBlock()
+	node.SetSyncHeaders(false)
+}
+
+// setSyncPeer changes the peer to request headers from the tip, it must be
+// called with the lock.
+func (node *node) setSyncPeer(peer Noder) {
+	if node.headerSync.syncPeer != nil {
+		node.headerSync.syncPeer.SetSyncHeaders(false)
+	}
+	node.headerSync.syncPeer = peer
+	node.headerSync.headerDone = false
+	node.headerSync.fork = nil
+	node.headerSync.forkQueue = nil
+	peer.SetSyncHeaders(true)
+	node.requestHeaders()
+}
+
+// changeSyncPeer changes the sync peer to the best other peer serving headers,
+// and stops headers-first sync if there is none. It must be called with the
+// lock.
+func (node *node) changeSyncPeer() {
+	peer := node.bestHeadersNoder(node.headerSync.syncPeer)
+	if peer == nil {
+		node.stopHeaderSync()
+		return
+	}
+	log.Infof("Sync headers with peer %d", peer.ID())
+	node.setSyncPeer(peer)
+}
+
+// requestHeaders requests the headers after the tip, or after the fork being
+// downloaded, from the sync peer. It must be called with the lock.
+func (node *node) requestHeaders() {
+	bc := chain.DefaultLedger.Blockchain
+	locator, err := bc.LatestBlockLocator()
+	if err != nil {
+		log.Errorf("Failed to get block locator for the latest block: %v", err)
+		return
+	}
+	// the tip is not in the chain if its block is not downloaded
+	if tip := node.headerSync.tip; tip != nil && !tip.Hash.IsEqual(*locator[0]) {
+		locator = append([]*common.Uint256{tip.Hash}, locator...)
+	}
+	if fork := node.headerSync.fork; fork != nil {
+		locator = append([]*common.Uint256{fork.Hash}, locator...)
+	}
+
+	node.headerSync.headerTime = time.Now()
+	node.headerSync.syncPeer.Send(NewGetHeaders(locator, common.EmptyHash))
+}
+
+// onHeaders checks the headers from the sync peer and requests their blocks.
+// The headers extend the tip, or fork from a block in the chain when the sync
+// peer is on another fork, the sync peer is disconnected if they connect to
+// neither.
+func (node *node) onHeaders(peer Noder, headers []*core.Header) {
+	node.headerSync.Lock()
+	defer node.headerSync.Unlock()
+
+	hs := &node.headerSync
+	if !node.headerFirstMode || hs.syncPeer == nil || hs.syncPeer.ID() != peer.ID() {
+		return
+	}
+	hs.headerTime = time.Time{}
+	if len(headers) == 0 {
+		hs.headerDone = true
+		hs.fork, hs.forkQueue = nil, nil
+		return
+	}
+
+	bc := chain.DefaultLedger.Blockchain
+	prevNode, onFork := hs.tip, false
+	previous := headers[0].Previous
+	switch {
+	case previous.IsEqual(*hs.tip.Hash):
+		hs.fork, hs.forkQueue = nil, nil
+	case hs.fork != nil && previous.IsEqual(*hs.fork.Hash):
+		prevNode, onFork = hs.fork, true
+	default:
+		forkNode, ok := bc.LookupNodeInIndex(&previous)
+		if !ok {
+			log.Errorf("Headers from peer %d do not connect", peer.ID())
+			peer.CloseConn()
+			node.changeSyncPeer()
+			return
+		}
+		prevNode, onFork = forkNode, true
+		hs.forkQueue = nil
+	}
+
+	for _, header := range headers {
+		if !header.Previous.IsEqual(*prevNode.Hash) {
+			log.Errorf("Headers from peer %d are not continuous", peer.ID())
+			peer.CloseConn()
+			node.changeSyncPeer()
+			return
+		}
+		if err := bc.CheckBlockHeader(header, prevNode); err != nil {
+			log.Errorf("Invalid header from peer %d: %v", peer.ID(), err)
+			peer.CloseConn()
+			node.stopHeaderSync()
+			return
+		}
+
+		hash := header.Hash()
+		headerNode := chain.NewBlockNode(header, &hash)
+		headerNode.Parent = prevNode
+		headerNode.WorkSum.Add(prevNode.WorkSum, headerNode.WorkSum)
+		bc.AddAssumedValidHeaders(headerNode)
+		prevNode = headerNode
+		if chain.DefaultLedger.BlockInLedger(hash) {
+			continue
+		}
+		if onFork {
+			hs.forkQueue = append(hs.forkQueue, headerNode)
+		} else {
+			hs.queue = append(hs.queue, headerNode)
+		}
+	}
+
+	if !onFork {
+		hs.tip = prevNode
+	} else if prevNode.WorkSum.Cmp(hs.tip.WorkSum) > 0 {
+		// the blocks of the old fork are not requested any more
+		log.Infof("Headers from peer %d fork with more work at height %d", peer.ID(), prevNode.Height)
+		hs.tip, hs.queue = prevNode, hs.forkQueue
+		hs.fork, hs.forkQueue = nil, nil
+	} else {
+		hs.fork = prevNode
+	}
+	node.trimHeaderNodes(hs.tip)
+	if hs.fork != nil {
+		node.trimHeaderNodes(hs.fork)
+	}
+
+	if len(headers) < MaxHeadersPerMsg {
+		// the fork of the sync peer has no more work than the tip
+		hs.headerDone = true
+		hs.fork, hs.forkQueue = nil, nil
+	} else if hs.fork != nil || len(hs.queue) < maxHeadersAhead {
+		node.requestHeaders()
+	}
+	node.requestBlocks()
+}
+
+// trimHeaderNodes unlinks the old header nodes from the tip, the nodes of the
+// last MinMemoryNodes headers are kept to check the next headers. It must be
+// called with the lock.
+func (node *node) trimHeaderNodes(tip *chain.BlockNode) {
+	headerNode := tip
+	for i := uint32(0); i < chain.MinMemoryNodes && headerNode != nil; i++ {
+		headerNode = headerNode.Parent
+	}
+	if headerNode == nil {
+		return
+	}
+	// the nodes of the chain are not changed
+	if indexNode, ok := chain.DefaultLedger.Blockchain.LookupNodeInIndex(headerNode.Hash); ok &&
+		indexNode == headerNode {
+		return
+	}
+	headerNode.Parent = nil
+}
+
+// requestBlocks requests the blocks of the queued headers within the download
+// window, each block is requested from the peer with the least blocks in
+// flight. It must be called with the lock.
+func (node *node) requestBlocks() {
+	hs := &node.headerSync
+	now := time.Now()
+	inFlight := make(map[uint64]int)
+	for _, req := range hs.requested {
+		inFlight[req.peer.ID()]++
+	}
+
+	var peers []Noder
+	for _, n := range node.GetNeighborNoder() {
+		if n.State() != p2p.ESTABLISH || n.IsSyncFailed() {
+			continue
+		}
+		if stalled, ok := hs.stalled[n.ID()]; ok && now.Before(stalled.Add(time.Second*BlockStallTimeout)) {
+			continue
+		}
+		peers = append(peers, n)
+	}
+
+	getData := make(map[uint64]*msg.GetData)
+	maxHeight := chain.DefaultLedger.Blockchain.GetBestHeight() + BlockDownloadWindow
+	var queue []*chain.BlockNode
+	for i, header := range hs.queue {
+		if header.Height > maxHeight {
+			queue = append(queue, hs.queue[i:]...)
+			break
+		}
+
+		var peer Noder
+		for _, n := range peers {
+			if inFlight[n.ID()] >= MaxBlocksInFlight || n.Height() < uint64(header.Height) {
+				continue
+			}
+			// pruned peers only have the recent blocks
+			if n.Services()&PrunedService != 0 && uint64(header.Height)+chain.MinPruneDepth <= n.Height() {
+				continue
+			}
+			if peer == nil || inFlight[n.ID()] < inFlight[peer.ID()] {
+				peer = n
+			}
+		}
+		if peer == nil {
+			queue = append(queue, header)
+			continue
+		}
+
+		inFlight[peer.ID()]++
+		hs.requested[*header.Hash] = &blockRequest{header: header, peer: peer, time: now}
+		node.AddRequestedBlock(*header.Hash)
+		if _, ok := getData[peer.ID()]; !ok {
+			getData[peer.ID()] = msg.NewGetData()
+		}
+		getData[peer.ID()].AddInvVect(msg.NewInvVect(msg.InvTypeBlock, header.Hash))
+	}
+	hs.queue = queue
+
+	for _, n := range peers {
+		if data, ok := getData[n.ID()]; ok {
+			n.Send(data)
+		}
+	}
+
+	if hs.headerDone || !hs.headerTime.IsZero() || len(hs.queue) >= maxHeadersAhead {
+		return
+	}
+	node.requestHeaders()
+}
+
+// onSyncBlock removes the request of the received block and requests more
+// blocks.
+func (node *node) onSyncBlock(hash common.Uint256) {
+	node.headerSync.Lock()
+	defer node.headerSync.Unlock()
+	if !node.headerFirstMode {
+		return
+	}
+	delete(node.headerSync.requested, hash)
+	node.requestBlocks()
+}
+
+// onSyncBlocksNotFound requests the blocks the peer does not have from other
+// peers.
+func (node *node) onSyncBlocksNotFound(peer Noder, invList []*msg.InvVect) {
+	node.headerSync.Lock()
+	defer node.headerSync.Unlock()
+	if !node.headerFirstMode {
+		return
+	}
+	for _, iv := range invList {
+		if req, ok := node.headerSync.requested[iv.Hash]; ok && req.peer.ID() == peer.ID() {
+			node.requeueBlock(iv.Hash, req)
+		}
+	}
+	node.headerSync.stalled[peer.ID()] = time.Now()
+	node.requestBlocks()
+}
+
+// requeueBlock puts the requested block back to the queue to request it from
+// another peer, it must be called with the lock.
+func (node *node) requeueBlock(hash common.Uint256, req *blockRequest) {
+	hs := &node.headerSync
+	delete(hs.requested, hash)
+	node.DeleteRequestedBlock(hash)
+	hs.queue = append(hs.queue, req.header)
+	sort.Slice(hs.queue, func(i, j int) bool {
+		return hs.queue[i].Height < hs.queue[j].Height
+	})
+}
+
+// checkHeaderSync re-assigns the stalled block requests, changes the sync peer
+// if it stalls, and stops headers-first sync once the blocks of all headers
+// are downloaded.
+func (node *node) checkHeaderSync() {
+	node.headerSync.Lock()
+	defer node.headerSync.Unlock()
+
+	hs := &node.headerSync
+	if !node.headerFirstMode {
+		return
+	}
+	now := time.Now()
+	timeout := time.Second * BlockStallTimeout
+	for hash, req := range hs.requested {
+		if LocalNode.IsNeighborNoder(req.peer) && now.Before(req.time.Add(timeout)) {
+			continue
+		}
+		log.Warnf("Block %s stalled by peer %d", hash.String(), req.peer.ID())
+		hs.stalled[req.peer.ID()] = now
+		node.requeueBlock(hash, req)
+	}
+
+	if !LocalNode.IsNeighborNoder(hs.syncPeer) ||
+		(!hs.headerTime.IsZero() && now.After(hs.headerTime.Add(timeout))) {
+		log.Warnf("Headers stalled by peer %d", hs.syncPeer.ID())
+		node.changeSyncPeer()
+		if !node.headerFirstMode {
+			return
+		}
+	}
+
+	if hs.headerDone && len(hs.queue) == 0 && len(hs.requested) == 0 {
+		bestHeight := chain.DefaultLedger.Blockchain.GetBestHeight()
+		if bestHeight < hs.tip.Height {
+			// the downloaded blocks are not connected, like orphans
+			// removed from the orphan pool, so sync from the best chain
+			// again.
+			log.Warnf("Blocks to height %d are not connected at height %d", hs.tip.Height, bestHeight)
+			hs.tip = chain.DefaultLedger.Blockchain.BestChain
+			node.requestHeaders()
+			return
+		}
+		node.stopHeaderSync()
+		return
+	}
+	node.requestBlocks()
+}
@@ -0,0 +1,268 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	chain "github.com/elastos/Elastos.ELA.SideChain/blockchain"
+	"github.com/elastos/Elastos.ELA.SideChain/config"
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+	"github.com/elastos/Elastos.ELA.SideChain/log"
+	. "github.com/elastos/Elastos.ELA.SideChain/protocol"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/p2p"
+	"github.com/elastos/Elastos.ELA.Utility/p2p/msg"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeNoder is a peer recording the messages sent to it, the methods not used
+// by headers-first sync are not implemented.
+type fakeNoder struct {
+	Noder
+	id          uint64
+	height      uint64
+	services    uint64
+	syncHeaders bool
+	closed      bool
+	sent        []p2p.Message
+}
+
+var _ Noder = (*fakeNoder)(nil)
+
+func newFakeNoder(id, height, services uint64) *fakeNoder {
+	return &fakeNoder{id: id, height: height, services: services}
+}
+
+func (n *fakeNoder) ID() uint64 { return n.id }
+
+func (n *fakeNoder) Height() uint64 { return n.height }
+
+func (n *fakeNoder) Services() uint64 { return n.services }
+
+func (n *fakeNoder) State() uint { return p2p.ESTABLISH }
+
+func (n *fakeNoder) IsSyncFailed() bool { return false }
+
+func (n *fakeNoder) IsSyncHeaders() bool { return n.syncHeaders }
+
+func (n *fakeNoder) SetSyncHeaders(b bool) { n.syncHeaders = b }
+
+func (n *fakeNoder) CloseConn() { n.closed = true }
+
+func (n *fakeNoder) Send(msg p2p.Message) { n.sent = append(n.sent, msg) }
+
+func (n *fakeNoder) GetTxInPool(txId common.Uint256) (*core.Transaction, bool) {
+	return nil, false
+}
+
+// requestedBlocks returns the hashes of the blocks requested from the peer.
+func (n *fakeNoder) requestedBlocks() []common.Uint256 {
+	var hashes []common.Uint256
+	for _, m := range n.sent {
+		if getData, ok := m.(*msg.GetData); ok {
+			for _, iv := range getData.InvList {
+				hashes = append(hashes, iv.Hash)
+			}
+		}
+	}
+	return hashes
+}
+
+// getHeadersCount returns the number of getheaders sent to the peer.
+func (n *fakeNoder) getHeadersCount() int {
+	var count int
+	for _, m := range n.sent {
+		if _, ok := m.(*GetHeaders); ok {
+			count++
+		}
+	}
+	return count
+}
+
+// newTestSyncNode sets a local node in headers-first sync from the genesis
+// block with the peers, and returns a function to restore the local node and
+// the ledger.
+func newTestSyncNode(peers ...Noder) (*node, func()) {
+	log.Init(
+		config.Parameters.PrintLevel,
+		config.Parameters.MaxPerLogSize,
+		config.Parameters.MaxLogsSize,
+	)
+
+	localNode, ledger := LocalNode, chain.DefaultLedger
+	restore := func() {
+		LocalNode, chain.DefaultLedger = localNode, ledger
+	}
+
+	genesisHash := common.Uint256{0xff}
+	genesis := chain.NewBlockNode(&core.Header{}, &genesisHash)
+	chain.DefaultLedger = &chain.Ledger{Blockchain: &chain.Blockchain{
+		GenesisHash: genesisHash,
+		BestChain:   genesis,
+		Index:       map[common.Uint256]*chain.BlockNode{genesisHash: genesis},
+	}}
+
+	n := &node{RequestedBlockList: make(map[common.Uint256]time.Time)}
+	n.nbrNodes.init()
+	for _, peer := range peers {
+		n.AddNbrNode(peer)
+	}
+	LocalNode = n
+
+	n.headerFirstMode = true
+	n.headerSync.tip = genesis
+	n.headerSync.requested = make(map[common.Uint256]*blockRequest)
+	n.headerSync.stalled = make(map[uint64]time.Time)
+	return n, restore
+}
+
+// newHeaderNodes returns the header nodes from height 1 to the height.
+func newHeaderNodes(height uint32) []*chain.BlockNode {
+	nodes := make([]*chain.BlockNode, 0, height)
+	for h := uint32(1); h <= height; h++ {
+		hash := common.Uint256{byte(h), byte(h >> 8), 0x01}
+		nodes = append(nodes, chain.NewBlockNode(&core.Header{Height: h}, &hash))
+	}
+	return nodes
+}
+
+func TestHeaderSync_RequestBlocks(t *testing.T) {
+	peerA := newFakeNoder(1, 100, 0)
+	peerB := newFakeNoder(2, 100, 0)
+	pruned := newFakeNoder(3, 100+chain.MinPruneDepth, PrunedService)
+	stalled := newFakeNoder(4, 100, 0)
+	n, restore := newTestSyncNode(peerA, peerB, pruned, stalled)
+	defer restore()
+
+	hs := &n.headerSync
+	hs.headerDone = true
+	hs.stalled[stalled.ID()] = time.Now()
+	headers := newHeaderNodes(MaxBlocksInFlight*2 + 1)
+	hash := common.Uint256{0x02}
+	beyondWindow := chain.NewBlockNode(&core.Header{Height: BlockDownloadWindow + 1}, &hash)
+	hs.queue = append(append([]*chain.BlockNode{}, headers...), beyondWindow)
+
+	// 1. The blocks are requested from the peers with the least blocks in
+	// flight, except the pruned and stalled peers
+	n.requestBlocks()
+	assert.Len(t, peerA.requestedBlocks(), MaxBlocksInFlight)
+	assert.Len(t, peerB.requestedBlocks(), MaxBlocksInFlight)
+	assert.Empty(t, pruned.requestedBlocks())
+	assert.Empty(t, stalled.requestedBlocks())
+	assert.Len(t, hs.requested, MaxBlocksInFlight*2)
+	for _, header := range headers[:MaxBlocksInFlight*2] {
+		assert.Contains(t, hs.requested, *header.Hash)
+		assert.True(t, n.IsRequestedBlock(*header.Hash))
+	}
+
+	// 2. The blocks over the in flight limit and beyond the download window
+	// are kept in the queue
+	assert.Equal(t, []*chain.BlockNode{headers[MaxBlocksInFlight*2], beyondWindow}, hs.queue)
+
+	// 3. The next block is requested once a block is received
+	n.onSyncBlock(*headers[0].Hash)
+	assert.Len(t, hs.requested, MaxBlocksInFlight*2)
+	assert.Equal(t, []*chain.BlockNode{beyondWindow}, hs.queue)
+	assert.Len(t, append(peerA.requestedBlocks(), peerB.requestedBlocks()...), MaxBlocksInFlight*2+1)
+}
+
+func TestHeaderSync_RequeueBlock(t *testing.T) {
+	peer := newFakeNoder(1, 100, 0)
+	n, restore := newTestSyncNode(peer)
+	defer restore()
+
+	hs := &n.headerSync
+	headers := newHeaderNodes(4)
+	hs.queue = []*chain.BlockNode{headers[1], headers[3]}
+	req := &blockRequest{header: headers[2], peer: peer, time: time.Now()}
+	hs.requested[*headers[2].Hash] = req
+	n.AddRequestedBlock(*headers[2].Hash)
+
+	// the block is put back to the queue in height order
+	n.requeueBlock(*headers[2].Hash, req)
+	assert.Equal(t, []*chain.BlockNode{headers[1], headers[2], headers[3]}, hs.queue)
+	assert.NotContains(t, hs.requested, *headers[2].Hash)
+	assert.False(t, n.IsRequestedBlock(*headers[2].Hash))
+
+	// the blocks not found by the peer are requested from other peers
+	hs.headerDone = true
+	hs.queue = nil
+	hs.requested[*headers[0].Hash] = &blockRequest{header: headers[0], peer: peer, time: time.Now()}
+	notFound := []*msg.InvVect{msg.NewInvVect(msg.InvTypeBlock, headers[0].Hash)}
+	n.onSyncBlocksNotFound(peer, notFound)
+	assert.Contains(t, hs.stalled, peer.ID())
+	assert.Equal(t, []*chain.BlockNode{headers[0]}, hs.queue)
+	assert.Empty(t, hs.requested)
+}
+
+func TestHeaderSync_StallReassignment(t *testing.T) {
+	slow := newFakeNoder(1, 100, HeadersService)
+	fast := newFakeNoder(2, 100, HeadersService)
+	gone := newFakeNoder(3, 100, HeadersService)
+	n, restore := newTestSyncNode(slow, fast)
+	defer restore()
+
+	hs := &n.headerSync
+	hs.syncPeer = fast
+	hs.headerDone = true
+	headers := newHeaderNodes(3)
+	now := time.Now()
+	stallTime := now.Add(-time.Second * BlockStallTimeout * 2)
+	hs.requested[*headers[0].Hash] = &blockRequest{header: headers[0], peer: slow, time: stallTime}
+	hs.requested[*headers[1].Hash] = &blockRequest{header: headers[1], peer: slow, time: now}
+	hs.requested[*headers[2].Hash] = &blockRequest{header: headers[2], peer: gone, time: now}
+
+	// the stalled block and the block of the disconnected peer are requested
+	// from another peer, the stalled peer is not requested for a while.
+	n.checkHeaderSync()
+	assert.Contains(t, hs.stalled, slow.ID())
+	assert.ElementsMatch(t, []common.Uint256{*headers[0].Hash, *headers[2].Hash}, fast.requestedBlocks())
+	assert.Equal(t, fast, hs.requested[*headers[0].Hash].peer)
+	assert.Equal(t, slow, hs.requested[*headers[1].Hash].peer)
+	assert.Equal(t, fast, hs.requested[*headers[2].Hash].peer)
+	assert.Empty(t, hs.queue)
+}
+
+func TestHeaderSync_SyncPeer(t *testing.T) {
+	low := newFakeNoder(1, 10, HeadersService)
+	high := newFakeNoder(2, 20, HeadersService)
+	noHeaders := newFakeNoder(3, 30, 0)
+	n, restore := newTestSyncNode(low, high, noHeaders)
+	defer restore()
+
+	// 1. The best peer serving headers is the sync peer
+	n.startHeaderSync(n.bestHeadersNoder(nil))
+	hs := &n.headerSync
+	assert.Equal(t, high, hs.syncPeer)
+	assert.True(t, high.IsSyncHeaders())
+	assert.Equal(t, 1, high.getHeadersCount())
+
+	// 2. The sync peer is changed when it stalls the headers
+	hs.headerTime = time.Now().Add(-time.Second * BlockStallTimeout * 2)
+	n.checkHeaderSync()
+	assert.Equal(t, low, hs.syncPeer)
+	assert.False(t, high.IsSyncHeaders())
+	assert.True(t, low.IsSyncHeaders())
+	assert.Equal(t, 1, low.getHeadersCount())
+
+	// 3. The headers from other peers are ignored
+	tip := hs.tip
+	n.onHeaders(high, []*core.Header{{Height: 1}})
+	assert.False(t, high.closed)
+	assert.Equal(t, tip, hs.tip)
+
+	// 4. The sync peer sending headers which do not connect is disconnected
+	n.onHeaders(low, []*core.Header{{Height: 1, Previous: common.Uint256{0xee}}})
+	assert.True(t, low.closed)
+	assert.Equal(t, high, hs.syncPeer)
+	assert.Equal(t, tip, hs.tip)
+
+	// 5. Headers-first sync stops when no other peer serves headers
+	n.DelNbrNode(low.ID())
+	n.DelNbrNode(high.ID())
+	n.checkHeaderSync()
+	assert.False(t, n.IsHeaderFirstMode())
+	assert.Nil(t, hs.syncPeer)
+	assert.False(t, high.IsSyncHeaders())
+}
@@ -79,9 +79,19 @@ func (node *node) SyncBlocks() {
 	bc := chain.DefaultLedger.Blockchain
 	log.Info("[", len(bc.Index), len(bc.BlockCache), len(bc.Orphans), "]")
 	if needSync {
+		if LocalNode.IsHeaderFirstMode() {
+			LocalNode.checkHeaderSync()
+			return
+		}
 		if LocalNode.IsSyncHeaders() {
 			return
 		}
+		// prefer headers-first sync with the peers serving headers
+		if peer := LocalNode.bestHeadersNoder(nil); peer != nil &&
+			peer.Height() > uint64(bc.GetBestHeight()) {
+			LocalNode.startHeaderSync(peer)
+			return
+		}
 		LocalNode.ResetRequestedBlock()
 		hasSyncPeer, syncNode := LocalNode.hasSyncPeer()
 		if hasSyncPeer == false {
@@ -95,6 +105,8 @@ func (node *node) SyncBlocks() {
 		syncNode.SetSyncHeaders(true)
 		// Start sync timer
 		LocalNode.syncTimer.start()
+	} else if LocalNode.IsHeaderFirstMode() {
+		LocalNode.checkHeaderSync()
 	} else {
 		LocalNode.stopSyncing()
 	}
@@ -174,7 +186,7 @@ func (node *node) ConnectNode() {
 	}
 }
 
-func getNodeAddr(n *node) p2p.NetAddress {
+func getNodeAddr(n Noder) p2p.NetAddress {
 	var addr p2p.NetAddress
 	addr.IP, _ = n.Addr16()
 	addr.Time = n.GetTime()
@@ -189,6 +201,7 @@ func (node *node) updateNodeInfo() {
 	for {
 		select {
 		case <-ticker.C:
+			chain.DefaultLedger.Blockchain.ProcessDeferredBlocks()
 			node.SyncBlocks()
 		}
 	}
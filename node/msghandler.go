@@ -78,6 +78,10 @@ func (h *MsgHandlerV1) OnMakeMessage(cmd string) (message p2p.Message, err error
 		message = new(msg.MemPool)
 	case p2p.CmdReject:
 		message = new(msg.Reject)
+	case CmdGetHeaders:
+		message = new(GetHeaders)
+	case CmdHeaders:
+		message = new(Headers)
 	default:
 		err = fmt.Errorf("unknown message type")
 	}
@@ -120,6 +124,10 @@ func (h *MsgHandlerV1) OnMessageDecoded(message p2p.Message) {
 		err = h.onMemPool(message)
 	case *msg.Reject:
 		err = h.onReject(message)
+	case *GetHeaders:
+		err = h.onGetHeaders(message)
+	case *Headers:
+		err = h.onHeaders(message)
 	default:
 		err = fmt.Errorf("unknown message type")
 	}
@@ -281,11 +289,64 @@ func (h *MsgHandlerV1) onGetBlocks(req *msg.GetBlocks) error {
 	return nil
 }
 
+func (h *MsgHandlerV1) onGetHeaders(req *GetHeaders) error {
+	node := h.node
+	LocalNode.AcqSyncHdrReqSem()
+	defer LocalNode.RelSyncHdrReqSem()
+
+	// the headers of pruned blocks are kept, so the start is looked up by
+	// headers instead of blocks.
+	startHeight := uint32(0)
+	for _, hash := range req.Locator {
+		header, err := chain.DefaultLedger.Store.GetHeader(*hash)
+		if err != nil {
+			continue
+		}
+		mainHash, err := chain.DefaultLedger.Store.GetBlockHash(header.Height)
+		if err == nil && mainHash.IsEqual(*hash) {
+			startHeight = header.Height
+			break
+		}
+	}
+
+	headers := new(Headers)
+	bestHeight := chain.DefaultLedger.Store.GetHeight()
+	for height := startHeight + 1; height <= bestHeight && len(headers.Headers) < MaxHeadersPerMsg; height++ {
+		hash, err := chain.DefaultLedger.Store.GetBlockHash(height)
+		if err != nil {
+			return err
+		}
+		header, err := chain.DefaultLedger.Store.GetHeader(hash)
+		if err != nil {
+			return err
+		}
+		headers.Headers = append(headers.Headers, header)
+		if hash.IsEqual(req.HashStop) {
+			break
+		}
+	}
+
+	node.Send(headers)
+	return nil
+}
+
+func (h *MsgHandlerV1) onHeaders(headers *Headers) error {
+	if !LocalNode.IsNeighborNoder(h.node) {
+		return fmt.Errorf("received headers message from unknown peer")
+	}
+
+	LocalNode.onHeaders(h.node, headers.Headers)
+	return nil
+}
+
 func (h *MsgHandlerV1) onInventory(inv *msg.Inventory) error {
 	node := h.node
 	if LocalNode.IsSyncHeaders() && !node.IsSyncHeaders() {
 		return nil
 	}
+	// blocks are requested by headers in headers-first sync, transactions
+	// are still requested.
+	headerFirstMode := LocalNode.IsHeaderFirstMode()
 
 	// Attempt to find the final block in the inventory list.  There may
 	// not be one.
@@ -303,6 +364,9 @@ func (h *MsgHandlerV1) onInventory(inv *msg.Inventory) error {
 		hash := iv.Hash
 		switch iv.Type {
 		case msg.InvTypeBlock:
+			if headerFirstMode {
+				continue
+			}
 			haveInv := chain.DefaultLedger.BlockInLedger(hash) ||
 				chain.DefaultLedger.Blockchain.IsKnownOrphan(&hash) || LocalNode.IsRequestedBlock(hash)
 
@@ -349,9 +413,24 @@ func (h *MsgHandlerV1) onGetData(getData *msg.GetData) error {
 	node := h.node
 	notFound := msg.NewNotFound()
 
+	// limit the peers downloading blocks at the same time
+	for _, iv := range getData.InvList {
+		if iv.Type == msg.InvTypeBlock {
+			LocalNode.AcqSyncBlkReqSem()
+			defer LocalNode.RelSyncBlkReqSem()
+			break
+		}
+	}
+
 	for _, iv := range getData.InvList {
 		switch iv.Type {
 		case msg.InvTypeBlock:
+			// transactions of pruned blocks may be removed
+			if chain.DefaultLedger.Store.IsBlockPruned(iv.Hash) {
+				notFound.AddInvVect(iv)
+				continue
+			}
+
 			block, err := chain.DefaultLedger.Store.GetBlock(iv.Hash)
 			if err != nil {
 				log.Debug("Can't get block from hash: ", iv.Hash, " ,send not found message")
@@ -384,6 +463,11 @@ func (h *MsgHandlerV1) onGetData(getData *msg.GetData) error {
 				return nil
 			}
 
+			if chain.DefaultLedger.Store.IsBlockPruned(iv.Hash) {
+				notFound.AddInvVect(iv)
+				continue
+			}
+
 			block, err := chain.DefaultLedger.Store.GetBlock(iv.Hash)
 			if err != nil {
 				log.Debug("Can't get block from hash: ", iv.Hash, " ,send not found message")
@@ -407,6 +491,10 @@ func (h *MsgHandlerV1) onGetData(getData *msg.GetData) error {
 		}
 	}
 
+	if len(notFound.InvList) > 0 {
+		node.Send(notFound)
+	}
+
 	return nil
 }
 
@@ -418,6 +506,7 @@ func (h *MsgHandlerV1) onBlock(msgBlock *msg.Block) error {
 	if !LocalNode.IsNeighborNoder(node) {
 		return fmt.Errorf("received block message from unknown peer")
 	}
+	defer LocalNode.onSyncBlock(hash)
 
 	if chain.DefaultLedger.BlockInLedger(hash) {
 		log.Trace("Receive duplicated block, ", hash.String())
@@ -430,6 +519,11 @@ func (h *MsgHandlerV1) onBlock(msgBlock *msg.Block) error {
 	LocalNode.DeleteRequestedBlock(hash)
 
 	_, isOrphan, err := chain.DefaultLedger.Blockchain.AddBlock(block)
+	if err == chain.ErrMainChainBlockNotSynced {
+		// the block is processed again when the main chain block is synced
+		log.Debugf("Block %s is deferred, main chain block is not synced", hash.String())
+		return nil
+	}
 	if err != nil {
 		reject := msg.NewReject(msgBlock.CMD(), msg.RejectInvalid, err.Error())
 		reject.Hash = block.Hash()
@@ -438,7 +532,8 @@ func (h *MsgHandlerV1) onBlock(msgBlock *msg.Block) error {
 		return fmt.Errorf("Block add failed: %s ,block hash %s ", err.Error(), hash.String())
 	}
 
-	if isOrphan {
+	// the parents of orphans are requested by headers in headers-first sync
+	if isOrphan && !LocalNode.IsHeaderFirstMode() {
 		orphanRoot := chain.DefaultLedger.Blockchain.GetOrphanRoot(&hash)
 		locator, _ := chain.DefaultLedger.Blockchain.LatestBlockLocator()
 		SendGetBlocks(node, locator, *orphanRoot)
@@ -489,6 +584,7 @@ func (h *MsgHandlerV1) onNotFound(inv *msg.NotFound) error {
 	for _, iv := range inv.InvList {
 		log.Warnf("data not found type: %s hash: %s", iv.Type.String(), iv.Hash.String())
 	}
+	LocalNode.onSyncBlocksNotFound(h.node, inv.InvList)
 	return nil
 }
 
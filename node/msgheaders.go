@@ -0,0 +1,116 @@
+package node
+
+import (
+	"errors"
+	"io"
+
+	chain "github.com/elastos/Elastos.ELA.SideChain/blockchain"
+	"github.com/elastos/Elastos.ELA.SideChain/config"
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+)
+
+const (
+	CmdGetHeaders = "getheaders"
+	CmdHeaders    = "headers"
+
+	// MaxHeadersPerMsg is the max number of headers in a headers message.
+	MaxHeadersPerMsg = 2000
+)
+
+// GetHeaders requests the headers after the first hash of the locator in the
+// main chain, to HashStop or MaxHeadersPerMsg headers.
+type GetHeaders struct {
+	Locator  []*common.Uint256
+	HashStop common.Uint256
+}
+
+func NewGetHeaders(locator []*common.Uint256, hashStop common.Uint256) *GetHeaders {
+	return &GetHeaders{Locator: locator, HashStop: hashStop}
+}
+
+func (msg *GetHeaders) CMD() string {
+	return CmdGetHeaders
+}
+
+func (msg *GetHeaders) MaxLength() uint32 {
+	return 4 + (chain.MaxBlockLocatorsPerMsg+1)*common.UINT256SIZE
+}
+
+func (msg *GetHeaders) Serialize(w io.Writer) error {
+	if err := common.WriteUint32(w, uint32(len(msg.Locator))); err != nil {
+		return err
+	}
+	for _, hash := range msg.Locator {
+		if err := hash.Serialize(w); err != nil {
+			return err
+		}
+	}
+	return msg.HashStop.Serialize(w)
+}
+
+func (msg *GetHeaders) Deserialize(r io.Reader) error {
+	count, err := common.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	if count > chain.MaxBlockLocatorsPerMsg {
+		return errors.New("too many locator hashes in getheaders message")
+	}
+
+	msg.Locator = make([]*common.Uint256, 0, count)
+	for i := uint32(0); i < count; i++ {
+		var hash common.Uint256
+		if err := hash.Deserialize(r); err != nil {
+			return err
+		}
+		msg.Locator = append(msg.Locator, &hash)
+	}
+	return msg.HashStop.Deserialize(r)
+}
+
+// Headers is the response of GetHeaders, the headers are in height order.
+type Headers struct {
+	Headers []*core.Header
+}
+
+func (msg *Headers) CMD() string {
+	return CmdHeaders
+}
+
+func (msg *Headers) MaxLength() uint32 {
+	return uint32(config.Parameters.MaxBlockSize)
+}
+
+func (msg *Headers) Serialize(w io.Writer) error {
+	if err := common.WriteVarUint(w, uint64(len(msg.Headers))); err != nil {
+		return err
+	}
+	for _, header := range msg.Headers {
+		if err := header.Serialize(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (msg *Headers) Deserialize(r io.Reader) error {
+	count, err := common.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > MaxHeadersPerMsg {
+		return errors.New("too many headers in headers message")
+	}
+
+	msg.Headers = make([]*core.Header, 0, count)
+	for i := uint64(0); i < count; i++ {
+		header := new(core.Header)
+		if err := header.Deserialize(r); err != nil {
+			return err
+		}
+		msg.Headers = append(msg.Headers, header)
+	}
+	return nil
+}
@@ -67,6 +67,7 @@ type node struct {
 	KnownAddressList
 	DefaultMaxPeers    uint
 	headerFirstMode    bool
+	headerSync         headerSync
 	RequestedBlockList map[Uint256]time.Time
 	syncTimer          *syncTimer
 	SyncBlkReqSem      Semaphore
@@ -100,6 +101,11 @@ func InitLocalNode() Noder {
 	if Parameters.OpenService {
 		LocalNode.services += protocol.OpenService
 	}
+	// the blocks of an imported snapshot are pruned as well
+	if _, pruned := chain.DefaultLedger.Store.GetPruneHeight(); Parameters.PruneDepth > 0 || pruned {
+		LocalNode.services += PrunedService
+	}
+	LocalNode.services += HeadersService
 	LocalNode.relay = true
 	idHash := sha256.Sum256([]byte(strconv.Itoa(int(time.Now().UnixNano()))))
 	binary.Read(bytes.NewBuffer(idHash[:8]), binary.LittleEndian, &(LocalNode.id))
@@ -107,7 +113,7 @@ func InitLocalNode() Noder {
 	log.Info(fmt.Sprintf("Init node ID to 0x%x", LocalNode.id))
 	LocalNode.nbrNodes.init()
 	LocalNode.KnownAddressList.init()
-	LocalNode.TxPool.Init()
+	LocalNode.TxPool.Init(chain.DefaultLedger.Blockchain.BCEvents)
 	LocalNode.eventQueue.init()
 	LocalNode.idCache.init()
 	LocalNode.cachedHashes = make([]Uint256, 0)
@@ -413,20 +419,26 @@ func (node *node) needSync() bool {
 func (node *node) GetBestHeightNoder() Noder {
 	node.nbrNodes.RLock()
 	defer node.nbrNodes.RUnlock()
-	var bestnode Noder
+	// pruned nodes may not have the blocks to sync, they are used only if
+	// there is no other node.
+	var bestnode, bestPruned Noder
 	for _, n := range node.nbrNodes.List {
-		if n.State() == p2p.ESTABLISH {
-			if bestnode == nil {
-				if !n.IsSyncFailed() {
-					bestnode = n
-				}
-			} else {
-				if (n.Height() > bestnode.Height()) && !n.IsSyncFailed() {
-					bestnode = n
-				}
+		if n.State() != p2p.ESTABLISH || n.IsSyncFailed() {
+			continue
+		}
+		if n.Services()&PrunedService != 0 {
+			if bestPruned == nil || n.Height() > bestPruned.Height() {
+				bestPruned = n
 			}
+			continue
+		}
+		if bestnode == nil || n.Height() > bestnode.Height() {
+			bestnode = n
 		}
 	}
+	if bestnode == nil {
+		return bestPruned
+	}
 	return bestnode
 }
 
@@ -13,7 +13,7 @@ import (
 type nbrNodes struct {
 	sync.RWMutex
 	// Todo using the Pool structure
-	List map[uint64]*node
+	List map[uint64]Noder
 }
 
 func (nm *nbrNodes) NodeExisted(uid uint64) bool {
@@ -28,12 +28,7 @@ func (nm *nbrNodes) AddNbrNode(n Noder) {
 	if nm.NodeExisted(n.ID()) {
 		fmt.Printf("Insert a existed node\n")
 	} else {
-		node, err := n.(*node)
-		if err == false {
-			fmt.Println("Convert the noder error when add node")
-			return
-		}
-		nm.List[n.ID()] = node
+		nm.List[n.ID()] = n
 	}
 }
 
@@ -63,7 +58,7 @@ func (nm *nbrNodes) GetConnectionCnt() uint {
 }
 
 func (nm *nbrNodes) init() {
-	nm.List = make(map[uint64]*node)
+	nm.List = make(map[uint64]Noder)
 }
 
 func (nm *nbrNodes) NodeEstablished(id uint64) bool {
@@ -126,8 +126,13 @@ func (pow *PowService) GenerateBlock(addr string) (*core.Block, error) {
 		return nil, err
 	}
 
+	version, err := DefaultLedger.Blockchain.CalcNextBlockVersion()
+	if err != nil {
+		return nil, err
+	}
+
 	header := core.Header{
-		Version:    0,
+		Version:    version,
 		Previous:   *DefaultLedger.Blockchain.BestChain.Hash,
 		MerkleRoot: common.EmptyHash,
 		Timestamp:  uint32(DefaultLedger.Blockchain.MedianAdjustedTime().Unix()),
@@ -166,7 +171,7 @@ func (pow *PowService) GenerateBlock(addr string) (*core.Block, error) {
 			continue
 		}
 
-		fee := GetTxFee(tx, DefaultLedger.Blockchain.AssetID)
+		fee := GetTxFee(tx, DefaultLedger.Blockchain.AssetID, nextBlockHeight)
 		if fee != tx.Fee {
 			continue
 		}
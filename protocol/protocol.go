@@ -28,8 +28,21 @@ const (
 	MaxIdCached        = 5000
 )
 
+// headers-first sync
+const (
+	BlockStallTimeout   = 15   // Seconds before a requested block is assigned to another peer
+	MaxBlocksInFlight   = 16   // Max blocks requested from a peer at once
+	BlockDownloadWindow = 1024 // Max heights of requested blocks beyond the best block
+)
+
 const (
 	OpenService = 1 << 2
+	// PrunedService is set by nodes which only serve the recent blocks
+	// within their prune depth.
+	PrunedService = 1 << 3
+	// HeadersService is set by nodes which serve getheaders for
+	// headers-first sync.
+	HeadersService = 1 << 4
 )
 
 type Noder interface {
@@ -51,9 +64,11 @@ type Noder interface {
 	GetConn() net.Conn
 	CloseConn()
 	GetConnectionCnt() uint
+	GetTxInPool(txId common.Uint256) (*core.Transaction, bool)
 	GetTxsInPool() map[common.Uint256]*core.Transaction
 	AppendToTxnPool(*core.Transaction) errors.ErrCode
 	IsDuplicateMainchainTx(mainchainTxHash common.Uint256) bool
+	GetMainchainTxInPool(mainchainTxHash common.Uint256) (*core.Transaction, bool)
 	ExistedID(id common.Uint256) bool
 	DumpInfo()
 	UpdateInfo(t time.Time, version uint32, services uint64,
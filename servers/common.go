@@ -116,6 +116,108 @@ type RechargeToSideChainInfo struct {
 	MainChainTransaction string
 }
 
+type MainChainAnchorInfo struct {
+	Hash               string
+	Height             uint32
+	MainChainHash      string
+	MainChainHeight    uint32
+	MainChainTimestamp uint32
+	Verified           bool
+}
+
+type ChainTipInfo struct {
+	Height    uint32
+	Hash      string
+	BranchLen uint32
+	Status    string
+}
+
+type AssetSupplyInfo struct {
+	AssetID string
+	Name    string
+	Amount  string
+}
+
+type TxOutSetInfo struct {
+	Height       uint32
+	BestBlock    string
+	Transactions uint64
+	TxOuts       uint64
+	TotalAmount  string
+	Assets       []AssetSupplyInfo
+	SetHash      string
+}
+
+type SnapshotInfo struct {
+	Height uint32
+	Hash   string
+	Path   string
+}
+
+type DeploymentInfo struct {
+	Name       string
+	Bit        uint8
+	StartTime  int64
+	ExpireTime int64
+	Threshold  uint32
+	Window     uint32
+	State      string
+	Elapsed    uint32
+	Count      uint32
+}
+
+type CrossChainSummaryInfo struct {
+	StartHeight uint32
+	EndHeight   uint32
+	Recharged   string
+	Destroyed   string
+	Withdrawn   string
+	FeesBurned  string
+}
+
+type ExchangeRateInfo struct {
+	Height uint32
+	Rate   string
+}
+
+type DepositOutputInfo struct {
+	Address string
+	Amount  string
+}
+
+// DepositStatusInfo is the status of a main chain deposit, Status is one of
+// "unknown", "pending", "confirmed" and "reorganized".
+type DepositStatusInfo struct {
+	MainChainTxId string
+	Status        string
+	TxId          string              `json:",omitempty"`
+	Height        uint32              `json:",omitempty"`
+	Confirmations uint32              `json:",omitempty"`
+	Outputs       []DepositOutputInfo `json:",omitempty"`
+}
+
+type WithdrawFromSideChainInfo struct {
+	Proof                string
+	MainChainTransaction string
+}
+
+type WithdrawalOutputInfo struct {
+	Address string
+	Amount  string
+}
+
+// WithdrawalInfo is a withdrawal to main chain, the processed fields are empty
+// while the withdrawal is pending.
+type WithdrawalInfo struct {
+	TxId            string
+	Height          uint32
+	Status          string
+	Outputs         []WithdrawalOutputInfo
+	ProcessedHeight uint32 `json:",omitempty"`
+	ProcessedTxId   string `json:",omitempty"`
+	MainChainTxId   string `json:",omitempty"`
+}
+
 type TransferCrossChainAssetInfo struct {
 	CrossChainAddresses []string
 	OutputIndexes       []uint64
@@ -137,3 +239,47 @@ type RegisterIdentificationInfo struct {
 	Sign     string
 	Contents []RegisterIdentificationContentInfo
 }
+
+type RevokeIdentificationInfo struct {
+	Id    string
+	Paths []string
+}
+
+type RotateIdentificationKeyInfo struct {
+	Id        string
+	PublicKey string
+}
+
+type IdentificationTransactionInfo struct {
+	*TransactionInfo
+	Revoked bool `json:"revoked"`
+}
+
+type IdentificationVersionInfo struct {
+	Height     uint32
+	TxId       string
+	Revoked    bool
+	DataHashes []string
+}
+
+type IdentificationPathInfo struct {
+	Path    string
+	Revoked bool
+}
+
+type DIDPathInfo struct {
+	Path      string
+	TxId      string
+	Height    uint32
+	Timestamp uint32
+	Values    []RegisterIdentificationValueInfo
+}
+
+// DIDDocumentInfo is the resolved ID at Height, Controller is the address of
+// the key controls the ID, revoked paths are not included.
+type DIDDocumentInfo struct {
+	Id         string
+	Controller string
+	Height     uint32
+	Paths      []DIDPathInfo
+}
@@ -3,6 +3,7 @@ package httpjsonrpc
 import (
 	"encoding/json"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strconv"
 
@@ -15,6 +16,10 @@ import (
 //an instance of the multiplexer
 var mainMux map[string]func(Params) map[string]interface{}
 
+// localMux is the methods which manage the node or it's wallet, they are only
+// served to clients on the same host.
+var localMux map[string]func(Params) map[string]interface{}
+
 const (
 	// JSON-RPC protocol error codes.
 	ParseError     = -32700
@@ -27,6 +32,7 @@ const (
 
 func StartRPCServer() {
 	mainMux = make(map[string]func(Params) map[string]interface{})
+	localMux = make(map[string]func(Params) map[string]interface{})
 
 	http.HandleFunc("/", Handle)
 
@@ -48,6 +54,16 @@ func StartRPCServer() {
 	mainMux["getdestroyedtransactions"] = GetDestroyedTransactionsByHeight
 	mainMux["getexistdeposittransactions"] = GetExistDepositTransactions
 	mainMux["getidentificationtxbyidandpath"] = GetIdentificationTxByIdAndPath
+	mainMux["getidentificationhistory"] = GetIdentificationHistory
+	mainMux["getidentificationpaths"] = GetIdentificationPaths
+	mainMux["resolvedid"] = ResolveDID
+	mainMux["getwithdrawals"] = GetWithdrawals
+	mainMux["getdepositstatus"] = GetDepositStatus
+	mainMux["getexchangerate"] = GetExchangeRate
+	mainMux["getcrosschainsummary"] = GetCrossChainSummary
+	mainMux["getmainchainanchor"] = GetMainChainAnchor
+	mainMux["getchaintips"] = GetChainTips
+	mainMux["getdeploymentinfo"] = GetDeploymentInfo
 
 	// aux interfaces
 	mainMux["help"] = AuxHelp
@@ -56,6 +72,19 @@ func StartRPCServer() {
 	// mining interfaces
 	mainMux["togglemining"] = ToggleMining
 	mainMux["discretemining"] = DiscreteMining
+	// wallet interfaces
+	localMux["createwallet"] = CreateWallet
+	localMux["getnewaddress"] = GetNewAddress
+	localMux["listunspent"] = ListUnspent
+	localMux["sendtoaddress"] = SendToAddress
+	localMux["sendcrosschain"] = SendCrossChain
+	localMux["walletpassphrase"] = WalletPassphrase
+	// chain management interfaces
+	localMux["invalidateblock"] = InvalidateBlock
+	localMux["reconsiderblock"] = ReconsiderBlock
+	localMux["verifychain"] = VerifyChain
+	localMux["exportsnapshot"] = ExportSnapshot
+	localMux["gettxoutsetinfo"] = GetTxOutSetInfo
 
 	err := http.ListenAndServe(":"+strconv.Itoa(Parameters.HttpJsonPort), nil)
 	if err != nil {
@@ -94,6 +123,13 @@ func Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	method, ok := mainMux[requestMethod]
+	if !ok {
+		method, ok = localMux[requestMethod]
+		if ok && !isLocalRequest(r) {
+			RPCError(w, http.StatusForbidden, InvalidRequest, "method "+requestMethod+" is only allowed from localhost")
+			return
+		}
+	}
 	if !ok {
 		RPCError(w, http.StatusNotFound, MethodNotFound, "method "+requestMethod+" not found")
 		return
@@ -139,6 +175,16 @@ func Handle(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
+// isLocalRequest returns if the request is sent from a loopback address.
+func isLocalRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func RPCError(w http.ResponseWriter, httpStatus int, code errors.ErrCode, message string) {
 	w.WriteHeader(httpStatus)
 	data, _ := json.Marshal(map[string]interface{}{
@@ -172,6 +218,38 @@ func convertParams(method string, params []interface{}) Params {
 		return FromArray(params, "mine")
 	case "discretemining":
 		return FromArray(params, "count")
+	case "getidentificationhistory":
+		return FromArray(params, "id", "path")
+	case "getidentificationpaths":
+		return FromArray(params, "id")
+	case "resolvedid":
+		return FromArray(params, "id", "height")
+	case "getwithdrawals":
+		return FromArray(params, "height", "status")
+	case "getdepositstatus":
+		return FromArray(params, "hashes")
+	case "getexchangerate":
+		return FromArray(params, "height")
+	case "getcrosschainsummary":
+		return FromArray(params, "start", "end")
+	case "getmainchainanchor":
+		return FromArray(params, "hash")
+	case "invalidateblock", "reconsiderblock":
+		return FromArray(params, "hash")
+	case "verifychain":
+		return FromArray(params, "blocks")
+	case "exportsnapshot":
+		return FromArray(params, "height")
+	case "getnewaddress":
+		return FromArray(params, "m", "publickeys")
+	case "sendtoaddress":
+		return FromArray(params, "address", "amount")
+	case "sendcrosschain":
+		return FromArray(params, "address", "amount")
+	case "createwallet":
+		return FromArray(params, "passphrase")
+	case "walletpassphrase":
+		return FromArray(params, "passphrase", "timeout")
 	default:
 		return Params{}
 	}
@@ -34,6 +34,7 @@ const (
 	Api_SendRawTransaction  = "/api/v1/transaction"
 	Api_GetTransactionPool  = "/api/v1/transactionpool"
 	Api_Restart             = "/api/v1/restart"
+	Api_ResolveDID          = "/api/v1/did/:id"
 )
 
 type Action struct {
@@ -111,6 +112,7 @@ func (rt *restServer) initializeMethod() {
 		Api_GetUTXObyAsset:      {name: "getutxobyasset", handler: servers.GetUnspendOutput},
 		Api_GetBalanceByAddr:    {name: "getbalancebyaddr", handler: servers.GetBalanceByAddr},
 		Api_GetBalancebyAsset:   {name: "getbalancebyasset", handler: servers.GetBalanceByAsset},
+		Api_ResolveDID:          {name: "resolvedid", handler: servers.ResolveDID},
 		Api_Restart:             {name: "restart", handler: rt.Restart},
 	}
 
@@ -145,6 +147,8 @@ func (rt *restServer) getPath(url string) string {
 		return Api_GetUTXObyAsset
 	} else if strings.Contains(url, strings.TrimRight(Api_Getasset, ":hash")) {
 		return Api_Getasset
+	} else if strings.Contains(url, strings.TrimRight(Api_ResolveDID, ":id")) {
+		return Api_ResolveDID
 	}
 	return url
 }
@@ -192,6 +196,12 @@ func (rt *restServer) getParams(r *http.Request, url string, req map[string]inte
 		req["addr"] = getParam(r, "addr")
 		req["assetid"] = getParam(r, "assetid")
 
+	case Api_ResolveDID:
+		req["id"] = getParam(r, "id")
+		if height := r.URL.Query().Get("height"); height != "" {
+			req["height"] = height
+		}
+
 	case Api_Restart:
 
 	case Api_SendRawTransaction:
@@ -0,0 +1,45 @@
+package httprestful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/elastos/Elastos.ELA.SideChain/errors"
+	"github.com/elastos/Elastos.ELA.SideChain/servers"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestServer_ResolveDID(t *testing.T) {
+	rt := InitRestServer().(*restServer)
+	var params servers.Params
+	rt.getMap[Api_ResolveDID] = Action{name: "resolvedid", handler: func(param servers.Params) map[string]interface{} {
+		params = param
+		return servers.ResponsePack(Success, "")
+	}}
+
+	get := func(url string) map[string]interface{} {
+		params = nil
+		w := httptest.NewRecorder()
+		rt.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp
+	}
+	id := "ij8rfb6A4Ri7c5CRE1nDVdVCUMuUxkk2c6"
+
+	// 1. The ID is resolved at the best height by default
+	resp := get("/api/v1/did/" + id)
+	assert.Equal(t, float64(Success), resp["Error"])
+	assert.Equal(t, servers.Params{"id": id}, params)
+
+	// 2. The height is passed from the query
+	get("/api/v1/did/" + id + "?height=5")
+	assert.Equal(t, servers.Params{"id": id, "height": "5"}, params)
+	height, ok := params.Uint("height")
+	assert.True(t, ok)
+	assert.Equal(t, uint32(5), height)
+}
@@ -1,11 +1,14 @@
 package servers
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"sort"
 	"time"
 
 	chain "github.com/elastos/Elastos.ELA.SideChain/blockchain"
@@ -15,6 +18,7 @@ import (
 	"github.com/elastos/Elastos.ELA.SideChain/log"
 	"github.com/elastos/Elastos.ELA.SideChain/pow"
 	. "github.com/elastos/Elastos.ELA.SideChain/protocol"
+	"github.com/elastos/Elastos.ELA.SideChain/spv"
 
 	. "github.com/elastos/Elastos.ELA.Utility/common"
 )
@@ -416,6 +420,8 @@ func GetInfo(param Params) map[string]interface{} {
 		Paytxfee       int    `json:"paytxfee"`
 		Relayfee       int    `json:"relayfee"`
 		Errors         string `json:"errors"`
+		Pruned         bool   `json:"pruned"`
+		PruneHeight    uint32 `json:"pruneheight"`
 	}{
 		Version:        config.Parameters.Version,
 		Balance:        0,
@@ -429,6 +435,7 @@ func GetInfo(param Params) map[string]interface{} {
 		Paytxfee:       0,
 		Relayfee:       0,
 		Errors:         "Tobe written"}
+	RetVal.PruneHeight, RetVal.Pruned = chain.DefaultLedger.Store.GetPruneHeight()
 	return ResponsePack(Success, &RetVal)
 }
 
@@ -577,6 +584,218 @@ func GetBlockByHash(param Params) map[string]interface{} {
 	return ResponsePack(error, result)
 }
 
+// GetMainChainAnchor returns the main chain block which the side chain block
+// given by hash or height is mined in, and if it's verified by the SPV module.
+func GetMainChainAnchor(param Params) map[string]interface{} {
+	var hash Uint256
+	if str, ok := param.String("hash"); ok {
+		hashBytes, err := FromReversedString(str)
+		if err != nil {
+			return ResponsePack(InvalidParams, "invalid block hash")
+		}
+		if err := hash.Deserialize(bytes.NewReader(hashBytes)); err != nil {
+			return ResponsePack(InvalidParams, "invalid block hash")
+		}
+	} else if height, ok := param.Uint("height"); ok {
+		h, err := chain.DefaultLedger.Store.GetBlockHash(height)
+		if err != nil {
+			return ResponsePack(UnknownBlock, "")
+		}
+		hash = h
+	} else {
+		return ResponsePack(InvalidParams, "need a block hash or height")
+	}
+
+	header, err := chain.DefaultLedger.Store.GetHeader(hash)
+	if err != nil {
+		return ResponsePack(UnknownBlock, "")
+	}
+	mainBlockHeader := header.SideAuxPow.MainBlockHeader
+	mainBlockHash := mainBlockHeader.Hash()
+	return ResponsePack(Success, MainChainAnchorInfo{
+		Hash:               ToReversedString(hash),
+		Height:             header.Height,
+		MainChainHash:      ToReversedString(mainBlockHash),
+		MainChainHeight:    mainBlockHeader.Height,
+		MainChainTimestamp: mainBlockHeader.Timestamp,
+		Verified:           spv.VerifyElaHeader(&mainBlockHash) == nil,
+	})
+}
+
+// GetChainTips returns the tips of the main chain and the side chains known
+// by the node.
+func GetChainTips(param Params) map[string]interface{} {
+	var tips []ChainTipInfo
+	for _, tip := range chain.DefaultLedger.Blockchain.GetChainTips() {
+		tips = append(tips, ChainTipInfo{
+			Height:    tip.Height,
+			Hash:      ToReversedString(tip.Hash),
+			BranchLen: tip.BranchLen,
+			Status:    tip.Status,
+		})
+	}
+	return ResponsePack(Success, tips)
+}
+
+// InvalidateBlock marks the block invalid as if it violated a consensus rule,
+// the block and its descendants are disconnected from the main chain.
+func InvalidateBlock(param Params) map[string]interface{} {
+	hash, ok := blockHashParam(param)
+	if !ok {
+		return ResponsePack(InvalidParams, "invalid block hash")
+	}
+	if err := chain.DefaultLedger.Blockchain.InvalidateBlock(hash); err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	return ResponsePack(Success, nil)
+}
+
+// ReconsiderBlock removes the invalid mark of the block set by
+// InvalidateBlock and its ancestors and descendants.
+func ReconsiderBlock(param Params) map[string]interface{} {
+	hash, ok := blockHashParam(param)
+	if !ok {
+		return ResponsePack(InvalidParams, "invalid block hash")
+	}
+	if err := chain.DefaultLedger.Blockchain.ReconsiderBlock(hash); err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	return ResponsePack(Success, nil)
+}
+
+// GetDeploymentInfo returns the states of the version bits deployments for the
+// next block, and the signalling of the current window.
+func GetDeploymentInfo(param Params) map[string]interface{} {
+	deployments, err := chain.DefaultLedger.Blockchain.GetDeploymentInfo()
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	var infos []DeploymentInfo
+	for _, d := range deployments {
+		infos = append(infos, DeploymentInfo{
+			Name:       d.Deployment.Name,
+			Bit:        d.Deployment.Bit,
+			StartTime:  d.Deployment.StartTime,
+			ExpireTime: d.Deployment.ExpireTime,
+			Threshold:  d.Deployment.Threshold,
+			Window:     d.Window,
+			State:      d.State.String(),
+			Elapsed:    d.Elapsed,
+			Count:      d.Count,
+		})
+	}
+	return ResponsePack(Success, infos)
+}
+
+// VerifyChain verifies the last blocks of the main chain, 6 blocks by
+// default and at most MaxVerifyChainBlocks.
+func VerifyChain(param Params) map[string]interface{} {
+	blocks, ok := param.Uint("blocks")
+	if !ok {
+		blocks = 6
+	}
+	if blocks == 0 || blocks > chain.MaxVerifyChainBlocks {
+		return ResponsePack(InvalidParams, fmt.Sprintf("blocks must be between 1 and %d",
+			chain.MaxVerifyChainBlocks))
+	}
+	if err := chain.DefaultLedger.Store.VerifyChain(blocks); err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	return ResponsePack(Success, true)
+}
+
+// GetTxOutSetInfo returns the statistics of the unspent outputs, TotalAmount
+// is the supply of the native asset.
+func GetTxOutSetInfo(param Params) map[string]interface{} {
+	info, err := chain.DefaultLedger.Store.GetTxOutSetInfo()
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+
+	assets := chain.DefaultLedger.Store.GetAssets()
+	assetIDs := make([]Uint256, 0, len(info.Supply))
+	for assetID := range info.Supply {
+		assetIDs = append(assetIDs, assetID)
+	}
+	sort.Slice(assetIDs, func(i, j int) bool {
+		return bytes.Compare(assetIDs[i].Bytes(), assetIDs[j].Bytes()) < 0
+	})
+	supplies := make([]AssetSupplyInfo, 0, len(assetIDs))
+	for _, assetID := range assetIDs {
+		supply := AssetSupplyInfo{
+			AssetID: ToReversedString(assetID),
+			Amount:  info.Supply[assetID].String(),
+		}
+		if asset, ok := assets[assetID]; ok {
+			supply.Name = asset.Name
+		}
+		supplies = append(supplies, supply)
+	}
+
+	return ResponsePack(Success, TxOutSetInfo{
+		Height:       info.Height,
+		BestBlock:    ToReversedString(info.BestBlock),
+		Transactions: info.Transactions,
+		TxOuts:       info.TxOuts,
+		TotalAmount:  info.Supply[chain.DefaultLedger.Blockchain.AssetID].String(),
+		Assets:       supplies,
+		SetHash:      ToReversedString(info.SetHash),
+	})
+}
+
+// ExportSnapshot writes the snapshot at the height, the best block by default,
+// to the snapshot file in the data directory.
+func ExportSnapshot(param Params) map[string]interface{} {
+	height, ok := param.Uint("height")
+	if !ok {
+		height = chain.DefaultLedger.Store.GetHeight()
+	}
+	hash, err := chain.DefaultLedger.Store.GetBlockHash(height)
+	if err != nil {
+		return ResponsePack(UnknownBlock, "")
+	}
+
+	path := chain.SnapshotFile(height)
+	file, err := os.Create(path)
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	w := bufio.NewWriter(file)
+	err = chain.DefaultLedger.Store.ExportSnapshot(w, height)
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return ResponsePack(InternalError, err.Error())
+	}
+
+	return ResponsePack(Success, SnapshotInfo{
+		Height: height,
+		Hash:   ToReversedString(hash),
+		Path:   path,
+	})
+}
+
+func blockHashParam(param Params) (Uint256, bool) {
+	var hash Uint256
+	str, ok := param.String("hash")
+	if !ok {
+		return hash, false
+	}
+	hashBytes, err := FromReversedString(str)
+	if err != nil {
+		return hash, false
+	}
+	if err := hash.Deserialize(bytes.NewReader(hashBytes)); err != nil {
+		return hash, false
+	}
+	return hash, true
+}
+
 func SendTransactionInfo(param Params) map[string]interface{} {
 
 	infoStr, ok := param.String("Info")
@@ -944,6 +1163,143 @@ func GetExistDepositTransactions(param Params) map[string]interface{} {
 	return ResponsePack(Success, resultTxHashes)
 }
 
+// GetExchangeRate returns the exchange rate from main chain to side chain at
+// height, or at the next block height if height is not given.
+func GetExchangeRate(param Params) map[string]interface{} {
+	height := chain.DefaultLedger.Store.GetHeight() + 1
+	if _, ok := param["height"]; ok {
+		h, ok := param.Uint("height")
+		if !ok {
+			return ResponsePack(InvalidParams, "height parameter should be a positive integer")
+		}
+		height = h
+	}
+
+	rate := config.Parameters.ChainParam.GetExchangeRate(height)
+	return ResponsePack(Success, ExchangeRateInfo{Height: height, Rate: Fixed64(rate).String()})
+}
+
+// GetCrossChainSummary returns the recharged, withdrawn and burned amounts of
+// the native asset from start to end height. The range is the latest not
+// pruned MaxCrossChainSummaryBlocks blocks by default, the current supply is
+// returned by gettxoutsetinfo.
+func GetCrossChainSummary(param Params) map[string]interface{} {
+	end := chain.DefaultLedger.Store.GetHeight()
+	start := uint32(0)
+	if end >= chain.MaxCrossChainSummaryBlocks {
+		start = end - chain.MaxCrossChainSummaryBlocks + 1
+	}
+	if pruneHeight, ok := chain.DefaultLedger.Store.GetPruneHeight(); ok && start <= pruneHeight {
+		start = pruneHeight + 1
+	}
+	if _, ok := param["start"]; ok {
+		h, ok := param.Uint("start")
+		if !ok {
+			return ResponsePack(InvalidParams, "start parameter should be a positive integer")
+		}
+		start = h
+	}
+	if _, ok := param["end"]; ok {
+		h, ok := param.Uint("end")
+		if !ok {
+			return ResponsePack(InvalidParams, "end parameter should be a positive integer")
+		}
+		end = h
+	}
+	if pruneHeight, ok := chain.DefaultLedger.Store.GetPruneHeight(); ok && start <= pruneHeight {
+		return ResponsePack(InvalidParams, fmt.Sprintf("blocks to height %d are pruned", pruneHeight))
+	}
+	if start > end || end > chain.DefaultLedger.Store.GetHeight() {
+		return ResponsePack(InvalidParams, "invalid height range")
+	}
+	if end-start >= chain.MaxCrossChainSummaryBlocks {
+		return ResponsePack(InvalidParams, fmt.Sprintf("height range is larger than %d blocks", chain.MaxCrossChainSummaryBlocks))
+	}
+
+	summary, err := chain.DefaultLedger.Blockchain.GetCrossChainSummary(start, end)
+	if err != nil {
+		return ResponsePack(InternalError, err.Error())
+	}
+	return ResponsePack(Success, CrossChainSummaryInfo{
+		StartHeight: summary.StartHeight,
+		EndHeight:   summary.EndHeight,
+		Recharged:   summary.Recharged.String(),
+		Destroyed:   summary.Destroyed.String(),
+		Withdrawn:   summary.Withdrawn.String(),
+		FeesBurned:  summary.FeesBurned.String(),
+	})
+}
+
+// GetDepositStatus returns if the main chain transactions are deposited, a
+// deposit is pending if the recharge transaction is in pool, or confirmed if it
+// is in a block. A confirmed deposit is reorganized if the main chain block in
+// it's proof has been rolled back.
+func GetDepositStatus(param Params) map[string]interface{} {
+	hashes, ok := param["hashes"].([]interface{})
+	if !ok {
+		return ResponsePack(InvalidParams, "need an array parameter named hashes")
+	}
+
+	results := make([]DepositStatusInfo, 0, len(hashes))
+	for _, v := range hashes {
+		str, ok := v.(string)
+		if !ok {
+			return ResponsePack(InvalidParams, "invalid main chain transaction hash")
+		}
+		bys, err := FromReversedString(str)
+		if err != nil {
+			return ResponsePack(InvalidParams, "invalid main chain transaction hash")
+		}
+		hash, err := Uint256FromBytes(bys)
+		if err != nil {
+			return ResponsePack(InvalidParams, "invalid main chain transaction hash")
+		}
+		results = append(results, getDepositStatus(str, *hash))
+	}
+	return ResponsePack(Success, results)
+}
+
+func getDepositStatus(str string, hash Uint256) DepositStatusInfo {
+	info := DepositStatusInfo{MainChainTxId: str, Status: "unknown"}
+	if chain.DefaultLedger.Store.IsMainchainTxHashDuplicate(hash) {
+		info.Status = "confirmed"
+		if chain.DefaultLedger.Store.IsDepositReorganized(hash) {
+			info.Status = "reorganized"
+		}
+		deposit, err := chain.DefaultLedger.Store.GetDeposit(hash)
+		if err != nil {
+			// deposited before the deposit records are kept
+			return info
+		}
+		info.TxId = ToReversedString(deposit.TxHash)
+		info.Height = deposit.Height
+		info.Confirmations = chain.DefaultLedger.Store.GetHeight() - deposit.Height + 1
+		for _, output := range deposit.Outputs {
+			info.Outputs = append(info.Outputs, DepositOutputInfo{
+				Address: output.Address,
+				Amount:  output.Amount.String(),
+			})
+		}
+		return info
+	}
+
+	if txn, ok := NodeForServers.GetMainchainTxInPool(hash); ok {
+		info.Status = "pending"
+		info.TxId = ToReversedString(txn.Hash())
+		for _, output := range txn.Outputs {
+			address, err := output.ProgramHash.ToAddress()
+			if err != nil {
+				continue
+			}
+			info.Outputs = append(info.Outputs, DepositOutputInfo{
+				Address: address,
+				Amount:  output.Value.String(),
+			})
+		}
+	}
+	return info
+}
+
 func GetBlockTransactionsDetail(block *Block, filter func(*Transaction) bool) interface{} {
 	var trans []*TransactionInfo
 	for _, tx := range block.Transactions {
@@ -998,6 +1354,54 @@ func GetDestroyedTransactionsByHeight(param Params) map[string]interface{} {
 	}))
 }
 
+// GetWithdrawals returns the withdrawals to main chain from height, status can
+// be "pending" or "processed" to filter the withdrawals.
+func GetWithdrawals(param Params) map[string]interface{} {
+	height, ok := param.Uint("height")
+	if !ok {
+		return ResponsePack(InvalidParams, "height parameter should be a positive integer")
+	}
+	status, ok := param.String("status")
+	if ok && status != "pending" && status != "processed" {
+		return ResponsePack(InvalidParams, "status should be pending or processed")
+	}
+
+	withdrawals, err := chain.DefaultLedger.Store.GetWithdrawals(height)
+	if err != nil {
+		return ResponsePack(InternalError, "")
+	}
+	results := make([]WithdrawalInfo, 0, len(withdrawals))
+	for _, withdrawal := range withdrawals {
+		if (status == "pending" && withdrawal.Processed) || (status == "processed" && !withdrawal.Processed) {
+			continue
+		}
+		results = append(results, getWithdrawalInfo(withdrawal))
+	}
+	return ResponsePack(Success, results)
+}
+
+func getWithdrawalInfo(withdrawal *chain.Withdrawal) WithdrawalInfo {
+	info := WithdrawalInfo{
+		TxId:    ToReversedString(withdrawal.TxHash),
+		Height:  withdrawal.Height,
+		Status:  "pending",
+		Outputs: make([]WithdrawalOutputInfo, 0, len(withdrawal.Outputs)),
+	}
+	for _, output := range withdrawal.Outputs {
+		info.Outputs = append(info.Outputs, WithdrawalOutputInfo{
+			Address: output.Address,
+			Amount:  output.Amount.String(),
+		})
+	}
+	if withdrawal.Processed {
+		info.Status = "processed"
+		info.ProcessedHeight = withdrawal.ProcessedHeight
+		info.ProcessedTxId = ToReversedString(withdrawal.ProcessedTxHash)
+		info.MainChainTxId = ToReversedString(withdrawal.MainChainTxHash)
+	}
+	return info
+}
+
 func GetIdentificationTxByIdAndPath(param Params) map[string]interface{} {
 	id, ok := param.String("id")
 	if !ok {
@@ -1036,8 +1440,164 @@ func GetIdentificationTxByIdAndPath(param Params) map[string]interface{} {
 	if err != nil {
 		return ResponsePack(UnknownBlock, "")
 	}
+	revoked, err := isIdentificationRevoked(id, path)
+	if err != nil {
+		return ResponsePack(InternalError, "")
+	}
 
-	return ResponsePack(Success, GetTransactionInfo(header, txn))
+	return ResponsePack(Success, IdentificationTransactionInfo{
+		TransactionInfo: GetTransactionInfo(header, txn),
+		Revoked:         revoked,
+	})
+}
+
+// isIdentificationRevoked returns if the latest version of the ID path is a
+// revocation.
+func isIdentificationRevoked(id, path string) (bool, error) {
+	versions, err := chain.DefaultLedger.Store.GetIdentificationHistory(id, path)
+	if err != nil {
+		return false, err
+	}
+	return len(versions) > 0 && versions[len(versions)-1].Revoked, nil
+}
+
+// GetIdentificationHistory returns all registered and revoked versions of an
+// ID path.
+func GetIdentificationHistory(param Params) map[string]interface{} {
+	id, ok := param.String("id")
+	if !ok {
+		return ResponsePack(InvalidParams, "")
+	}
+	if _, err := Uint168FromAddress(id); err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+	path, ok := param.String("path")
+	if !ok {
+		return ResponsePack(InvalidParams, "")
+	}
+
+	versions, err := chain.DefaultLedger.Store.GetIdentificationHistory(id, path)
+	if err != nil {
+		return ResponsePack(InternalError, "")
+	}
+	if len(versions) == 0 {
+		return ResponsePack(UnknownTransaction, "")
+	}
+	results := make([]IdentificationVersionInfo, 0, len(versions))
+	for _, version := range versions {
+		dataHashes := make([]string, 0, len(version.DataHashes))
+		for _, hash := range version.DataHashes {
+			dataHashes = append(dataHashes, ToReversedString(hash))
+		}
+		results = append(results, IdentificationVersionInfo{
+			Height:     version.Height,
+			TxId:       ToReversedString(version.TxHash),
+			Revoked:    version.Revoked,
+			DataHashes: dataHashes,
+		})
+	}
+	return ResponsePack(Success, results)
+}
+
+// GetIdentificationPaths returns all paths registered under an ID.
+func GetIdentificationPaths(param Params) map[string]interface{} {
+	id, ok := param.String("id")
+	if !ok {
+		return ResponsePack(InvalidParams, "")
+	}
+	if _, err := Uint168FromAddress(id); err != nil {
+		return ResponsePack(InvalidParams, "")
+	}
+
+	paths, err := chain.DefaultLedger.Store.GetIdentificationPaths(id)
+	if err != nil {
+		return ResponsePack(InternalError, "")
+	}
+	results := make([]IdentificationPathInfo, 0, len(paths))
+	for _, path := range paths {
+		revoked, err := isIdentificationRevoked(id, path)
+		if err != nil {
+			return ResponsePack(InternalError, "")
+		}
+		results = append(results, IdentificationPathInfo{Path: path, Revoked: revoked})
+	}
+	return ResponsePack(Success, results)
+}
+
+// ResolveDID returns the document of an ID with all current paths, or the
+// document at the block height if height is given.
+func ResolveDID(param Params) map[string]interface{} {
+	id, ok := param.String("id")
+	if !ok {
+		return ResponsePack(InvalidParams, "need a string parameter named id")
+	}
+	if _, err := Uint168FromAddress(id); err != nil {
+		return ResponsePack(InvalidParams, "invalid id")
+	}
+	store := chain.DefaultLedger.Store
+	height := store.GetHeight()
+	if _, ok := param["height"]; ok {
+		h, ok := param.Uint("height")
+		if !ok || h > height {
+			return ResponsePack(InvalidParams, "invalid height")
+		}
+		height = h
+	}
+
+	doc, err := store.ResolveIdentification(id, height)
+	if err == chain.ErrIdentificationNotFound {
+		return ResponsePack(UnknownTransaction, "")
+	}
+	if err != nil {
+		return ResponsePack(InternalError, "")
+	}
+	results := make([]DIDPathInfo, 0, len(doc.Paths))
+	for _, path := range doc.Paths {
+		info, err := getDIDPathInfo(path.Path, path.Version)
+		if err != nil {
+			return ResponsePack(InternalError, "")
+		}
+		results = append(results, *info)
+	}
+	address, err := doc.Controller.ToAddress()
+	if err != nil {
+		return ResponsePack(InternalError, "")
+	}
+	return ResponsePack(Success, DIDDocumentInfo{
+		Id:         id,
+		Controller: address,
+		Height:     height,
+		Paths:      results,
+	})
+}
+
+// getDIDPathInfo returns the values of path registered by the version, the
+// values are read from the identification index as the registration
+// transaction may be pruned.
+func getDIDPathInfo(path string, version *chain.IdentificationVersion) (*DIDPathInfo, error) {
+	hash, err := chain.DefaultLedger.Store.GetBlockHash(version.Height)
+	if err != nil {
+		return nil, err
+	}
+	header, err := chain.DefaultLedger.Store.GetHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &DIDPathInfo{
+		Path:      path,
+		TxId:      ToReversedString(version.TxHash),
+		Height:    version.Height,
+		Timestamp: header.Timestamp,
+		Values:    []RegisterIdentificationValueInfo{},
+	}
+	for i, dataHash := range version.DataHashes {
+		info.Values = append(info.Values, RegisterIdentificationValueInfo{
+			DataHash: ToReversedString(dataHash),
+			Proof:    version.Proofs[i],
+		})
+	}
+	return info, nil
 }
 
 func getPayload(pInfo PayloadInfo) (Payload, error) {
@@ -1071,12 +1631,69 @@ func getPayload(pInfo PayloadInfo) (Payload, error) {
 		}
 		obj.MainChainTransaction = transactionBytes
 		return obj, nil
+	case *WithdrawFromSideChainInfo:
+		obj := new(PayloadWithdrawFromSideChain)
+		proofBytes, err := HexStringToBytes(object.Proof)
+		if err != nil {
+			return nil, err
+		}
+		obj.MerkleProof = proofBytes
+		transactionBytes, err := HexStringToBytes(object.MainChainTransaction)
+		if err != nil {
+			return nil, err
+		}
+		obj.MainChainTransaction = transactionBytes
+		return obj, nil
 	case *TransferCrossChainAssetInfo:
 		obj := new(PayloadTransferCrossChainAsset)
 		obj.CrossChainAddresses = object.CrossChainAddresses
 		obj.OutputIndexes = object.OutputIndexes
 		obj.CrossChainAmounts = object.CrossChainAmounts
 		return obj, nil
+	case *RegisterIdentificationInfo:
+		obj := new(PayloadRegisterIdentification)
+		obj.ID = object.Id
+		sign, err := HexStringToBytes(object.Sign)
+		if err != nil {
+			return nil, err
+		}
+		obj.Sign = sign
+		for _, content := range object.Contents {
+			var values []RegisterIdentificationValue
+			for _, value := range content.Values {
+				hashBytes, err := FromReversedString(value.DataHash)
+				if err != nil {
+					return nil, err
+				}
+				dataHash, err := Uint256FromBytes(hashBytes)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, RegisterIdentificationValue{
+					DataHash: *dataHash,
+					Proof:    value.Proof,
+				})
+			}
+			obj.Contents = append(obj.Contents, RegisterIdentificationContent{
+				Path:   content.Path,
+				Values: values,
+			})
+		}
+		return obj, nil
+	case *RevokeIdentificationInfo:
+		obj := new(PayloadRevokeIdentification)
+		obj.ID = object.Id
+		obj.Paths = object.Paths
+		return obj, nil
+	case *RotateIdentificationKeyInfo:
+		obj := new(PayloadRotateIdentificationKey)
+		obj.ID = object.Id
+		publicKey, err := HexStringToBytes(object.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		obj.PublicKey = publicKey
+		return obj, nil
 	}
 
 	return nil, errors.New("Invalid payload type.")
@@ -1107,6 +1724,11 @@ func getPayloadInfo(p Payload) PayloadInfo {
 		obj.MainChainTransaction = BytesToHexString(object.MainChainTransaction)
 		obj.Proof = BytesToHexString(object.MerkleProof)
 		return obj
+	case *PayloadWithdrawFromSideChain:
+		obj := new(WithdrawFromSideChainInfo)
+		obj.MainChainTransaction = BytesToHexString(object.MainChainTransaction)
+		obj.Proof = BytesToHexString(object.MerkleProof)
+		return obj
 	case *PayloadRegisterIdentification:
 		obj := new(RegisterIdentificationInfo)
 		obj.Id = object.ID
@@ -1128,6 +1750,16 @@ func getPayloadInfo(p Payload) PayloadInfo {
 		}
 		obj.Contents = contents
 		return obj
+	case *PayloadRevokeIdentification:
+		obj := new(RevokeIdentificationInfo)
+		obj.Id = object.ID
+		obj.Paths = object.Paths
+		return obj
+	case *PayloadRotateIdentificationKey:
+		obj := new(RotateIdentificationKeyInfo)
+		obj.Id = object.ID
+		obj.PublicKey = BytesToHexString(object.PublicKey)
+		return obj
 	}
 	return nil
 }
@@ -1161,10 +1793,16 @@ func getTransactionInfo(txInfoBytes []byte) (*TransactionInfo, error) {
 		assetInfo = &SideChainPowInfo{}
 	case RechargeToSideChain:
 		assetInfo = &RechargeToSideChainInfo{}
+	case WithdrawFromSideChain:
+		assetInfo = &WithdrawFromSideChainInfo{}
 	case TransferCrossChainAsset:
 		assetInfo = &TransferCrossChainAssetInfo{}
 	case RegisterIdentification:
 		assetInfo = &RegisterIdentificationInfo{}
+	case RevokeIdentification:
+		assetInfo = &RevokeIdentificationInfo{}
+	case RotateIdentificationKey:
+		assetInfo = &RotateIdentificationKeyInfo{}
 	default:
 		return nil, errors.New("GetBlockTransactions: Unknown payload type")
 	}
@@ -0,0 +1,223 @@
+package servers
+
+import (
+	"errors"
+	"time"
+
+	chain "github.com/elastos/Elastos.ELA.SideChain/blockchain"
+	"github.com/elastos/Elastos.ELA.SideChain/config"
+	. "github.com/elastos/Elastos.ELA.SideChain/core"
+	. "github.com/elastos/Elastos.ELA.SideChain/errors"
+	"github.com/elastos/Elastos.ELA.SideChain/wallet"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+)
+
+// LocalWallet is nil unless WalletPath is set in config file.
+var LocalWallet *wallet.Wallet
+
+func InitWallet() error {
+	assetID := chain.DefaultLedger.Blockchain.AssetID
+	w, err := wallet.New(&wallet.Config{
+		Path:          config.Parameters.WalletPath,
+		AssetID:       assetID,
+		FeePerKB:      Fixed64(config.Parameters.WalletFeePerKB),
+		Fee:           Fixed64(config.Parameters.PowConfiguration.MinTxFee),
+		CrossChainFee: Fixed64(config.Parameters.MinCrossChainTxFee),
+		Events:        chain.DefaultLedger.Blockchain.BCEvents,
+		GetUnspents: func(programHash Uint168) ([]*wallet.UTXO, error) {
+			unspents, err := chain.DefaultLedger.Store.GetUnspentFromProgramHash(programHash, assetID)
+			if err != nil {
+				return nil, err
+			}
+			utxos := make([]*wallet.UTXO, 0, len(unspents))
+			for _, u := range unspents {
+				utxos = append(utxos, &wallet.UTXO{TxId: u.TxId, Index: u.Index, Value: u.Value, ProgramHash: programHash})
+			}
+			return utxos, nil
+		},
+		SendTransaction: func(tx *Transaction) error {
+			if errCode := VerifyAndSendTx(tx); errCode != Success {
+				return errors.New(errCode.Message())
+			}
+			return nil
+		},
+		IsInPool: func(txHash Uint256) bool {
+			_, ok := NodeForServers.GetTxInPool(txHash)
+			return ok
+		},
+	})
+	if err != nil {
+		return err
+	}
+	LocalWallet = w
+	return nil
+}
+
+func walletResponse(err error) map[string]interface{} {
+	switch err {
+	case wallet.ErrKeystoreLocked:
+		return ResponsePack(WalletLocked, "")
+	case wallet.ErrWalletNotCreated:
+		return ResponsePack(WalletLocked, err.Error())
+	case wallet.ErrInvalidPassphrase:
+		return ResponsePack(InvalidParams, err.Error())
+	default:
+		return ResponsePack(Error, err.Error())
+	}
+}
+
+func GetNewAddress(param Params) map[string]interface{} {
+	if LocalWallet == nil {
+		return ResponsePack(WalletNotEnabled, "")
+	}
+
+	m, ok := param.Int("m")
+	if !ok {
+		address, err := LocalWallet.NewAddress()
+		if err != nil {
+			return walletResponse(err)
+		}
+		return ResponsePack(Success, address)
+	}
+
+	keys, ok := param["publickeys"].([]interface{})
+	if !ok {
+		return ResponsePack(InvalidParams, "need an array parameter named publickeys")
+	}
+	var publicKeys []*crypto.PublicKey
+	for _, key := range keys {
+		str, ok := key.(string)
+		if !ok {
+			return ResponsePack(InvalidParams, "invalid public key")
+		}
+		buf, err := HexStringToBytes(str)
+		if err != nil {
+			return ResponsePack(InvalidParams, "invalid public key")
+		}
+		publicKey, err := crypto.DecodePoint(buf)
+		if err != nil {
+			return ResponsePack(InvalidParams, "invalid public key")
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+	address, err := LocalWallet.NewMultiSignAddress(int(m), publicKeys)
+	if err != nil {
+		return walletResponse(err)
+	}
+	return ResponsePack(Success, address)
+}
+
+func ListUnspent(param Params) map[string]interface{} {
+	if LocalWallet == nil {
+		return ResponsePack(WalletNotEnabled, "")
+	}
+
+	type UnspentInfo struct {
+		TxId      string `json:"txid"`
+		Index     uint32 `json:"vout"`
+		Address   string `json:"address"`
+		Amount    string `json:"amount"`
+		Spendable bool   `json:"spendable"`
+	}
+	var results []UnspentInfo
+	for _, u := range LocalWallet.ListUnspent() {
+		address, err := u.ProgramHash.ToAddress()
+		if err != nil {
+			return ResponsePack(InternalError, "")
+		}
+		results = append(results, UnspentInfo{
+			TxId:      ToReversedString(u.TxId),
+			Index:     u.Index,
+			Address:   address,
+			Amount:    u.Value.String(),
+			Spendable: u.Spendable,
+		})
+	}
+	return ResponsePack(Success, results)
+}
+
+func getAddressAndAmount(param Params) (string, Fixed64, bool) {
+	address, ok := param.String("address")
+	if !ok {
+		return "", 0, false
+	}
+	str, ok := param.String("amount")
+	if !ok {
+		return "", 0, false
+	}
+	amount, err := StringToFixed64(str)
+	if err != nil || *amount <= 0 {
+		return "", 0, false
+	}
+	return address, *amount, true
+}
+
+func SendToAddress(param Params) map[string]interface{} {
+	if LocalWallet == nil {
+		return ResponsePack(WalletNotEnabled, "")
+	}
+	address, amount, ok := getAddressAndAmount(param)
+	if !ok {
+		return ResponsePack(InvalidParams, "")
+	}
+
+	tx, err := LocalWallet.SendToAddress(address, amount)
+	if err != nil {
+		return walletResponse(err)
+	}
+	return ResponsePack(Success, ToReversedString(tx.Hash()))
+}
+
+func SendCrossChain(param Params) map[string]interface{} {
+	if LocalWallet == nil {
+		return ResponsePack(WalletNotEnabled, "")
+	}
+	address, amount, ok := getAddressAndAmount(param)
+	if !ok {
+		return ResponsePack(InvalidParams, "")
+	}
+
+	tx, err := LocalWallet.SendCrossChain(address, amount)
+	if err != nil {
+		return walletResponse(err)
+	}
+	return ResponsePack(Success, ToReversedString(tx.Hash()))
+}
+
+// CreateWallet creates the key file of the wallet protected by passphrase.
+func CreateWallet(param Params) map[string]interface{} {
+	if LocalWallet == nil {
+		return ResponsePack(WalletNotEnabled, "")
+	}
+	passphrase, ok := param.String("passphrase")
+	if !ok || len(passphrase) == 0 {
+		return ResponsePack(InvalidParams, "need a string parameter named passphrase")
+	}
+
+	if err := LocalWallet.Create(passphrase); err != nil {
+		return walletResponse(err)
+	}
+	return ResponsePack(Success, nil)
+}
+
+// WalletPassphrase unlocks the wallet for timeout seconds.
+func WalletPassphrase(param Params) map[string]interface{} {
+	if LocalWallet == nil {
+		return ResponsePack(WalletNotEnabled, "")
+	}
+	passphrase, ok := param.String("passphrase")
+	if !ok || len(passphrase) == 0 {
+		return ResponsePack(InvalidParams, "need a string parameter named passphrase")
+	}
+	timeout, ok := param.Uint("timeout")
+	if !ok || timeout == 0 {
+		return ResponsePack(InvalidParams, "need a positive parameter named timeout")
+	}
+
+	if err := LocalWallet.Unlock(passphrase, time.Duration(timeout)*time.Second); err != nil {
+		return walletResponse(err)
+	}
+	return ResponsePack(Success, nil)
+}
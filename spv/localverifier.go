@@ -0,0 +1,140 @@
+package spv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA/bloom"
+	ela "github.com/elastos/Elastos.ELA/core"
+)
+
+// LocalVerifier is a Verifier which accepts a configured set of main chain
+// block headers and transactions instead of syncing them from main chain
+// peers, it's used by unit tests and by regnet nodes without a main chain.
+type LocalVerifier struct {
+	mu      sync.RWMutex
+	headers map[common.Uint256]*ela.Header
+	txs     map[common.Uint256]common.Uint256 // transaction hash to block hash
+}
+
+func NewLocalVerifier() *LocalVerifier {
+	return &LocalVerifier{
+		headers: make(map[common.Uint256]*ela.Header),
+		txs:     make(map[common.Uint256]common.Uint256),
+	}
+}
+
+// localVerifierFile is the JSON file of a LocalVerifier, headers and
+// transactions are hex strings of their serialized data.
+type localVerifierFile struct {
+	Headers      []string
+	Transactions []struct {
+		BlockHash   string
+		Transaction string
+	}
+}
+
+// LoadLocalVerifier returns a LocalVerifier with the main chain headers and
+// transactions in the JSON file.
+func LoadLocalVerifier(path string) (*LocalVerifier, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file localVerifierFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	v := NewLocalVerifier()
+	for _, str := range file.Headers {
+		buf, err := common.HexStringToBytes(str)
+		if err != nil {
+			return nil, err
+		}
+		header := new(ela.Header)
+		if err := header.Deserialize(bytes.NewReader(buf)); err != nil {
+			return nil, err
+		}
+		v.AddHeader(header)
+	}
+	for _, item := range file.Transactions {
+		buf, err := common.HexStringToBytes(item.BlockHash)
+		if err != nil {
+			return nil, err
+		}
+		blockHash, err := common.Uint256FromBytes(common.BytesReverse(buf))
+		if err != nil {
+			return nil, err
+		}
+		buf, err = common.HexStringToBytes(item.Transaction)
+		if err != nil {
+			return nil, err
+		}
+		tx := new(ela.Transaction)
+		if err := tx.Deserialize(bytes.NewReader(buf)); err != nil {
+			return nil, err
+		}
+		if err := v.AddTransaction(*blockHash, tx); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// AddHeader adds the block header to the main chain.
+func (v *LocalVerifier) AddHeader(header *ela.Header) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.headers[header.Hash()] = header
+}
+
+// AddTransaction adds the transaction to the main chain block, the block
+// header must have been added.
+func (v *LocalVerifier) AddTransaction(blockHash common.Uint256, tx *ela.Transaction) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.headers[blockHash]; !ok {
+		return errors.New("[LocalVerifier], block header not found.")
+	}
+	v.txs[tx.Hash()] = blockHash
+	return nil
+}
+
+func (v *LocalVerifier) VerifyTransaction(proof bloom.MerkleProof, tx ela.Transaction) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	header, ok := v.headers[proof.BlockHash]
+	if !ok || header.Height != proof.Height {
+		return errors.New("[LocalVerifier], block header not found.")
+	}
+	if blockHash, ok := v.txs[tx.Hash()]; !ok || blockHash != proof.BlockHash {
+		return errors.New("[LocalVerifier], transaction not found in block.")
+	}
+	return nil
+}
+
+func (v *LocalVerifier) VerifyElaHeader(hash *common.Uint256) error {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if _, ok := v.headers[*hash]; !ok {
+		return errors.New("Verify ela header failed.")
+	}
+	return nil
+}
+
+func (v *LocalVerifier) GetBestHeight() (uint32, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	var height uint32
+	for _, header := range v.headers {
+		if header.Height > height {
+			height = header.Height
+		}
+	}
+	return height, nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/elastos/Elastos.ELA.SideChain/config"
 	"github.com/elastos/Elastos.ELA.SideChain/core"
+	"github.com/elastos/Elastos.ELA.SideChain/events"
 	"github.com/elastos/Elastos.ELA.SideChain/log"
 
 	spv "github.com/elastos/Elastos.ELA.SPV/interface"
@@ -21,7 +22,9 @@ import (
 
 var spvService spv.SPVService
 
-func SpvInit() error {
+// SpvInit starts the SPV service, main chain rollbacks are sent to bcEvents as
+// EventMainChainRollback with the height.
+func SpvInit(bcEvents *events.Event) error {
 	var err error
 	spvlog.Init(config.Parameters.SpvPrintLevel, 20, 1024)
 
@@ -35,9 +38,13 @@ func SpvInit() error {
 	if err != nil {
 		return err
 	}
+	verifier = &serviceVerifier{service: spvService}
 
 	//register an invalid address to prevent bloom filter from sending all data
-	err = spvService.RegisterTransactionListener(&SpvListener{ListenAddress: "XagqqFetxiDb9wbartKDrXgnqLagy5yY1z"})
+	err = spvService.RegisterTransactionListener(&SpvListener{
+		ListenAddress: "XagqqFetxiDb9wbartKDrXgnqLagy5yY1z",
+		Events:        bcEvents,
+	})
 	if err != nil {
 		return err
 	}
@@ -52,25 +59,35 @@ func SpvInit() error {
 	return nil
 }
 
+// VerifyTransaction verifies the main chain transaction in the payload of a
+// recharge or withdraw transaction by it's merkle proof.
 func VerifyTransaction(tx *core.Transaction) error {
 	proof := new(MerkleProof)
 	mainChainTransaction := new(ela.Transaction)
 
-	payloadObj, ok := tx.Payload.(*core.PayloadRechargeToSideChain)
-	if !ok {
-		return errors.New("Invalid payload core.PayloadRechargeToSideChain")
+	var merkleProof, transaction []byte
+	switch payloadObj := tx.Payload.(type) {
+	case *core.PayloadRechargeToSideChain:
+		merkleProof, transaction = payloadObj.MerkleProof, payloadObj.MainChainTransaction
+	case *core.PayloadWithdrawFromSideChain:
+		merkleProof, transaction = payloadObj.MerkleProof, payloadObj.MainChainTransaction
+	default:
+		return errors.New("Invalid payload type, should be recharge or withdraw payload")
 	}
 
-	reader := bytes.NewReader(payloadObj.MerkleProof)
+	reader := bytes.NewReader(merkleProof)
 	if err := proof.Deserialize(reader); err != nil {
-		return errors.New("RechargeToSideChain payload deserialize failed")
+		return errors.New(tx.TxType.Name() + " payload deserialize failed")
 	}
-	reader = bytes.NewReader(payloadObj.MainChainTransaction)
+	reader = bytes.NewReader(transaction)
 	if err := mainChainTransaction.Deserialize(reader); err != nil {
-		return errors.New("RechargeToSideChain mainChainTransaction deserialize failed")
+		return errors.New(tx.TxType.Name() + " mainChainTransaction deserialize failed")
 	}
 
-	if err := spvService.VerifyTransaction(*proof, *mainChainTransaction); err != nil {
+	if verifier == nil {
+		return errors.New("SPV verifier is not initialized.")
+	}
+	if err := verifier.VerifyTransaction(*proof, *mainChainTransaction); err != nil {
 		return errors.New("SPV module verify transaction failed.")
 	}
 
@@ -78,17 +95,24 @@ func VerifyTransaction(tx *core.Transaction) error {
 }
 
 func VerifyElaHeader(hash *common.Uint256) error {
-	blockChain := spvService.HeaderStore()
-	_, err := blockChain.GetHeader(hash)
-	if err != nil {
-		return errors.New("Verify ela header failed.")
+	if verifier == nil {
+		return errors.New("SPV verifier is not initialized.")
 	}
+	return verifier.VerifyElaHeader(hash)
+}
 
-	return nil
+// GetBestHeight returns the height of the best main chain block header known
+// by the SPV module.
+func GetBestHeight() (uint32, error) {
+	if verifier == nil {
+		return 0, errors.New("SPV verifier is not initialized.")
+	}
+	return verifier.GetBestHeight()
 }
 
 type SpvListener struct {
 	ListenAddress string
+	Events        *events.Event
 }
 
 func (l *SpvListener) Address() string {
@@ -103,7 +127,13 @@ func (l *SpvListener) Flags() uint64 {
 	return spv.FlagNotifyInSyncing
 }
 
+// Rollback is called when the main chain blocks from height are removed by a
+// reorganization, recharge transactions proved by them have to be handled.
 func (l *SpvListener) Rollback(height uint32) {
+	log.Warn("Main chain rollback to height ", height)
+	if l.Events != nil {
+		l.Events.Notify(events.EventMainChainRollback, height)
+	}
 }
 
 func (l *SpvListener) Notify(id common.Uint256, proof bloom.MerkleProof, tx ela.Transaction) {
@@ -1,10 +1,17 @@
 package spv
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+
 	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA/bloom"
+	ela "github.com/elastos/Elastos.ELA/core"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,3 +42,68 @@ func TestSpvInit(t *testing.T) {
 
 	assert.Equal(t, address, addr)
 }
+
+func TestLocalVerifier(t *testing.T) {
+	header := &ela.Header{Version: 1, Height: 100, Timestamp: 1530000000}
+	blockHash := header.Hash()
+	tx := &ela.Transaction{
+		TxType:  ela.TransferCrossChainAsset,
+		Payload: new(ela.PayloadTransferCrossChainAsset),
+	}
+	newRecharge := func(proof bloom.MerkleProof) *core.Transaction {
+		proofBuf := new(bytes.Buffer)
+		proof.Serialize(proofBuf)
+		txBuf := new(bytes.Buffer)
+		tx.Serialize(txBuf)
+		return &core.Transaction{
+			TxType: core.RechargeToSideChain,
+			Payload: &core.PayloadRechargeToSideChain{
+				MerkleProof:          proofBuf.Bytes(),
+				MainChainTransaction: txBuf.Bytes(),
+			},
+		}
+	}
+	recharge := newRecharge(bloom.MerkleProof{BlockHash: blockHash, Height: 100})
+
+	// the verifier is not initialized
+	SetVerifier(nil)
+	assert.Error(t, VerifyTransaction(recharge))
+	assert.Error(t, VerifyElaHeader(&blockHash))
+
+	verifier := NewLocalVerifier()
+	SetVerifier(verifier)
+	defer SetVerifier(nil)
+	assert.Error(t, verifier.AddTransaction(blockHash, tx))
+	assert.Error(t, VerifyElaHeader(&blockHash))
+
+	verifier.AddHeader(header)
+	assert.NoError(t, VerifyElaHeader(&blockHash))
+	assert.Error(t, VerifyTransaction(recharge))
+
+	assert.NoError(t, verifier.AddTransaction(blockHash, tx))
+	assert.NoError(t, VerifyTransaction(recharge))
+	assert.Error(t, VerifyTransaction(newRecharge(bloom.MerkleProof{BlockHash: blockHash, Height: 101})))
+	assert.Error(t, VerifyTransaction(newRecharge(bloom.MerkleProof{Height: 100})))
+
+	// load the same main chain data from file
+	headerBuf := new(bytes.Buffer)
+	header.Serialize(headerBuf)
+	txBuf := new(bytes.Buffer)
+	tx.Serialize(txBuf)
+	file, err := ioutil.TempFile("", "mainchain")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	fmt.Fprintf(file, `{"Headers": ["%s"], "Transactions": [{"BlockHash": "%s", "Transaction": "%s"}]}`,
+		common.BytesToHexString(headerBuf.Bytes()),
+		common.BytesToHexString(common.BytesReverse(blockHash.Bytes())),
+		common.BytesToHexString(txBuf.Bytes()))
+	file.Close()
+
+	loaded, err := LoadLocalVerifier(file.Name())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	SetVerifier(loaded)
+	assert.NoError(t, VerifyElaHeader(&blockHash))
+	assert.NoError(t, VerifyTransaction(recharge))
+}
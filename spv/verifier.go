@@ -0,0 +1,62 @@
+package spv
+
+import (
+	"errors"
+
+	spv "github.com/elastos/Elastos.ELA.SPV/interface"
+	"github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA/bloom"
+	ela "github.com/elastos/Elastos.ELA/core"
+)
+
+// Verifier verifies main chain transactions and block headers. It's the SPV
+// service connected to main chain peers on a running node, or a LocalVerifier
+// with configured main chain data in tests and on regnet.
+type Verifier interface {
+	// VerifyTransaction checks the transaction is in the main chain block
+	// of the merkle proof.
+	VerifyTransaction(proof bloom.MerkleProof, tx ela.Transaction) error
+
+	// VerifyElaHeader checks the block header is in the main chain.
+	VerifyElaHeader(hash *common.Uint256) error
+
+	// GetBestHeight returns the height of the best main chain block header.
+	GetBestHeight() (uint32, error)
+}
+
+var verifier Verifier
+
+// SetVerifier sets the verifier used by VerifyTransaction and VerifyElaHeader.
+func SetVerifier(v Verifier) {
+	verifier = v
+}
+
+// GetVerifier returns the verifier in use, it's nil before SpvInit or
+// SetVerifier is called.
+func GetVerifier() Verifier {
+	return verifier
+}
+
+// serviceVerifier verifies by the SPV service.
+type serviceVerifier struct {
+	service spv.SPVService
+}
+
+func (v *serviceVerifier) VerifyTransaction(proof bloom.MerkleProof, tx ela.Transaction) error {
+	return v.service.VerifyTransaction(proof, tx)
+}
+
+func (v *serviceVerifier) VerifyElaHeader(hash *common.Uint256) error {
+	if _, err := v.service.HeaderStore().GetHeader(hash); err != nil {
+		return errors.New("Verify ela header failed.")
+	}
+	return nil
+}
+
+func (v *serviceVerifier) GetBestHeight() (uint32, error) {
+	header, err := v.service.HeaderStore().GetBestHeader()
+	if err != nil {
+		return 0, err
+	}
+	return header.Height, nil
+}
@@ -50,9 +50,9 @@ func ToBigInt(data interface{}) *big.Int {
 	case uint:
 		bi.SetUint64(uint64(t))
 	case big.Int:
-		bi = t
+		bi.Set(&t)
 	case *big.Int:
-		bi = *t
+		bi.Set(t)
 	}
 	return &bi
 }
@@ -131,23 +131,25 @@ func Concat(array1 []byte, array2 []byte) []byte {
 	return array1
 }
 
+// BigIntOp returns the result of op in a new integer, the operand may be shared
+// by other stack items so it's not changed.
 func BigIntOp(bi *big.Int, op OpCode) *big.Int {
-	var nb *big.Int
+	nb := new(big.Int)
 	switch op {
 	case INC:
-		nb = bi.Add(bi, big.NewInt(int64(1)))
+		nb.Add(bi, big.NewInt(int64(1)))
 	case DEC:
-		nb = bi.Sub(bi, big.NewInt(int64(1)))
+		nb.Sub(bi, big.NewInt(int64(1)))
 	case SAL:
-		nb = bi.Lsh(bi, 1)
+		nb.Lsh(bi, 1)
 	case SAR:
-		nb = bi.Rsh(bi, 1)
+		nb.Rsh(bi, 1)
 	case NEGATE:
-		nb = bi.Neg(bi)
+		nb.Neg(bi)
 	case ABS:
-		nb = bi.Abs(bi)
+		nb.Abs(bi)
 	default:
-		nb = bi
+		nb.Set(bi)
 	}
 	return nb
 }
@@ -188,42 +190,44 @@ func ByteArrZip(s1 []byte, s2 []byte, op OpCode) []byte {
 	return ns
 }
 
+// BigIntZip returns the result of op in a new integer, the operands may be
+// shared by other stack items so they are not changed.
 func BigIntZip(ints1 *big.Int, ints2 *big.Int, op OpCode) *big.Int {
-	var nb *big.Int
+	nb := new(big.Int)
 	switch op {
 	case AND:
-		nb = ints1.And(ints1, ints2)
+		nb.And(ints1, ints2)
 	case OR:
-		nb = ints1.Or(ints1, ints2)
+		nb.Or(ints1, ints2)
 	case XOR:
-		nb = ints1.Xor(ints1, ints2)
+		nb.Xor(ints1, ints2)
 	case ADD:
-		nb = ints1.Add(ints1, ints2)
+		nb.Add(ints1, ints2)
 	case SUB:
-		nb = ints1.Sub(ints1, ints2)
+		nb.Sub(ints1, ints2)
 	case MUL:
-		nb = ints1.Mul(ints1, ints2)
+		nb.Mul(ints1, ints2)
 	case DIV:
-		nb = ints1.Div(ints1, ints2)
+		nb.Div(ints1, ints2)
 	case MOD:
-		nb = ints1.Mod(ints1, ints2)
+		nb.Mod(ints1, ints2)
 	case SHL:
-		nb = ints1.Lsh(ints1, uint(ints2.Int64()))
+		nb.Lsh(ints1, uint(ints2.Int64()))
 	case SHR:
-		nb = ints1.Rsh(ints1, uint(ints2.Int64()))
+		nb.Rsh(ints1, uint(ints2.Int64()))
 	case MIN:
 		c := ints1.Cmp(ints2)
 		if c <= 0 {
-			nb = ints1
+			nb.Set(ints1)
 		} else {
-			nb = ints2
+			nb.Set(ints2)
 		}
 	case MAX:
 		c := ints1.Cmp(ints2)
 		if c <= 0 {
-			nb = ints2
+			nb.Set(ints2)
 		} else {
-			nb = ints1
+			nb.Set(ints1)
 		}
 	}
 	return nb
@@ -0,0 +1,84 @@
+package vm
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+)
+
+const (
+	// SchnorrPublicKeyLength is the length of the x-only public key verified
+	// by CHECKSCHNORRSIG.
+	SchnorrPublicKeyLength = 32
+	// SchnorrSignatureLength is the length of a BIP-340 signature.
+	SchnorrSignatureLength = 64
+	// AggregatePublicKeyLength is the length of the compressed public keys
+	// aggregated by CHECKAGGSIG.
+	AggregatePublicKeyLength = 33
+)
+
+// CryptoSchnorr implements BIP-340 Schnorr signatures over secp256k1, the
+// message signed is sha256 of the container data.
+//
+// Public keys of a m of n program are aggregated by the MuSig2 key aggregation
+// (BIP-327) in the order of the program, the signers run the MuSig2 signing
+// protocol off chain to create one signature of the aggregated key.
+type CryptoSchnorr struct {
+	CryptoECDsa
+}
+
+func (c *CryptoSchnorr) VerifySchnorrSignature(data []byte, signature []byte, pubkey []byte) error {
+	digest := sha256.Sum256(data)
+	return verifySchnorr(digest[:], signature, pubkey)
+}
+
+// verifySchnorr verifies the BIP-340 signature of the 32 bytes message.
+func verifySchnorr(message []byte, signature []byte, pubkey []byte) error {
+	if len(signature) != SchnorrSignatureLength {
+		return errors.New("[CryptoSchnorr], invalid signature length.")
+	}
+	if len(pubkey) != SchnorrPublicKeyLength {
+		return errors.New("[CryptoSchnorr], invalid public key length.")
+	}
+	pk, err := schnorr.ParsePubKey(pubkey)
+	if err != nil {
+		return errors.New("[CryptoSchnorr], invalid public key.")
+	}
+	sig, err := schnorr.ParseSignature(signature)
+	if err != nil {
+		return errors.New("[CryptoSchnorr], invalid signature.")
+	}
+	if !sig.Verify(message, pk) {
+		return errors.New("[CryptoSchnorr], VerifySignature failed.")
+	}
+	return nil
+}
+
+// AggregatePublicKeys combines compressed public keys into the x-only MuSig2
+// aggregated key, the keys are not sorted so the order of keys matters.
+func (c *CryptoSchnorr) AggregatePublicKeys(pubkeys [][]byte) ([]byte, error) {
+	if len(pubkeys) == 0 {
+		return nil, errors.New("[CryptoSchnorr], no public keys to aggregate.")
+	}
+
+	keys := make([]*btcec.PublicKey, 0, len(pubkeys))
+	for _, pubkey := range pubkeys {
+		if len(pubkey) != AggregatePublicKeyLength {
+			return nil, errors.New("[CryptoSchnorr], invalid public key length.")
+		}
+		key, err := btcec.ParsePubKey(pubkey)
+		if err != nil {
+			return nil, errors.New("[CryptoSchnorr], invalid public key.")
+		}
+		keys = append(keys, key)
+	}
+
+	aggregated, _, _, err := musig2.AggregateKeys(keys, false)
+	if err != nil {
+		return nil, errors.New("[CryptoSchnorr], aggregate public keys failed.")
+	}
+	return schnorr.SerializePubKey(aggregated.FinalKey), nil
+}
@@ -0,0 +1,332 @@
+package vm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+)
+
+var schnorrData = []byte("Elastos side chain schnorr")
+
+// schnorrPrivateKeys are the private keys of the BIP-340 test vectors.
+var schnorrPrivateKeys = []string{
+	"0000000000000000000000000000000000000000000000000000000000000003",
+	"b7e151628aed2a6abf7158809cf4f3c762e7160f38b4da56a784d9045190cfef",
+	"c90fdaa22168c234c4c6628b80dc1cd129024e088a67cc74020bbea63b14e5c9",
+}
+
+type schnorrContainer struct{}
+
+func (c *schnorrContainer) GetData() []byte {
+	return schnorrData
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func schnorrPrivateKey(t *testing.T, i int) *btcec.PrivateKey {
+	privateKey, _ := btcec.PrivKeyFromBytes(mustDecodeHex(t, schnorrPrivateKeys[i]))
+	return privateKey
+}
+
+func schnorrSign(t *testing.T, privateKey *btcec.PrivateKey) []byte {
+	digest := sha256.Sum256(schnorrData)
+	signature, err := schnorr.Sign(privateKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signature.Serialize()
+}
+
+// musigSign runs the MuSig2 protocol between signers to sign schnorrData.
+func musigSign(t *testing.T, signers []*btcec.PrivateKey) []byte {
+	publicKeys := make([]*btcec.PublicKey, 0, len(signers))
+	for _, signer := range signers {
+		publicKeys = append(publicKeys, signer.PubKey())
+	}
+
+	sessions := make([]*musig2.Session, 0, len(signers))
+	for _, signer := range signers {
+		ctx, err := musig2.NewContext(signer, false, musig2.WithKnownSigners(publicKeys))
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, err := ctx.NewSession()
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	// first round, exchange the public nonces
+	for i, session := range sessions {
+		for j, other := range sessions {
+			if i == j {
+				continue
+			}
+			if _, err := session.RegisterPubNonce(other.PublicNonce()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// second round, exchange the partial signatures
+	digest := sha256.Sum256(schnorrData)
+	partials := make([]*musig2.PartialSignature, 0, len(sessions))
+	for _, session := range sessions {
+		partial, err := session.Sign(digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		partials = append(partials, partial)
+	}
+	for i, partial := range partials[1:] {
+		if _, err := sessions[0].CombineSig(partial); err != nil {
+			t.Fatalf("combine partial signature %d failed, %s", i+1, err)
+		}
+	}
+	return sessions[0].FinalSig().Serialize()
+}
+
+func TestSchnorrBIP340Vectors(t *testing.T) {
+	file, err := os.Open("testdata/bip340_vectors.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, record := range records[1:] {
+		index := record[0]
+		publicKey := mustDecodeHex(t, record[2])
+		message := mustDecodeHex(t, record[4])
+		signature := mustDecodeHex(t, record[5])
+		valid := record[6] == "TRUE"
+
+		if record[1] != "" {
+			privateKey, _ := btcec.PrivKeyFromBytes(mustDecodeHex(t, record[1]))
+			if !bytes.Equal(schnorr.SerializePubKey(privateKey.PubKey()), publicKey) {
+				t.Fatalf("vector %s public key mismatch", index)
+			}
+			var auxRand [32]byte
+			copy(auxRand[:], mustDecodeHex(t, record[3]))
+			sig, err := schnorr.Sign(privateKey, message, schnorr.CustomNonce(auxRand))
+			if err != nil {
+				t.Fatalf("vector %s sign failed, %s", index, err)
+			}
+			if !bytes.Equal(sig.Serialize(), signature) {
+				t.Fatalf("vector %s signature expect %x got %x", index, signature, sig.Serialize())
+			}
+		}
+
+		err := verifySchnorr(message, signature, publicKey)
+		if valid && err != nil {
+			t.Fatalf("vector %s verify failed, %s", index, err)
+		}
+		if !valid && err == nil {
+			t.Fatalf("vector %s verify passed, %s", index, record[7])
+		}
+	}
+}
+
+func TestSchnorrSignature(t *testing.T) {
+	c := new(CryptoSchnorr)
+	for i := range schnorrPrivateKeys {
+		privateKey := schnorrPrivateKey(t, i)
+		publicKey := schnorr.SerializePubKey(privateKey.PubKey())
+		signature := schnorrSign(t, privateKey)
+
+		if err := c.VerifySchnorrSignature(schnorrData, signature, publicKey); err != nil {
+			t.Fatalf("key %d verify failed, %s", i, err)
+		}
+
+		// wrong data
+		if err := c.VerifySchnorrSignature([]byte("wrong data"), signature, publicKey); err == nil {
+			t.Fatalf("key %d verify wrong data passed", i)
+		}
+
+		// signature of another key
+		other := schnorrSign(t, schnorrPrivateKey(t, (i+1)%len(schnorrPrivateKeys)))
+		if err := c.VerifySchnorrSignature(schnorrData, other, publicKey); err == nil {
+			t.Fatalf("key %d verify signature of another key passed", i)
+		}
+
+		// ECDSA length signature
+		if err := c.VerifySchnorrSignature(schnorrData, signature[:63], publicKey); err == nil {
+			t.Fatalf("key %d verify short signature passed", i)
+		}
+
+		// compressed public key
+		compressed := privateKey.PubKey().SerializeCompressed()
+		if err := c.VerifySchnorrSignature(schnorrData, signature, compressed); err == nil {
+			t.Fatalf("key %d verify with compressed public key passed", i)
+		}
+	}
+}
+
+func TestSchnorrAggregation(t *testing.T) {
+	c := new(CryptoSchnorr)
+	signers := []*btcec.PrivateKey{schnorrPrivateKey(t, 0), schnorrPrivateKey(t, 1)}
+	publicKeys := [][]byte{
+		signers[0].PubKey().SerializeCompressed(),
+		signers[1].PubKey().SerializeCompressed(),
+	}
+	aggregated, err := c.AggregatePublicKeys(publicKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the signature created by the two parties verifies for the aggregated key
+	signature := musigSign(t, signers)
+	if err := c.VerifySchnorrSignature(schnorrData, signature, aggregated); err != nil {
+		t.Fatal(err)
+	}
+
+	// but not for a single signer
+	if err := c.VerifySchnorrSignature(schnorrData, schnorrSign(t, signers[0]), aggregated); err == nil {
+		t.Fatal("signature of a single signer should not pass")
+	}
+
+	// the order of keys changes the aggregated key
+	reversed, err := c.AggregatePublicKeys([][]byte{publicKeys[1], publicKeys[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(reversed, aggregated) {
+		t.Fatal("aggregated key should depend on the order of keys")
+	}
+
+	if _, err := c.AggregatePublicKeys(nil); err == nil {
+		t.Fatal("aggregate empty keys should fail")
+	}
+	if _, err := c.AggregatePublicKeys([][]byte{aggregated}); err == nil {
+		t.Fatal("aggregate x-only key should fail")
+	}
+}
+
+func runSchnorrScript(t *testing.T, code, program []byte, flags VerifyFlags) *ExecutionEngine {
+	engine := NewExecutionEngine(new(schnorrContainer), new(CryptoSchnorr), MAXSTEPS, nil, nil)
+	engine.SetFlags(flags)
+	engine.LoadScript(code, false)
+	engine.LoadScript(program, true)
+	engine.Execute()
+	return engine
+}
+
+func pushBytes(buf *bytes.Buffer, data []byte) {
+	buf.WriteByte(byte(len(data)))
+	buf.Write(data)
+}
+
+func TestOpCheckSchnorrSig(t *testing.T) {
+	privateKey := schnorrPrivateKey(t, 0)
+
+	code := new(bytes.Buffer)
+	pushBytes(code, schnorr.SerializePubKey(privateKey.PubKey()))
+	code.WriteByte(byte(CHECKSCHNORRSIG))
+
+	program := new(bytes.Buffer)
+	pushBytes(program, schnorrSign(t, privateKey))
+	engine := runSchnorrScript(t, code.Bytes(), program.Bytes(), VerifyAll)
+	if engine.GetState() != HALT || !engine.GetExecuteResult() {
+		t.Fatal("valid schnorr signature should pass")
+	}
+
+	// CHECKSCHNORRSIG is undefined before VerifySchnorr
+	engine = runSchnorrScript(t, code.Bytes(), program.Bytes(), VerifyStrict)
+	if engine.GetState() != FAULT {
+		t.Fatal("schnorr signature before activation should fault")
+	}
+	engine = runSchnorrScript(t, code.Bytes(), program.Bytes(), 0)
+	if engine.GetState() != FAULT {
+		t.Fatal("schnorr signature before activation should fault")
+	}
+
+	program.Reset()
+	pushBytes(program, schnorrSign(t, schnorrPrivateKey(t, 1)))
+	engine = runSchnorrScript(t, code.Bytes(), program.Bytes(), VerifyAll)
+	if engine.GetState() != HALT || engine.GetExecuteResult() {
+		t.Fatal("invalid schnorr signature should not pass")
+	}
+
+	// crypto without schnorr support
+	engine = NewExecutionEngine(new(schnorrContainer), new(CryptoECDsa), MAXSTEPS, nil, nil)
+	engine.LoadScript(code.Bytes(), false)
+	engine.LoadScript(program.Bytes(), true)
+	engine.Execute()
+	if engine.GetState() != FAULT {
+		t.Fatal("schnorr signature without schnorr crypto should fault")
+	}
+}
+
+func TestOpCheckAggSig(t *testing.T) {
+	// 2 of 3 script
+	code := new(bytes.Buffer)
+	code.WriteByte(byte(PUSH2))
+	for i := range schnorrPrivateKeys {
+		pushBytes(code, schnorrPrivateKey(t, i).PubKey().SerializeCompressed())
+	}
+	code.WriteByte(byte(PUSH3))
+	code.WriteByte(byte(CHECKAGGSIG))
+
+	signature := musigSign(t, []*btcec.PrivateKey{schnorrPrivateKey(t, 0), schnorrPrivateKey(t, 2)})
+	tests := []struct {
+		description string
+		signature   []byte
+		bitmap      []byte
+		state       VMState
+		result      bool
+	}{
+		{"signed by key 1 and 3", signature, []byte{0x05}, HALT, true},
+		{"bitmap of key 1 and 2", signature, []byte{0x03}, HALT, false},
+		{"bitmap of all keys", signature, []byte{0x07}, HALT, false},
+		{"less than m signers", schnorrSign(t, schnorrPrivateKey(t, 0)), []byte{0x01}, HALT, false},
+		{"signer out of n", signature, []byte{0x0d}, FAULT, false},
+		{"bitmap too long", signature, []byte{0x05, 0x00}, FAULT, false},
+		{"empty signature", []byte{}, []byte{0x05}, HALT, false},
+	}
+	for _, test := range tests {
+		program := new(bytes.Buffer)
+		pushBytes(program, test.signature)
+		pushBytes(program, test.bitmap)
+		engine := runSchnorrScript(t, code.Bytes(), program.Bytes(), VerifyAll)
+		if engine.GetState() != test.state {
+			t.Fatalf("%s, state expect %d got %d", test.description, test.state, engine.GetState())
+		}
+		if test.state == HALT && engine.GetExecuteResult() != test.result {
+			t.Fatalf("%s, result expect %v", test.description, test.result)
+		}
+	}
+
+	// CHECKAGGSIG is undefined before VerifySchnorr
+	program := new(bytes.Buffer)
+	pushBytes(program, signature)
+	pushBytes(program, []byte{0x05})
+	engine := runSchnorrScript(t, code.Bytes(), program.Bytes(), VerifyStrict)
+	if engine.GetState() != FAULT {
+		t.Fatal("aggregated signature before activation should fault")
+	}
+
+	// missing signer bitmap
+	program.Reset()
+	pushBytes(program, signature)
+	engine = runSchnorrScript(t, code.Bytes(), program.Bytes(), VerifyAll)
+	if engine.GetState() != FAULT {
+		t.Fatal("missing signer bitmap should fault")
+	}
+}
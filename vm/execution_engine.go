@@ -5,11 +5,37 @@ import (
 	_ "math/big"
 	_ "sort"
 
+	"github.com/elastos/Elastos.ELA.SideChain/vm/errors"
 	"github.com/elastos/Elastos.ELA.SideChain/vm/interfaces"
 	"github.com/elastos/Elastos.ELA.SideChain/vm/utils"
 )
 
-const MAXSTEPS int = 1200
+const (
+	MAXSTEPS int = 1200
+
+	// MaxBigIntegerSize is the byte size limit of an integer operand or result.
+	MaxBigIntegerSize = 32
+	// MaxItemSize is the byte size limit of a single stack item.
+	MaxItemSize = 1024 * 1024
+)
+
+// VerifyFlags are the script rules activated at chain heights, the former rules
+// are kept to verify the transactions before them.
+type VerifyFlags uint32
+
+const (
+	// VerifyStrict stops the execution at the first fault and enables the
+	// SUBSTR, RET, FROMALTSTACK and XTUCK fixes and the integer size limit.
+	// Before it faults without an error are ignored, and NOP sleeps.
+	VerifyStrict VerifyFlags = 1 << iota
+
+	// VerifySchnorr enables the CHECKSCHNORRSIG and CHECKAGGSIG opcodes, they
+	// are undefined opcodes before it.
+	VerifySchnorr
+
+	// VerifyAll enables all the script rules.
+	VerifyAll = VerifyStrict | VerifySchnorr
+)
 
 func NewExecutionEngine(container interfaces.IDataContainer, crypto interfaces.ICrypto, maxSteps int, table interfaces.IScriptTable, service *GeneralService) *ExecutionEngine {
 	var engine ExecutionEngine
@@ -29,6 +55,7 @@ func NewExecutionEngine(container interfaces.IDataContainer, crypto interfaces.I
 	engine.opCode = 0
 
 	engine.maxSteps = maxSteps
+	engine.flags = VerifyAll
 
 	if service != nil {
 		engine.service = service
@@ -49,6 +76,7 @@ type ExecutionEngine struct {
 	opCount         int
 
 	maxSteps int
+	flags    VerifyFlags
 
 	evaluationStack *utils.RandomAccessStack
 	altStack        *utils.RandomAccessStack
@@ -60,6 +88,25 @@ type ExecutionEngine struct {
 	opCode OpCode
 }
 
+// SetFlags sets the script rules of the execution, all the rules are enabled
+// by default.
+func (e *ExecutionEngine) SetFlags(flags VerifyFlags) {
+	e.flags = flags
+}
+
+func (e *ExecutionEngine) strict() bool {
+	return e.flags&VerifyStrict == VerifyStrict
+}
+
+// defined reports whether opCode is defined by the script rules of e.
+func (e *ExecutionEngine) defined(opCode OpCode) bool {
+	switch opCode {
+	case CHECKSCHNORRSIG, CHECKAGGSIG:
+		return e.flags&VerifySchnorr == VerifySchnorr
+	}
+	return true
+}
+
 func (e *ExecutionEngine) GetState() VMState {
 	return e.state
 }
@@ -69,7 +116,8 @@ func (e *ExecutionEngine) GetEvaluationStack() *utils.RandomAccessStack {
 }
 
 func (e *ExecutionEngine) GetExecuteResult() bool {
-	return AssertStackItem(e.evaluationStack.Pop()).GetBoolean()
+	item := AssertStackItem(e.evaluationStack.Pop())
+	return item != nil && item.GetBoolean()
 }
 
 func (e *ExecutionEngine) ExecutingScript() []byte {
@@ -142,7 +190,16 @@ func (e *ExecutionEngine) StepInto() {
 	}
 }
 
-func (e *ExecutionEngine) ExecuteOp(opCode OpCode, context *ExecutionContext) (VMState, error) {
+func (e *ExecutionEngine) ExecuteOp(opCode OpCode, context *ExecutionContext) (state VMState, err error) {
+	if !e.strict() {
+		// the former rules panic on some faults, they are faults with
+		// errors now.
+		defer func() {
+			if r := recover(); r != nil {
+				state, err = FAULT, errors.ErrFault
+			}
+		}()
+	}
 	if opCode > PUSH16 && opCode != RET && context.PushOnly {
 		return FAULT, nil
 	}
@@ -159,14 +216,14 @@ func (e *ExecutionEngine) ExecuteOp(opCode OpCode, context *ExecutionContext) (V
 	e.opCode = opCode
 	e.context = context
 	opExec := OpExecList[opCode]
-	if opExec.Exec == nil {
+	if opExec.Exec == nil || !e.defined(opCode) {
 		return FAULT, nil
 	}
-	state, err := opExec.Exec(e)
-	if err != nil {
-		return state, err
+	state, err = opExec.Exec(e)
+	if !e.strict() && err == nil {
+		return NONE, nil
 	}
-	return NONE, nil
+	return state, err
 }
 
 func (e *ExecutionEngine) StepOut() {
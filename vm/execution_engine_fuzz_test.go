@@ -0,0 +1,185 @@
+package vm
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+)
+
+// fuzzContainer supplies fixed signable data to CHECKSIG/CHECKMULTISIG.
+type fuzzContainer struct{}
+
+func (c *fuzzContainer) GetData() []byte {
+	return []byte("elastos side chain vm fuzz")
+}
+
+// fuzzCrypto never touches real curve code so that every failure is the VM's own.
+type fuzzCrypto struct{}
+
+func (c *fuzzCrypto) Hash168(data []byte) []byte {
+	h := sha256.Sum256(data)
+	return h[:21]
+}
+
+func (c *fuzzCrypto) Hash256(data []byte) []byte {
+	h := sha256.Sum256(data)
+	return h[:]
+}
+
+func (c *fuzzCrypto) VerifySignature(data []byte, signature []byte, pubkey []byte) error {
+	if len(signature) == 0 || len(pubkey) == 0 {
+		return errFuzzVerify
+	}
+	return nil
+}
+
+var errFuzzVerify = errors.New("[fuzzCrypto], VerifySignature failed.")
+
+// runScript executes program the same way blockchain.RunPrograms does and
+// reports the final engine, converting a panic into a test failure.
+func runScript(t *testing.T, code, program []byte, flags VerifyFlags) (engine *ExecutionEngine) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("engine panic on code %x program %x flags %d: %v", code, program, flags, r)
+		}
+	}()
+	engine = NewExecutionEngine(new(fuzzContainer), new(fuzzCrypto), MAXSTEPS, nil, nil)
+	engine.SetFlags(flags)
+	engine.LoadScript(code, false)
+	engine.LoadScript(program, true)
+	engine.Execute()
+	return engine
+}
+
+func checkEngine(t *testing.T, engine *ExecutionEngine, code, program []byte) {
+	state := engine.GetState()
+	if state&HALT != HALT && state&FAULT != FAULT {
+		t.Fatalf("engine stopped in state %d on code %x program %x", state, code, program)
+	}
+	// push operations are not counted against the budget, the rest are.
+	if engine.opCount > MAXSTEPS+len(code)+len(program)+1 {
+		t.Fatalf("engine exceeded step budget, %d steps on code %x program %x",
+			engine.opCount, code, program)
+	}
+}
+
+func FuzzExecutionEngine(f *testing.F) {
+	seeds := [][2][]byte{
+		{{}, {}},
+		{{byte(PUSHT)}, {}},
+		{{byte(PUSH1), byte(PUSH2), byte(ADD), byte(PUSH3), byte(NUMEQUAL)}, {}},
+		{{byte(PUSH1), byte(PUSH0), byte(DIV)}, {}},
+		{{byte(PUSH1), byte(PUSH2), byte(PUSH3), byte(PUSH2), byte(PICK)}, {}},
+		{{byte(PUSH1), byte(PUSH2), byte(PUSH3), byte(PUSH2), byte(ROLL)}, {}},
+		{{byte(PUSH1), byte(PUSH2), byte(PUSH3), byte(PUSH1), byte(XTUCK)}, {}},
+		{{byte(PUSHBYTES1) + 3, 1, 2, 3, byte(PUSH1), byte(PUSH1), byte(SUBSTR)}, {}},
+		{{byte(PUSH1), byte(PUSH2), byte(PUSH2), byte(PACK), byte(UNPACK)}, {}},
+		{{byte(FROMALTSTACK)}, {byte(PUSH1)}},
+		{{byte(PUSHBYTES1), 1, byte(PUSHBYTES1), 2, byte(CHECKSIG)}, {}},
+		{{byte(PUSH1), byte(PUSH2), byte(PUSH3), byte(PUSH2), byte(CHECKMULTISIG)}, {}},
+		{{byte(SHA256), byte(HASH160), byte(HASH256)}, {byte(PUSH0)}},
+	}
+	for _, s := range seeds {
+		f.Add(s[0], s[1])
+	}
+	f.Fuzz(func(t *testing.T, code []byte, program []byte) {
+		for _, flags := range []VerifyFlags{VerifyAll, 0} {
+			engine := runScript(t, code, program, flags)
+			checkEngine(t, engine, code, program)
+		}
+	})
+}
+
+func TestExecutionEngineLimits(t *testing.T) {
+	repeat := func(ops []byte, n int) []byte {
+		var script []byte
+		for i := 0; i < n; i++ {
+			script = append(script, ops...)
+		}
+		return script
+	}
+	faults := map[string][]byte{
+		"div by zero":    {byte(PUSH1), byte(PUSH0), byte(DIV)},
+		"substr bounds":  {byte(PUSHBYTES1) + 1, 1, 2, byte(PUSH2), byte(PUSH1), byte(SUBSTR)},
+		"pickitem empty": {byte(PUSH1), byte(PICKITEM)},
+		"pushdata4":      {byte(PUSHDATA4), 0x7f, 0xff, 0xff, 0xff},
+		"shl overflow":   {byte(PUSH1), byte(PUSHBYTES1) + 1, 0x01, 0x01, byte(SHL)},
+		"mul overflow":   append([]byte{byte(PUSH2)}, repeat([]byte{byte(DUP), byte(MUL)}, 10)...),
+		"cat overflow":   append([]byte{byte(PUSHBYTES1), 1}, repeat([]byte{byte(DUP), byte(CAT)}, 25)...),
+		"step budget":    append(repeat([]byte{byte(NOP)}, MAXSTEPS+1), byte(PUSH1)),
+	}
+	for name, code := range faults {
+		engine := runScript(t, code, nil, VerifyStrict)
+		checkEngine(t, engine, code, nil)
+		if engine.GetState()&FAULT != FAULT {
+			t.Errorf("%s: expect FAULT got %d", name, engine.GetState())
+		}
+	}
+
+	code := []byte{byte(PUSHBYTES1) + 1, 1, 2, byte(PUSH1), byte(PUSH1), byte(SUBSTR)}
+	engine := runScript(t, code, nil, VerifyStrict)
+	checkEngine(t, engine, code, nil)
+	if engine.GetState()&HALT != HALT {
+		t.Fatalf("substr: expect HALT got %d", engine.GetState())
+	}
+	if r := AssertStackItem(engine.GetEvaluationStack().Pop()).GetByteArray(); len(r) != 1 || r[0] != 2 {
+		t.Errorf("substr: expect [2] got %v", r)
+	}
+}
+
+func TestExecutionEngineFormerRules(t *testing.T) {
+	result := func(code []byte, flags VerifyFlags) (VMState, []byte) {
+		engine := runScript(t, code, nil, flags)
+		checkEngine(t, engine, code, nil)
+		if engine.GetState()&HALT != HALT {
+			return engine.GetState(), nil
+		}
+		return engine.GetState(), AssertStackItem(engine.GetEvaluationStack().Pop()).GetByteArray()
+	}
+
+	// faults without errors are ignored before the strict rules
+	code := []byte{byte(DROP), byte(PUSH1)}
+	if state, _ := result(code, VerifyStrict); state&FAULT != FAULT {
+		t.Errorf("drop: expect FAULT got %d", state)
+	}
+	if state, r := result(code, 0); state&HALT != HALT || len(r) != 1 || r[0] != 1 {
+		t.Errorf("drop: expect HALT with [1] got %d %v", state, r)
+	}
+
+	// the former SUBSTR returns bytes from index to the length minus index
+	// and count.
+	code = []byte{byte(PUSHBYTES1) + 2, 1, 2, 3, byte(PUSH1), byte(PUSH1), byte(SUBSTR)}
+	if state, r := result(code, 0); state&HALT != HALT || len(r) != 1 || r[0] != 2 {
+		t.Errorf("substr: expect HALT with [2] got %d %v", state, r)
+	}
+	if state, r := result(code, VerifyStrict); state&HALT != HALT || len(r) != 1 || r[0] != 2 {
+		t.Errorf("substr: expect HALT with [2] got %d %v", state, r)
+	}
+	code = []byte{byte(PUSHBYTES1) + 2, 1, 2, 3, byte(PUSH0), byte(PUSH1), byte(SUBSTR)}
+	if state, r := result(code, 0); state&HALT != HALT || len(r) != 3 {
+		t.Errorf("substr: expect HALT with 3 bytes got %d %v", state, r)
+	}
+	if state, r := result(code, VerifyStrict); state&HALT != HALT || len(r) != 1 || r[0] != 1 {
+		t.Errorf("substr: expect HALT with [1] got %d %v", state, r)
+	}
+
+	// the panics of the former rules are faults
+	code = []byte{byte(FROMALTSTACK), byte(PUSH1), byte(ADD)}
+	if state, _ := result(append([]byte{byte(PUSH1)}, code...), 0); state&FAULT != FAULT {
+		t.Errorf("fromaltstack: expect FAULT got %d", state)
+	}
+}
+
+func TestExecutionEngineSharedInteger(t *testing.T) {
+	// DUP pushes the same integer, an operation on one must not change
+	// the other.
+	code := []byte{byte(PUSH1), byte(DUP), byte(INC), byte(SWAP), byte(PUSH2), byte(NUMEQUAL)}
+	for _, flags := range []VerifyFlags{VerifyStrict, 0} {
+		engine := runScript(t, code, nil, flags)
+		checkEngine(t, engine, code, nil)
+		if engine.GetState()&HALT != HALT || engine.GetEvaluationStack().Count() != 2 ||
+			engine.GetExecuteResult() {
+			t.Errorf("flags %d: the duplicated integer is changed", flags)
+		}
+	}
+}
@@ -1,11 +1,24 @@
 package vm
 
+import (
+	"math/big"
+
+	"github.com/elastos/Elastos.ELA.SideChain/vm/errors"
+)
+
 func opBigInt(e *ExecutionEngine) (VMState, error) {
 	if e.evaluationStack.Count() < 1 {
 		return FAULT, nil
 	}
 	x := AssertStackItem(e.evaluationStack.Pop()).GetBigInteger()
-	err := pushData(e, BigIntOp(x, e.opCode))
+	if e.strict() && !checkBigInteger(x) {
+		return FAULT, nil
+	}
+	r := BigIntOp(x, e.opCode)
+	if err := checkBigIntResult(e, r); err != nil {
+		return FAULT, err
+	}
+	err := pushData(e, r)
 	if err != nil {
 		return FAULT, err
 	}
@@ -42,7 +55,34 @@ func opBigIntZip(e *ExecutionEngine) (VMState, error) {
 	}
 	x2 := AssertStackItem(e.evaluationStack.Pop()).GetBigInteger()
 	x1 := AssertStackItem(e.evaluationStack.Pop()).GetBigInteger()
-	err := pushData(e, BigIntZip(x1, x2, e.opCode))
+	if e.strict() && (!checkBigInteger(x1) || !checkBigInteger(x2)) {
+		return FAULT, nil
+	}
+	// the faults below panicked or exhausted memory before the strict rules,
+	// so they are faults with errors which are not ignored by the former rules.
+	switch e.opCode {
+	case DIV, MOD:
+		if x2.Sign() == 0 {
+			return FAULT, errors.ErrBadValue
+		}
+	case SHL, SHR:
+		maxShift := int64(MaxItemSize * 8)
+		if e.strict() {
+			maxShift = MaxBigIntegerSize * 8
+		}
+		if x2.Sign() < 0 || x2.Cmp(big.NewInt(maxShift)) > 0 {
+			return FAULT, errors.ErrBadValue
+		}
+	case MUL:
+		if len(x1.Bytes())+len(x2.Bytes()) > MaxItemSize {
+			return FAULT, errors.ErrOverLen
+		}
+	}
+	r := BigIntZip(x1, x2, e.opCode)
+	if err := checkBigIntResult(e, r); err != nil {
+		return FAULT, err
+	}
+	err := pushData(e, r)
 	if err != nil {
 		return FAULT, err
 	}
@@ -88,3 +128,22 @@ func opWithIn(e *ExecutionEngine) (VMState, error) {
 	}
 	return NONE, nil
 }
+
+func checkBigInteger(value *big.Int) bool {
+	if value == nil {
+		return false
+	}
+	return len(value.Bytes()) <= MaxBigIntegerSize
+}
+
+// checkBigIntResult limits the integer results to MaxBigIntegerSize by the
+// strict rules, and to MaxItemSize before them.
+func checkBigIntResult(e *ExecutionEngine, value *big.Int) error {
+	if e.strict() && !checkBigInteger(value) {
+		return errors.ErrOverLen
+	}
+	if len(value.Bytes()) > MaxItemSize {
+		return errors.ErrOverLen
+	}
+	return nil
+}
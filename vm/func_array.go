@@ -1,5 +1,7 @@
 package vm
 
+import "github.com/elastos/Elastos.ELA.SideChain/vm/errors"
+
 func opArraySize(e *ExecutionEngine) (VMState, error) {
 	if e.evaluationStack.Count() < 1 {
 		return FAULT, nil
@@ -55,6 +57,9 @@ func opPickItem(e *ExecutionEngine) (VMState, error) {
 	if e.evaluationStack.Count() < 1 {
 		return FAULT, nil
 	}
+	if e.evaluationStack.Count() < 2 {
+		return FAULT, errors.ErrFault
+	}
 	index := int(AssertStackItem(e.evaluationStack.Pop()).GetBigInteger().Int64())
 	if index < 0 {
 		return FAULT, nil
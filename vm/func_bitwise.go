@@ -1,12 +1,14 @@
 package vm
 
+import "math/big"
+
 func opInvert(e *ExecutionEngine) (VMState, error) {
 	if e.evaluationStack.Count() < 1 {
 		return FAULT, nil
 	}
 	x := e.evaluationStack.Pop()
 	i := AssertStackItem(x).GetBigInteger()
-	err := pushData(e, i.Not(i))
+	err := pushData(e, new(big.Int).Not(i))
 	if err != nil {
 		return FAULT, err
 	}
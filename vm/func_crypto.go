@@ -5,6 +5,8 @@ import (
 	"crypto/sha256"
 	"errors"
 	"hash"
+
+	"github.com/elastos/Elastos.ELA.SideChain/vm/interfaces"
 )
 
 func opHash(e *ExecutionEngine) (VMState, error) {
@@ -44,7 +46,7 @@ func opCheckMultiSig(e *ExecutionEngine) (VMState, error) {
 	if n < 1 {
 		return FAULT, errors.New("invalid n in multisig")
 	}
-	if e.evaluationStack.Count() < n+2 {
+	if n > e.evaluationStack.Count()-2 {
 		return FAULT, errors.New("invalid element count")
 	}
 	e.opCount += n
@@ -103,6 +105,97 @@ func opCheckMultiSig(e *ExecutionEngine) (VMState, error) {
 	return NONE, nil
 }
 
+func opCheckSchnorrSig(e *ExecutionEngine) (VMState, error) {
+	if e.dataContainer == nil {
+		return FAULT, nil
+	}
+	schnorr, ok := e.crypto.(interfaces.IAggregateCrypto)
+	if !ok {
+		return FAULT, errors.New("crypto do not support schnorr signature")
+	}
+	if e.evaluationStack.Count() < 2 {
+		return FAULT, nil
+	}
+	pubkey := AssertStackItem(e.evaluationStack.Pop()).GetByteArray()
+	signature := AssertStackItem(e.evaluationStack.Pop()).GetByteArray()
+	err := schnorr.VerifySchnorrSignature(e.dataContainer.GetData(), signature, pubkey)
+	err = pushData(e, err == nil)
+	if err != nil {
+		return FAULT, err
+	}
+	return NONE, nil
+}
+
+// opCheckAggSig verifies one Schnorr signature of the public keys selected by
+// the signer bitmap, the stack is [signature, bitmap, m, pubkey_1...pubkey_n, n].
+// Bit i of the bitmap (little endian) selects pubkey_i+1.
+func opCheckAggSig(e *ExecutionEngine) (VMState, error) {
+	if e.dataContainer == nil {
+		return FAULT, nil
+	}
+	schnorr, ok := e.crypto.(interfaces.IAggregateCrypto)
+	if !ok {
+		return FAULT, errors.New("crypto do not support schnorr signature")
+	}
+	if e.evaluationStack.Count() < 5 {
+		return FAULT, errors.New("element count is not enough")
+	}
+	n := int(AssertStackItem(e.evaluationStack.Pop()).GetBigInteger().Int64())
+	if n < 1 {
+		return FAULT, errors.New("invalid n in aggregated multisig")
+	}
+	if n > e.evaluationStack.Count()-3 {
+		return FAULT, errors.New("invalid element count")
+	}
+	e.opCount += n
+	if e.opCount > e.maxSteps {
+		return FAULT, errors.New("too many OP code")
+	}
+
+	pubkeys := make([][]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		pubkeys[i] = AssertStackItem(e.evaluationStack.Pop()).GetByteArray()
+	}
+
+	m := int(AssertStackItem(e.evaluationStack.Pop()).GetBigInteger().Int64())
+	if m < 1 || m > n {
+		return FAULT, errors.New("invalid m in aggregated multisig")
+	}
+	if e.evaluationStack.Count() != 2 {
+		return FAULT, errors.New("invalid signature element count")
+	}
+	bitmap := AssertStackItem(e.evaluationStack.Pop()).GetByteArray()
+	signature := AssertStackItem(e.evaluationStack.Pop()).GetByteArray()
+	if len(bitmap) != (n+7)/8 {
+		return FAULT, errors.New("invalid signer bitmap length")
+	}
+
+	signers := make([][]byte, 0, n)
+	for i := 0; i < len(bitmap)*8; i++ {
+		if bitmap[i/8]&(1<<uint(i%8)) == 0 {
+			continue
+		}
+		if i >= n {
+			return FAULT, errors.New("invalid signer in bitmap")
+		}
+		signers = append(signers, pubkeys[i])
+	}
+
+	fSuccess := false
+	if len(signers) >= m {
+		aggregated, err := schnorr.AggregatePublicKeys(signers)
+		if err == nil {
+			err = schnorr.VerifySchnorrSignature(e.dataContainer.GetData(), signature, aggregated)
+		}
+		fSuccess = err == nil
+	}
+	err := pushData(e, fSuccess)
+	if err != nil {
+		return FAULT, err
+	}
+	return NONE, nil
+}
+
 func Hash(b []byte, e *ExecutionEngine) []byte {
 	var sh hash.Hash
 	var bt []byte
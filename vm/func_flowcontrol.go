@@ -8,7 +8,9 @@ import (
 )
 
 func opNop(e *ExecutionEngine) (VMState, error) {
-	time.Sleep(1 * time.Millisecond)
+	if !e.strict() {
+		time.Sleep(1 * time.Millisecond)
+	}
 	return NONE, nil
 }
 
@@ -21,6 +23,9 @@ func opJmp(e *ExecutionEngine) (VMState, error) {
 	}
 	fValue := true
 	if e.opCode > JMP {
+		if e.evaluationStack.Count() < 1 {
+			return FAULT, errors.ErrFault
+		}
 		s := AssertStackItem(e.evaluationStack.Pop())
 		fValue = s.GetBoolean()
 		if e.opCode == JMPIFNOT {
@@ -45,13 +50,14 @@ func opRet(e *ExecutionEngine) (VMState, error) {
 	if e.invocationStack.Count() < 2 {
 		return FAULT, nil
 	}
-	x := AssertStackItem(e.invocationStack.Pop())
-	position := x.GetBigInteger().Int64()
-	if position < 0 || position > int64(e.context.OpReader.Length()) {
-		return FAULT, nil
+	// the invocation stack holds no stack items, so the former RET always
+	// panicked here.
+	if !e.strict() {
+		return FAULT, errors.ErrFault
 	}
-	e.invocationStack.Push(x)
-	e.context.OpReader.Seek(position, io.SeekStart)
+	// leave the rest of current context unread, execution continues
+	// with the caller context on top of invocation stack.
+	e.context.OpReader.Seek(0, io.SeekEnd)
 	return NONE, nil
 }
 
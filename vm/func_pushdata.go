@@ -1,5 +1,7 @@
 package vm
 
+import "github.com/elastos/Elastos.ELA.SideChain/vm/errors"
+
 func opPushData(e *ExecutionEngine) (VMState, error) {
 	data, err := getPushData(e)
 	if err != nil {
@@ -23,14 +25,26 @@ func getPushData(e *ExecutionEngine) (interface{}, error) {
 		data = []byte{0}
 	case PUSHDATA1:
 		d, _ := e.context.OpReader.ReadByte()
-		data = e.context.OpReader.ReadBytes(int(d))
+		return readPushData(e, int(d))
 	case PUSHDATA2:
-		data = e.context.OpReader.ReadBytes(int(e.context.OpReader.ReadUint16()))
+		return readPushData(e, int(e.context.OpReader.ReadUint16()))
 	case PUSHDATA4:
-		data = e.context.OpReader.ReadBytes(int(e.context.OpReader.ReadInt32()))
+		return readPushData(e, int(e.context.OpReader.ReadInt32()))
 	case PUSHM1, PUSH1, PUSH2, PUSH3, PUSH4, PUSH5, PUSH6, PUSH7, PUSH8, PUSH9, PUSH10, PUSH11, PUSH12, PUSH13, PUSH14, PUSH15, PUSH16:
 		data = int8(e.opCode - PUSH1 + 1)
 	}
 
 	return data, nil
 }
+
+// readPushData reads count bytes of push data, the bytes beyond the script are
+// zeros before the strict rules.
+func readPushData(e *ExecutionEngine, count int) ([]byte, error) {
+	if count > MaxItemSize {
+		return nil, errors.ErrOverLen
+	}
+	if e.strict() && (count < 0 || count > e.context.OpReader.Length()) {
+		return nil, errors.ErrOverLen
+	}
+	return e.context.OpReader.ReadBytes(count), nil
+}
@@ -1,5 +1,7 @@
 package vm
 
+import "github.com/elastos/Elastos.ELA.SideChain/vm/errors"
+
 func opCat(e *ExecutionEngine) (VMState, error) {
 	if e.evaluationStack.Count() < 2 {
 		return FAULT, nil
@@ -11,6 +13,9 @@ func opCat(e *ExecutionEngine) (VMState, error) {
 	if len(b1) != len(b2) {
 		return FAULT, nil
 	}
+	if len(b1)+len(b2) > MaxItemSize {
+		return FAULT, errors.ErrOverLen
+	}
 	r := ByteArrZip(b1, b2, CAT)
 	pushData(e, r)
 	return NONE, nil
@@ -30,12 +35,25 @@ func opSubStr(e *ExecutionEngine) (VMState, error) {
 	}
 	x := e.evaluationStack.Pop()
 	s := AssertStackItem(x).GetByteArray()
-	l1 := index + count
-	l2 := len(s)
-	if l1 > l2 {
-		return FAULT, nil
+	l := len(s)
+	var b []byte
+	if e.strict() {
+		if count > l || index > l-count {
+			return FAULT, nil
+		}
+		b = s[index : index+count]
+	} else {
+		// the former SUBSTR returns the bytes from index to the length
+		// minus index and count.
+		if index+count > l {
+			return FAULT, nil
+		}
+		end := l - index - count + 1
+		if index > end || end > l {
+			return FAULT, errors.ErrOverLen
+		}
+		b = s[index:end]
 	}
-	b := s[index : l2-l1+1]
 	err := pushData(e, b)
 	if err != nil {
 		return FAULT, err
@@ -9,7 +9,11 @@ func opToAltStack(e *ExecutionEngine) (VMState, error) {
 }
 
 func opFromAltStack(e *ExecutionEngine) (VMState, error) {
-	if e.evaluationStack.Count() < 1 {
+	// the former rules check the evaluation stack instead
+	if !e.strict() && e.evaluationStack.Count() < 1 {
+		return FAULT, nil
+	}
+	if e.strict() && e.altStack.Count() < 1 {
 		return FAULT, nil
 	}
 	e.evaluationStack.Push(e.altStack.Pop())
@@ -48,6 +52,17 @@ func opXTuck(e *ExecutionEngine) (VMState, error) {
 	if n < 0 || n > e.evaluationStack.Count()-1 {
 		return FAULT, nil
 	}
+	if !e.strict() && n > 0 {
+		// the former insert nests the items below n into one item
+		element := e.evaluationStack.Element
+		index := len(element) - n
+		array := make([]interface{}, 0, len(element)+1)
+		array = append(array, element[:index])
+		array = append(array, e.evaluationStack.Peek(0))
+		array = append(array, element[index:]...)
+		e.evaluationStack.Element = array
+		return NONE, nil
+	}
 	e.evaluationStack.Insert(n, e.evaluationStack.Peek(0))
 	return NONE, nil
 }
@@ -102,7 +117,7 @@ func opPick(e *ExecutionEngine) (VMState, error) {
 	if n < 0 {
 		return FAULT, nil
 	}
-	if e.evaluationStack.Count() < n+1 {
+	if n > e.evaluationStack.Count()-1 {
 		return FAULT, nil
 	}
 	e.evaluationStack.Push(e.evaluationStack.Peek(n))
@@ -120,7 +135,7 @@ func opRoll(e *ExecutionEngine) (VMState, error) {
 	if n == 0 {
 		return NONE, nil
 	}
-	if e.evaluationStack.Count() < n+1 {
+	if n > e.evaluationStack.Count()-1 {
 		return FAULT, nil
 	}
 	e.evaluationStack.Push(e.evaluationStack.Remove(n))
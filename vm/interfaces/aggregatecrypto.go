@@ -0,0 +1,13 @@
+package interfaces
+
+// IAggregateCrypto is implemented by crypto which supports Schnorr signatures,
+// it is required by the CHECKSCHNORRSIG and CHECKAGGSIG opcodes.
+type IAggregateCrypto interface {
+	ICrypto
+
+	VerifySchnorrSignature(data []byte, signature []byte, pubkey []byte) error
+
+	// AggregatePublicKeys returns the public key which verifies the signature
+	// jointly created by the owners of pubkeys.
+	AggregatePublicKeys(pubkeys [][]byte) ([]byte, error)
+}
@@ -105,13 +105,15 @@ const (
 
 	// Crypto
 	//RIPEMD160 = 0xA6 // The input is hashed using RIPEMD-160.
-	SHA1          = 0xA7 // The input is hashed using SHA-1.
-	SHA256        = 0xA8 // The input is hashed using SHA-256.
-	HASH160       = 0xA9
-	HASH256       = 0xAA
-	CHECKSIG      = 0xAC // The entire transaction's outputs inputs and script (from the most recently-executed CODESEPARATOR to the end) are hashed. The signature used by CHECKSIG must be a valid signature for this hash and public key. If it is 1 is returned 0 otherwise.
-	CHECKREGID    = 0xAD
-	CHECKMULTISIG = 0xAE // For each signature and public key pair CHECKSIG is executed. If more public keys than signatures are listed some key/sig pairs can fail. All signatures need to match a public key. If all signatures are valid 1 is returned 0 otherwise. Due to a bug one extra unused value is removed from the stack.
+	SHA1            = 0xA7 // The input is hashed using SHA-1.
+	SHA256          = 0xA8 // The input is hashed using SHA-256.
+	HASH160         = 0xA9
+	HASH256         = 0xAA
+	CHECKSIG        = 0xAC // The entire transaction's outputs inputs and script (from the most recently-executed CODESEPARATOR to the end) are hashed. The signature used by CHECKSIG must be a valid signature for this hash and public key. If it is 1 is returned 0 otherwise.
+	CHECKREGID      = 0xAD
+	CHECKMULTISIG   = 0xAE // For each signature and public key pair CHECKSIG is executed. If more public keys than signatures are listed some key/sig pairs can fail. All signatures need to match a public key. If all signatures are valid 1 is returned 0 otherwise. Due to a bug one extra unused value is removed from the stack.
+	CHECKSCHNORRSIG = 0xB0 // The BIP-340 Schnorr signature must be a valid signature of the container data for the public key. If it is 1 is returned 0 otherwise.
+	CHECKAGGSIG     = 0xB1 // The n public keys selected by the signer bitmap are aggregated by MuSig2 and the Schnorr signature is verified against the aggregated key. At least m keys must be selected. If the signature is valid 1 is returned 0 otherwise.
 
 	// Array
 	ARRAYSIZE = 0xC0
@@ -103,13 +103,15 @@ var (
 		WITHIN:      {WITHIN, "WITHIN", opWithIn},
 
 		//Crypto
-		SHA1:          {SHA1, "SHA1", opHash},
-		SHA256:        {SHA256, "SHA256", opHash},
-		HASH160:       {HASH160, "HASH160", opHash},
-		HASH256:       {HASH256, "HASH256", opHash},
-		CHECKSIG:      {CHECKSIG, "CHECKSIG", opCheckSig},
-		CHECKREGID:    {CHECKREGID, "CHECKREGID", opCheckSig},
-		CHECKMULTISIG: {CHECKMULTISIG, "CHECKMULTISIG", opCheckMultiSig},
+		SHA1:            {SHA1, "SHA1", opHash},
+		SHA256:          {SHA256, "SHA256", opHash},
+		HASH160:         {HASH160, "HASH160", opHash},
+		HASH256:         {HASH256, "HASH256", opHash},
+		CHECKSIG:        {CHECKSIG, "CHECKSIG", opCheckSig},
+		CHECKREGID:      {CHECKREGID, "CHECKREGID", opCheckSig},
+		CHECKMULTISIG:   {CHECKMULTISIG, "CHECKMULTISIG", opCheckMultiSig},
+		CHECKSCHNORRSIG: {CHECKSCHNORRSIG, "CHECKSCHNORRSIG", opCheckSchnorrSig},
+		CHECKAGGSIG:     {CHECKAGGSIG, "CHECKAGGSIG", opCheckAggSig},
 
 		//Array
 		ARRAYSIZE: {ARRAYSIZE, "ARRAYSIZE", opArraySize},
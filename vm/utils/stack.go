@@ -26,7 +26,7 @@ func (ras *RandomAccessStack) Insert(index int, t interface{}) {
 
 	var array = make([]interface{}, 0, l+1)
 	index = l - index
-	array = append(array, ras.Element[:index])
+	array = append(array, ras.Element[:index]...)
 	array = append(array, t)
 	array = append(array, ras.Element[index:]...)
 
@@ -60,7 +60,7 @@ func (ras *RandomAccessStack) Set(index int, t interface{}) {
 	if index >= l {
 		return
 	}
-	ras.Element[index] = t
+	ras.Element[l-index-1] = t
 }
 
 func (ras *RandomAccessStack) Push(t interface{}) {
@@ -77,6 +77,9 @@ func (r *VmReader) Seek(offset int64, whence int) (int64, error) {
 
 func (r *VmReader) ReadVarBytes(max int) []byte {
 	n := int(r.ReadVarInt(uint64(max)))
+	if n > r.Length() {
+		return nil
+	}
 	return r.ReadBytes(n)
 }
 
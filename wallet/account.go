@@ -0,0 +1,123 @@
+package wallet
+
+import (
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+
+	"github.com/elastos/Elastos.ELA.SideChain/common"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+)
+
+// Account is a key pair with it's standard program, the same key also owns an
+// identification program which is used to register identification.
+type Account struct {
+	PrivateKey   []byte
+	PublicKey    *crypto.PublicKey
+	RedeemScript []byte
+	ProgramHash  Uint168
+}
+
+func NewAccount() (*Account, error) {
+	privateKey, publicKey, err := crypto.GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	return newAccount(privateKey, publicKey)
+}
+
+func NewAccountFromPrivateKey(privateKey []byte) (*Account, error) {
+	if len(privateKey) > 32 {
+		return nil, errors.New("[Wallet], invalid private key length.")
+	}
+	curve := elliptic.P256()
+	d := new(big.Int).SetBytes(privateKey)
+	if d.Sign() == 0 || d.Cmp(curve.Params().N) >= 0 {
+		return nil, errors.New("[Wallet], invalid private key.")
+	}
+	x, y := curve.ScalarBaseMult(privateKey)
+	return newAccount(privateKey, &crypto.PublicKey{X: x, Y: y})
+}
+
+func newAccount(privateKey []byte, publicKey *crypto.PublicKey) (*Account, error) {
+	redeemScript, err := crypto.CreateStandardRedeemScript(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	programHash, err := common.ToProgramHash(redeemScript)
+	if err != nil {
+		return nil, err
+	}
+	return &Account{
+		PrivateKey:   privateKey,
+		PublicKey:    publicKey,
+		RedeemScript: redeemScript,
+		ProgramHash:  *programHash,
+	}, nil
+}
+
+func (a *Account) Address() (string, error) {
+	return a.ProgramHash.ToAddress()
+}
+
+// IDRedeemScript returns the identification program of the account, it is the
+// standard program with CHECKREGID instead of CHECKSIG.
+func (a *Account) IDRedeemScript() []byte {
+	script := make([]byte, len(a.RedeemScript))
+	copy(script, a.RedeemScript)
+	script[len(script)-1] = REGISTERID
+	return script
+}
+
+func (a *Account) IDProgramHash() (*Uint168, error) {
+	return common.ToProgramHash(a.IDRedeemScript())
+}
+
+// ID returns the identification of the account, which is the address of the
+// identification program.
+func (a *Account) ID() (string, error) {
+	programHash, err := a.IDProgramHash()
+	if err != nil {
+		return "", err
+	}
+	return programHash.ToAddress()
+}
+
+func (a *Account) Sign(data []byte) ([]byte, error) {
+	return crypto.Sign(a.PrivateKey, data)
+}
+
+// MultiSignAccount is a m of n multisig program, it holds no private key,
+// each co-signer signs the transaction with it's own Account.
+type MultiSignAccount struct {
+	M            int
+	PublicKeys   []*crypto.PublicKey
+	RedeemScript []byte
+	ProgramHash  Uint168
+}
+
+func NewMultiSignAccount(m int, publicKeys []*crypto.PublicKey) (*MultiSignAccount, error) {
+	if m < 1 || m > len(publicKeys) {
+		return nil, errors.New("[Wallet], invalid m of multisig account.")
+	}
+	redeemScript, err := crypto.CreateMultiSignRedeemScript(uint(m), publicKeys)
+	if err != nil {
+		return nil, err
+	}
+	programHash, err := common.ToProgramHash(redeemScript)
+	if err != nil {
+		return nil, err
+	}
+	return &MultiSignAccount{
+		M:            m,
+		PublicKeys:   publicKeys,
+		RedeemScript: redeemScript,
+		ProgramHash:  *programHash,
+	}, nil
+}
+
+func (a *MultiSignAccount) Address() (string, error) {
+	return a.ProgramHash.ToAddress()
+}
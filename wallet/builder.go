@@ -0,0 +1,326 @@
+package wallet
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+)
+
+// UTXO is an unspent output owned by ProgramHash, it is one item of the
+// GetUnspentFromProgramHash result together with the program hash queried.
+type UTXO struct {
+	TxId        Uint256
+	Index       uint32
+	Value       Fixed64
+	ProgramHash Uint168
+}
+
+// OutPoint returns the output referenced by an input spending the UTXO, it
+// fails if Index is out of the uint16 range of input references, such an
+// output can not be spent.
+func (u *UTXO) OutPoint() (*core.OutPoint, error) {
+	if u.Index > math.MaxUint16 {
+		return nil, errors.New("[Wallet], UTXO output index out of range.")
+	}
+	return core.NewOutPoint(u.TxId, uint16(u.Index)), nil
+}
+
+// standardProgramSize is the serialized size of a signed standard program.
+var standardProgramSize = programSize(crypto.PublicKeyScriptLength, 1)
+
+// programSize returns the serialized size of a signed program of the code
+// length with m signatures.
+func programSize(codeLength int, m int) int {
+	parameterLength := m * crypto.SignatureScriptLength
+	return varBytesSize(parameterLength) + varBytesSize(codeLength)
+}
+
+func varBytesSize(length int) int {
+	switch {
+	case length < 0xfd:
+		return 1 + length
+	case length <= math.MaxUint16:
+		return 3 + length
+	default:
+		return 5 + length
+	}
+}
+
+// Output is a payment to an address, for cross chain transfer the address
+// is a main chain address.
+type Output struct {
+	Address string
+	Amount  Fixed64
+}
+
+// Builder builds transactions of one asset from a set of UTXOs, the UTXOs spent
+// by a built transaction are removed from the builder, so transactions built
+// one after another do not spend the same output.
+type Builder struct {
+	assetID       Uint256
+	change        Uint168
+	feePerKB      Fixed64
+	minFee        Fixed64
+	crossChainFee Fixed64
+	unspents      []*UTXO
+	// programSizes are the signed program sizes of program hashes which are
+	// not standard programs.
+	programSizes map[Uint168]int
+}
+
+// NewBuilder creates a builder of assetID, the change of transactions goes to
+// the change program hash.
+func NewBuilder(assetID Uint256, change Uint168) *Builder {
+	return &Builder{assetID: assetID, change: change, programSizes: make(map[Uint168]int)}
+}
+
+// SetFee sets the fee of each transaction to feePerKB for every 1000 bytes of
+// the signed transaction, but not less than minFee, which must not be less than
+// MinTxFee of the node, and for cross chain transfer MinCrossChainTxFee.
+func (b *Builder) SetFee(feePerKB Fixed64, minFee Fixed64) {
+	b.feePerKB = feePerKB
+	b.minFee = minFee
+}
+
+// SetProgram sets the program of the UTXOs of programHash signed by m
+// signatures, it is required to estimate the transaction size if the UTXOs
+// of programHash are not owned by a standard program.
+func (b *Builder) SetProgram(programHash Uint168, code []byte, m int) {
+	b.programSizes[programHash] = programSize(len(code), m)
+}
+
+// SetCrossChainFee sets the fee added to each cross chain output, which is
+// paid to the arbitrators, it must not be less than MinCrossChainTxFee.
+func (b *Builder) SetCrossChainFee(fee Fixed64) {
+	b.crossChainFee = fee
+}
+
+func (b *Builder) AddUnspents(utxos ...*UTXO) {
+	b.unspents = append(b.unspents, utxos...)
+}
+
+func (b *Builder) GetUnspents() []*UTXO {
+	return b.unspents
+}
+
+func (b *Builder) Balance() Fixed64 {
+	var balance Fixed64
+	for _, utxo := range b.unspents {
+		balance += utxo.Value
+	}
+	return balance
+}
+
+// BuildTransfer builds a TransferAsset transaction pays to outputs.
+func (b *Builder) BuildTransfer(outputs []*Output) (*core.Transaction, error) {
+	if len(outputs) == 0 {
+		return nil, errors.New("[Wallet], transfer has no outputs.")
+	}
+	txOutputs, err := b.newOutputs(outputs)
+	if err != nil {
+		return nil, err
+	}
+	return b.build(core.TransferAsset, 0, new(core.PayloadTransferAsset), txOutputs, 0)
+}
+
+// BuildCrossChainTransfer builds a TransferCrossChainAsset transaction which
+// transfers the amounts to main chain addresses. Each cross chain output is
+// locked to the empty program hash with the amount and cross chain fee.
+func (b *Builder) BuildCrossChainTransfer(outputs []*Output) (*core.Transaction, error) {
+	if len(outputs) == 0 {
+		return nil, errors.New("[Wallet], cross chain transfer has no outputs.")
+	}
+	payload := new(core.PayloadTransferCrossChainAsset)
+	txOutputs := make([]*core.Output, 0, len(outputs))
+	for i, output := range outputs {
+		programHash, err := Uint168FromAddress(output.Address)
+		if err != nil {
+			return nil, errors.New("[Wallet], invalid cross chain address " + output.Address)
+		}
+		if programHash[0] != PrefixStandard && programHash[0] != PrefixMultisig {
+			return nil, errors.New("[Wallet], invalid cross chain address " + output.Address)
+		}
+		if output.Amount <= 0 {
+			return nil, errors.New("[Wallet], invalid cross chain amount.")
+		}
+		payload.CrossChainAddresses = append(payload.CrossChainAddresses, output.Address)
+		payload.OutputIndexes = append(payload.OutputIndexes, uint64(i))
+		payload.CrossChainAmounts = append(payload.CrossChainAmounts, output.Amount)
+		txOutputs = append(txOutputs, &core.Output{
+			AssetID:     b.assetID,
+			Value:       output.Amount + b.crossChainFee,
+			ProgramHash: Uint168{},
+		})
+	}
+	return b.build(core.TransferCrossChainAsset, 0, payload, txOutputs, 0)
+}
+
+// BuildIdentification builds a RegisterIdentification transaction of the
+// identification owned by account, the payload ID is set to the account ID.
+func (b *Builder) BuildIdentification(account *Account, payload *core.PayloadRegisterIdentification) (*core.Transaction, error) {
+	if len(payload.Contents) == 0 {
+		return nil, errors.New("[Wallet], identification has no contents.")
+	}
+	id, err := account.ID()
+	if err != nil {
+		return nil, err
+	}
+	idProgramHash, err := account.IDProgramHash()
+	if err != nil {
+		return nil, err
+	}
+	payload.ID = id
+	txOutputs := []*core.Output{{
+		AssetID:     b.assetID,
+		Value:       0,
+		ProgramHash: *idProgramHash,
+	}}
+	// the identification program is signed as a standard program
+	return b.build(core.RegisterIdentification, core.RegisterIdentificationVersion, payload, txOutputs, standardProgramSize)
+}
+
+func (b *Builder) newOutputs(outputs []*Output) ([]*core.Output, error) {
+	txOutputs := make([]*core.Output, 0, len(outputs))
+	for _, output := range outputs {
+		programHash, err := Uint168FromAddress(output.Address)
+		if err != nil {
+			return nil, errors.New("[Wallet], invalid address " + output.Address)
+		}
+		if output.Amount <= 0 {
+			return nil, errors.New("[Wallet], invalid output amount.")
+		}
+		txOutputs = append(txOutputs, &core.Output{
+			AssetID:     b.assetID,
+			Value:       output.Amount,
+			ProgramHash: *programHash,
+		})
+	}
+	return txOutputs, nil
+}
+
+// build builds the transaction with the fee of it's estimated signed size,
+// extraSize is the size of programs not referenced by inputs.
+func (b *Builder) build(txType core.TransactionType, payloadVersion byte,
+	payload core.Payload, outputs []*core.Output, extraSize int) (*core.Transaction, error) {
+	var amount Fixed64
+	for _, output := range outputs {
+		amount += output.Value
+	}
+
+	// more inputs may be selected for a higher fee, which makes the transaction
+	// larger, so repeat until the fee covers the size.
+	fee := b.minFee
+	for {
+		tx, selected, err := b.buildWithFee(txType, payloadVersion, payload, outputs, amount+fee)
+		if err != nil {
+			return nil, err
+		}
+		size := b.estimateSize(tx, selected) + extraSize
+		if required := b.feePerKB * Fixed64(size) / 1000; required > fee {
+			fee = required
+			continue
+		}
+		b.spend(selected)
+		return tx, nil
+	}
+}
+
+func (b *Builder) buildWithFee(txType core.TransactionType, payloadVersion byte,
+	payload core.Payload, outputs []*core.Output, total Fixed64) (*core.Transaction, []*UTXO, error) {
+	selected, amount, err := b.selectCoins(total)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inputs := make([]*core.Input, 0, len(selected))
+	for _, utxo := range selected {
+		outPoint, err := utxo.OutPoint()
+		if err != nil {
+			return nil, nil, err
+		}
+		inputs = append(inputs, &core.Input{Previous: *outPoint})
+	}
+	txOutputs := outputs[:len(outputs):len(outputs)]
+	if change := amount - total; change > 0 {
+		txOutputs = append(txOutputs, &core.Output{
+			AssetID:     b.assetID,
+			Value:       change,
+			ProgramHash: b.change,
+		})
+	}
+
+	return &core.Transaction{
+		TxType:         txType,
+		PayloadVersion: payloadVersion,
+		Payload:        payload,
+		Attributes:     []*core.Attribute{},
+		Inputs:         inputs,
+		Outputs:        txOutputs,
+		Programs:       []*core.Program{},
+	}, selected, nil
+}
+
+// estimateSize returns the size of the transaction after the inputs are signed.
+func (b *Builder) estimateSize(tx *core.Transaction, selected []*UTXO) int {
+	size := tx.GetSize()
+	signed := make(map[Uint168]bool)
+	for _, utxo := range selected {
+		if signed[utxo.ProgramHash] {
+			continue
+		}
+		signed[utxo.ProgramHash] = true
+		if programSize, ok := b.programSizes[utxo.ProgramHash]; ok {
+			size += programSize
+		} else {
+			size += standardProgramSize
+		}
+	}
+	return size
+}
+
+// selectCoins prefers the smallest UTXO which covers the total alone, if there
+// is no such UTXO, UTXOs are picked from the largest one until total is reached,
+// so the transaction has as few inputs as possible.
+func (b *Builder) selectCoins(total Fixed64) ([]*UTXO, Fixed64, error) {
+	unspents := make([]*UTXO, len(b.unspents))
+	copy(unspents, b.unspents)
+	sort.SliceStable(unspents, func(i, j int) bool {
+		return unspents[i].Value < unspents[j].Value
+	})
+
+	for _, utxo := range unspents {
+		if utxo.Value >= total {
+			return []*UTXO{utxo}, utxo.Value, nil
+		}
+	}
+
+	var selected []*UTXO
+	var amount Fixed64
+	for i := len(unspents) - 1; i >= 0; i-- {
+		selected = append(selected, unspents[i])
+		amount += unspents[i].Value
+		if amount >= total {
+			return selected, amount, nil
+		}
+	}
+	return nil, 0, errors.New("[Wallet], available balance is not enough.")
+}
+
+func (b *Builder) spend(selected []*UTXO) {
+	spent := make(map[*UTXO]bool, len(selected))
+	for _, utxo := range selected {
+		spent[utxo] = true
+	}
+	unspents := make([]*UTXO, 0, len(b.unspents))
+	for _, utxo := range b.unspents {
+		if !spent[utxo] {
+			unspents = append(unspents, utxo)
+		}
+	}
+	b.unspents = unspents
+}
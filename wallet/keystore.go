@@ -0,0 +1,354 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	KeystoreVersion = 1
+
+	// KeystoreIterations is the PBKDF2 iteration count to derive the
+	// encryption key from passphrase.
+	KeystoreIterations = 16384
+
+	keystoreSaltLength = 32
+)
+
+var (
+	ErrKeystoreLocked    = errors.New("[Keystore], keystore is locked.")
+	ErrInvalidPassphrase = errors.New("[Keystore], invalid passphrase.")
+)
+
+// keystoreFile is the json format of the key file, private keys are encrypted
+// by AES-GCM with a key derived from the passphrase, public keys are kept in
+// plain text so the addresses can be tracked while the keystore is locked.
+type keystoreFile struct {
+	Version    int             `json:"version"`
+	Salt       string          `json:"salt"`
+	Iterations int             `json:"iterations"`
+	Nonce      string          `json:"nonce"`
+	CipherText string          `json:"ciphertext"`
+	PublicKeys []string        `json:"publickeys"`
+	MultiSign  []multiSignFile `json:"multisign"`
+}
+
+type multiSignFile struct {
+	M          int      `json:"m"`
+	PublicKeys []string `json:"publickeys"`
+}
+
+// Keystore stores accounts in an encrypted key file.
+type Keystore struct {
+	mu         sync.RWMutex
+	path       string
+	salt       []byte
+	iterations int
+	key        []byte // encryption key, nil when locked
+
+	publicKeys  []*crypto.PublicKey
+	privateKeys [][]byte // nil when locked
+	multiSign   []*MultiSignAccount
+}
+
+// CreateKeystore creates a new key file protected by passphrase, the keystore
+// is unlocked after created.
+func CreateKeystore(path string, passphrase []byte) (*Keystore, error) {
+	if _, err := os.Stat(path); err == nil {
+		return nil, errors.New("[Keystore], key file already exists.")
+	}
+	salt := make([]byte, keystoreSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	ks := &Keystore{
+		path:        path,
+		salt:        salt,
+		iterations:  KeystoreIterations,
+		privateKeys: [][]byte{},
+	}
+	ks.key = ks.deriveKey(passphrase)
+	if err := ks.save(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// OpenKeystore opens an existing key file, the keystore is locked.
+func OpenKeystore(path string) (*Keystore, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file keystoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, errors.New("[Keystore], invalid key file.")
+	}
+	if file.Version != KeystoreVersion {
+		return nil, errors.New("[Keystore], unsupported key file version.")
+	}
+	salt, err := hex.DecodeString(file.Salt)
+	if err != nil || file.Iterations <= 0 {
+		return nil, errors.New("[Keystore], invalid key file.")
+	}
+
+	ks := &Keystore{path: path, salt: salt, iterations: file.Iterations}
+	for _, publicKey := range file.PublicKeys {
+		pk, err := decodePublicKey(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		ks.publicKeys = append(ks.publicKeys, pk)
+	}
+	for _, multiSign := range file.MultiSign {
+		var publicKeys []*crypto.PublicKey
+		for _, publicKey := range multiSign.PublicKeys {
+			pk, err := decodePublicKey(publicKey)
+			if err != nil {
+				return nil, err
+			}
+			publicKeys = append(publicKeys, pk)
+		}
+		account, err := NewMultiSignAccount(multiSign.M, publicKeys)
+		if err != nil {
+			return nil, err
+		}
+		ks.multiSign = append(ks.multiSign, account)
+	}
+	return ks, nil
+}
+
+// Unlock decrypts private keys with passphrase.
+func (ks *Keystore) Unlock(passphrase []byte) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	data, err := ioutil.ReadFile(ks.path)
+	if err != nil {
+		return err
+	}
+	var file keystoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return errors.New("[Keystore], invalid key file.")
+	}
+	nonce, err := hex.DecodeString(file.Nonce)
+	if err != nil {
+		return errors.New("[Keystore], invalid key file.")
+	}
+	cipherText, err := hex.DecodeString(file.CipherText)
+	if err != nil {
+		return errors.New("[Keystore], invalid key file.")
+	}
+
+	key := ks.deriveKey(passphrase)
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return errors.New("[Keystore], invalid key file.")
+	}
+	plainText, err := gcm.Open(nil, nonce, cipherText, nil)
+	if err != nil {
+		return ErrInvalidPassphrase
+	}
+	if len(plainText)%32 != 0 || len(plainText)/32 != len(ks.publicKeys) {
+		return errors.New("[Keystore], private keys do not match public keys.")
+	}
+
+	privateKeys := make([][]byte, 0, len(ks.publicKeys))
+	for i := 0; i < len(plainText); i += 32 {
+		privateKeys = append(privateKeys, plainText[i:i+32])
+	}
+	ks.key = key
+	ks.privateKeys = privateKeys
+	return nil
+}
+
+// Lock removes the decrypted private keys from memory.
+func (ks *Keystore) Lock() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.key = nil
+	ks.privateKeys = nil
+}
+
+func (ks *Keystore) IsLocked() bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.key == nil
+}
+
+// NewAccount creates a new standard account and saves it into key file.
+func (ks *Keystore) NewAccount() (*Account, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if ks.key == nil {
+		return nil, ErrKeystoreLocked
+	}
+
+	account, err := NewAccount()
+	if err != nil {
+		return nil, err
+	}
+	privateKey := make([]byte, 32)
+	copy(privateKey[32-len(account.PrivateKey):], account.PrivateKey)
+	account.PrivateKey = privateKey
+
+	ks.publicKeys = append(ks.publicKeys, account.PublicKey)
+	ks.privateKeys = append(ks.privateKeys, privateKey)
+	if err := ks.save(); err != nil {
+		ks.publicKeys = ks.publicKeys[:len(ks.publicKeys)-1]
+		ks.privateKeys = ks.privateKeys[:len(ks.privateKeys)-1]
+		return nil, err
+	}
+	return account, nil
+}
+
+// AddMultiSignAccount adds a multisig account into key file, the keystore
+// only holds public keys of it.
+func (ks *Keystore) AddMultiSignAccount(m int, publicKeys []*crypto.PublicKey) (*MultiSignAccount, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if ks.key == nil {
+		return nil, ErrKeystoreLocked
+	}
+
+	account, err := NewMultiSignAccount(m, publicKeys)
+	if err != nil {
+		return nil, err
+	}
+	for _, multiSign := range ks.multiSign {
+		if multiSign.ProgramHash == account.ProgramHash {
+			return multiSign, nil
+		}
+	}
+	ks.multiSign = append(ks.multiSign, account)
+	if err := ks.save(); err != nil {
+		ks.multiSign = ks.multiSign[:len(ks.multiSign)-1]
+		return nil, err
+	}
+	return account, nil
+}
+
+// GetAccounts returns the standard accounts, private keys of the accounts are
+// nil if the keystore is locked.
+func (ks *Keystore) GetAccounts() []*Account {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	accounts := make([]*Account, 0, len(ks.publicKeys))
+	for i, publicKey := range ks.publicKeys {
+		var privateKey []byte
+		if ks.privateKeys != nil {
+			privateKey = ks.privateKeys[i]
+		}
+		account, err := newAccount(privateKey, publicKey)
+		if err != nil {
+			continue
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts
+}
+
+func (ks *Keystore) GetMultiSignAccounts() []*MultiSignAccount {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return append([]*MultiSignAccount(nil), ks.multiSign...)
+}
+
+func (ks *Keystore) save() error {
+	gcm, err := newGCM(ks.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	plainText := make([]byte, 0, len(ks.privateKeys)*32)
+	for _, privateKey := range ks.privateKeys {
+		plainText = append(plainText, privateKey...)
+	}
+
+	file := keystoreFile{
+		Version:    KeystoreVersion,
+		Salt:       hex.EncodeToString(ks.salt),
+		Iterations: ks.iterations,
+		Nonce:      hex.EncodeToString(nonce),
+		CipherText: hex.EncodeToString(gcm.Seal(nil, nonce, plainText, nil)),
+		PublicKeys: []string{},
+		MultiSign:  []multiSignFile{},
+	}
+	for _, publicKey := range ks.publicKeys {
+		pk, err := encodePublicKey(publicKey)
+		if err != nil {
+			return err
+		}
+		file.PublicKeys = append(file.PublicKeys, pk)
+	}
+	for _, multiSign := range ks.multiSign {
+		var publicKeys []string
+		for _, publicKey := range multiSign.PublicKeys {
+			pk, err := encodePublicKey(publicKey)
+			if err != nil {
+				return err
+			}
+			publicKeys = append(publicKeys, pk)
+		}
+		file.MultiSign = append(file.MultiSign, multiSignFile{M: multiSign.M, PublicKeys: publicKeys})
+	}
+
+	data, err := json.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first, so a failure will not break the key file
+	temp := ks.path + ".tmp"
+	if err := ioutil.WriteFile(temp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temp, ks.path)
+}
+
+// deriveKey derives the encryption key by PBKDF2-HMAC-SHA256, only one block
+// is needed for a 32 bytes key.
+func (ks *Keystore) deriveKey(passphrase []byte) []byte {
+	return pbkdf2.Key(passphrase, ks.salt, ks.iterations, 32, sha256.New)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encodePublicKey(publicKey *crypto.PublicKey) (string, error) {
+	pk, err := publicKey.EncodePoint(true)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(pk), nil
+}
+
+func decodePublicKey(publicKey string) (*crypto.PublicKey, error) {
+	pk, err := hex.DecodeString(publicKey)
+	if err != nil {
+		return nil, errors.New("[Keystore], invalid public key.")
+	}
+	return crypto.DecodePoint(pk)
+}
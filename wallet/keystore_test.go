@@ -0,0 +1,65 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeystore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "keystore.dat")
+
+	ks, err := CreateKeystore(path, []byte("passphrase"))
+	assert.NoError(t, err)
+	assert.False(t, ks.IsLocked())
+	_, err = CreateKeystore(path, []byte("passphrase"))
+	assert.Error(t, err)
+
+	account, err := ks.NewAccount()
+	assert.NoError(t, err)
+	other, err := NewAccount()
+	assert.NoError(t, err)
+	multi, err := ks.AddMultiSignAccount(2, []*crypto.PublicKey{account.PublicKey, other.PublicKey})
+	assert.NoError(t, err)
+
+	ks.Lock()
+	assert.True(t, ks.IsLocked())
+	_, err = ks.NewAccount()
+	assert.Equal(t, ErrKeystoreLocked, err)
+	assert.Nil(t, ks.GetAccounts()[0].PrivateKey)
+
+	// reopen the key file
+	ks, err = OpenKeystore(path)
+	assert.NoError(t, err)
+	assert.True(t, ks.IsLocked())
+	accounts := ks.GetAccounts()
+	assert.Equal(t, 1, len(accounts))
+	assert.Equal(t, account.ProgramHash, accounts[0].ProgramHash)
+	assert.Equal(t, 1, len(ks.GetMultiSignAccounts()))
+	assert.Equal(t, multi.ProgramHash, ks.GetMultiSignAccounts()[0].ProgramHash)
+
+	assert.Equal(t, ErrInvalidPassphrase, ks.Unlock([]byte("wrong")))
+	assert.True(t, ks.IsLocked())
+	assert.NoError(t, ks.Unlock([]byte("passphrase")))
+	assert.Equal(t, account.PrivateKey, ks.GetAccounts()[0].PrivateKey)
+
+	// private keys are not stored in plain text
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), hex.EncodeToString(account.PrivateKey))
+}
+
+func TestKeystoreDeriveKey(t *testing.T) {
+	// PBKDF2-HMAC-SHA256 test vector
+	ks := &Keystore{salt: []byte("salt"), iterations: 4096}
+	assert.Equal(t, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a",
+		hex.EncodeToString(ks.deriveKey([]byte("password"))))
+}
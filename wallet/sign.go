@@ -0,0 +1,159 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/elastos/Elastos.ELA.SideChain/common"
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+)
+
+// SignTransaction signs the transaction for the standard program of account.
+func SignTransaction(tx *core.Transaction, account *Account) error {
+	program := getProgram(tx, account.RedeemScript)
+	signature, err := signProgram(tx, program, account)
+	if err != nil {
+		return err
+	}
+	program.Parameter = pushSignature(nil, signature)
+	return sortPrograms(tx.Programs)
+}
+
+// SignIdentification sets the Sign of a RegisterIdentification payload and
+// signs the payload for the identification program of account, it changes the
+// payload so it must be called before SignTransaction.
+func SignIdentification(tx *core.Transaction, account *Account) error {
+	payload, ok := tx.Payload.(*core.PayloadRegisterIdentification)
+	if !ok {
+		return errors.New("[Wallet], not a register identification transaction.")
+	}
+	sign, err := account.Sign(payload.SignData())
+	if err != nil {
+		return err
+	}
+	payload.Sign = sign
+
+	program := getProgram(tx, account.IDRedeemScript())
+	signature, err := signProgram(tx, program, account)
+	if err != nil {
+		return err
+	}
+	program.Parameter = pushSignature(nil, signature)
+	return sortPrograms(tx.Programs)
+}
+
+// SignMultiSignTransaction adds the signature of account to the multisig
+// program, co-signers call it one by one until M signatures are collected.
+func SignMultiSignTransaction(tx *core.Transaction, multi *MultiSignAccount, account *Account) error {
+	var isSigner bool
+	for _, publicKey := range multi.PublicKeys {
+		if publicKey.X.Cmp(account.PublicKey.X) == 0 && publicKey.Y.Cmp(account.PublicKey.Y) == 0 {
+			isSigner = true
+			break
+		}
+	}
+	if !isSigner {
+		return errors.New("[Wallet], account is not a signer of the multisig account.")
+	}
+
+	program := getProgram(tx, multi.RedeemScript)
+	data, err := getSignData(tx, program)
+	if err != nil {
+		return err
+	}
+	signatures := parseSignatures(program.Parameter)
+	if len(signatures) >= multi.M {
+		return errors.New("[Wallet], multisig transaction already has enough signatures.")
+	}
+	for _, signature := range signatures {
+		if crypto.Verify(*account.PublicKey, data, signature) == nil {
+			return errors.New("[Wallet], multisig transaction already signed by account.")
+		}
+	}
+
+	signature, err := account.Sign(data)
+	if err != nil {
+		return err
+	}
+	program.Parameter = pushSignature(program.Parameter, signature)
+	return sortPrograms(tx.Programs)
+}
+
+// MultiSignCompleted returns if the multisig program has collected M signatures.
+func MultiSignCompleted(tx *core.Transaction, multi *MultiSignAccount) bool {
+	for _, program := range tx.Programs {
+		if bytes.Equal(program.Code, multi.RedeemScript) {
+			return len(parseSignatures(program.Parameter)) >= multi.M
+		}
+	}
+	return false
+}
+
+func getProgram(tx *core.Transaction, code []byte) *core.Program {
+	for _, program := range tx.Programs {
+		if bytes.Equal(program.Code, code) {
+			return program
+		}
+	}
+	program := &core.Program{Code: code, Parameter: []byte{}}
+	tx.Programs = append(tx.Programs, program)
+	return program
+}
+
+func getSignData(tx *core.Transaction, program *core.Program) ([]byte, error) {
+	programHash, err := common.ToProgramHash(program.Code)
+	if err != nil {
+		return nil, err
+	}
+	return tx.GetDataContainer(programHash).GetData(), nil
+}
+
+func signProgram(tx *core.Transaction, program *core.Program, account *Account) ([]byte, error) {
+	data, err := getSignData(tx, program)
+	if err != nil {
+		return nil, err
+	}
+	return account.Sign(data)
+}
+
+func pushSignature(parameter []byte, signature []byte) []byte {
+	buf := bytes.NewBuffer(parameter)
+	buf.WriteByte(byte(len(signature)))
+	buf.Write(signature)
+	return buf.Bytes()
+}
+
+// parseSignatures splits the parameter of a multisig program into signatures.
+func parseSignatures(parameter []byte) [][]byte {
+	var signatures [][]byte
+	for len(parameter) > 0 {
+		length := int(parameter[0])
+		if length == 0 || len(parameter) < length+1 {
+			break
+		}
+		signatures = append(signatures, parameter[1:length+1])
+		parameter = parameter[length+1:]
+	}
+	return signatures
+}
+
+// sortPrograms sorts programs by program hash as blockchain.SortPrograms does,
+// programs must be in this order to pass the signature verification.
+func sortPrograms(programs []*core.Program) error {
+	hashes := make(map[*core.Program]Uint168, len(programs))
+	for _, program := range programs {
+		programHash, err := common.ToProgramHash(program.Code)
+		if err != nil {
+			return err
+		}
+		hashes[program] = *programHash
+	}
+	sort.SliceStable(programs, func(i, j int) bool {
+		return hashes[programs[i]].Compare(hashes[programs[j]]) < 0
+	})
+	return nil
+}
@@ -0,0 +1,431 @@
+package wallet
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+	"github.com/elastos/Elastos.ELA.SideChain/events"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+)
+
+var (
+	ErrWalletNotCreated = errors.New("[Wallet], wallet is not created.")
+	ErrWalletCreated    = errors.New("[Wallet], wallet is already created.")
+)
+
+// Config is what the node provides to the wallet, the wallet depends on these
+// functions instead of the blockchain package, so it can be used by clients.
+type Config struct {
+	// Path is the key file path.
+	Path    string
+	AssetID Uint256
+	// FeePerKB is the fee paid for every 1000 bytes of a transaction, Fee is
+	// the minimum fee of a transaction.
+	FeePerKB      Fixed64
+	Fee           Fixed64
+	CrossChainFee Fixed64
+
+	// Events is the blockchain events, the wallet updates it's UTXOs when a
+	// block is persisted or rolled back.
+	Events *events.Event
+
+	// GetUnspents returns the UTXOs of the program hash in the chain.
+	GetUnspents func(programHash Uint168) ([]*UTXO, error)
+
+	// SendTransaction puts the transaction into pool and relays it.
+	SendTransaction func(tx *core.Transaction) error
+
+	// IsInPool returns if the transaction is in the transaction pool, outputs
+	// spent by a sent transaction are spendable again once it's not in the
+	// pool nor in the chain.
+	IsInPool func(txHash Uint256) bool
+}
+
+// UnspentInfo is an UTXO of the wallet, Spendable is false if the wallet does
+// not hold enough keys to sign it, or it is spent by a pending transaction.
+type UnspentInfo struct {
+	UTXO
+	Spendable bool
+}
+
+// Wallet tracks UTXOs of the accounts in a keystore and sends transactions.
+type Wallet struct {
+	mu       sync.RWMutex
+	cfg      *Config
+	keystore *Keystore
+	lockTime *time.Timer
+
+	unspents map[core.OutPoint]*UTXO
+	// pending are the outputs spent by transactions not in a block yet
+	pending map[core.OutPoint]Uint256
+}
+
+// New opens the wallet of cfg.Path, if the key file not exists, the wallet is
+// created by Create.
+func New(cfg *Config) (*Wallet, error) {
+	w := &Wallet{
+		cfg:      cfg,
+		unspents: make(map[core.OutPoint]*UTXO),
+		pending:  make(map[core.OutPoint]Uint256),
+	}
+	if _, err := os.Stat(cfg.Path); err == nil {
+		w.keystore, err = OpenKeystore(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		if err := w.rescan(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Events != nil {
+		cfg.Events.Subscribe(events.EventBlockPersistCompleted, w.blockPersistCompleted)
+		cfg.Events.Subscribe(events.EventRollbackTransaction, w.rollbackTransaction)
+	}
+	return w, nil
+}
+
+// Create creates the key file of the wallet protected by passphrase, the
+// wallet is locked after created.
+func (w *Wallet) Create(passphrase string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.keystore != nil {
+		return ErrWalletCreated
+	}
+	if len(passphrase) == 0 {
+		return errors.New("[Wallet], passphrase is empty.")
+	}
+	keystore, err := CreateKeystore(w.cfg.Path, []byte(passphrase))
+	if err != nil {
+		return err
+	}
+	keystore.Lock()
+	w.keystore = keystore
+	return nil
+}
+
+// Unlock unlocks the keystore for timeout, the keystore is locked again when
+// timeout expires or Lock is called.
+func (w *Wallet) Unlock(passphrase string, timeout time.Duration) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.keystore == nil {
+		return ErrWalletNotCreated
+	}
+	if timeout <= 0 {
+		return errors.New("[Wallet], unlock timeout must be positive.")
+	}
+	if err := w.keystore.Unlock([]byte(passphrase)); err != nil {
+		return err
+	}
+
+	if w.lockTime != nil {
+		w.lockTime.Stop()
+	}
+	w.lockTime = time.AfterFunc(timeout, w.keystore.Lock)
+	return nil
+}
+
+func (w *Wallet) Lock() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.lockTime != nil {
+		w.lockTime.Stop()
+		w.lockTime = nil
+	}
+	if w.keystore != nil {
+		w.keystore.Lock()
+	}
+}
+
+// NewAddress creates a new standard account and returns it's address.
+func (w *Wallet) NewAddress() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.keystore == nil {
+		return "", ErrWalletNotCreated
+	}
+	account, err := w.keystore.NewAccount()
+	if err != nil {
+		return "", err
+	}
+	return account.Address()
+}
+
+// NewMultiSignAddress adds a m of n multisig account to the wallet and returns
+// it's address, the UTXOs of it are spendable if the wallet holds m keys.
+func (w *Wallet) NewMultiSignAddress(m int, publicKeys []*crypto.PublicKey) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.keystore == nil {
+		return "", ErrWalletNotCreated
+	}
+	account, err := w.keystore.AddMultiSignAccount(m, publicKeys)
+	if err != nil {
+		return "", err
+	}
+	utxos, err := w.cfg.GetUnspents(account.ProgramHash)
+	if err != nil {
+		return "", err
+	}
+	for _, utxo := range utxos {
+		outPoint, err := utxo.OutPoint()
+		if err != nil {
+			continue
+		}
+		w.unspents[*outPoint] = utxo
+	}
+	return account.Address()
+}
+
+func (w *Wallet) ListUnspent() []*UnspentInfo {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	signers := w.getSigners()
+	unspents := make([]*UnspentInfo, 0, len(w.unspents))
+	for _, utxo := range w.unspents {
+		_, canSign := signers[utxo.ProgramHash]
+		unspents = append(unspents, &UnspentInfo{UTXO: *utxo, Spendable: canSign})
+	}
+	return unspents
+}
+
+// GetBalance returns the amount of spendable UTXOs.
+func (w *Wallet) GetBalance() Fixed64 {
+	var balance Fixed64
+	for _, utxo := range w.ListUnspent() {
+		if utxo.Spendable {
+			balance += utxo.Value
+		}
+	}
+	return balance
+}
+
+// SendToAddress pays amount to address and returns the transaction sent.
+func (w *Wallet) SendToAddress(address string, amount Fixed64) (*core.Transaction, error) {
+	return w.send(func(builder *Builder) (*core.Transaction, error) {
+		return builder.BuildTransfer([]*Output{{Address: address, Amount: amount}})
+	})
+}
+
+// SendCrossChain transfers amount to the main chain address.
+func (w *Wallet) SendCrossChain(address string, amount Fixed64) (*core.Transaction, error) {
+	return w.send(func(builder *Builder) (*core.Transaction, error) {
+		return builder.BuildCrossChainTransfer([]*Output{{Address: address, Amount: amount}})
+	})
+}
+
+func (w *Wallet) send(build func(*Builder) (*core.Transaction, error)) (*core.Transaction, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.keystore == nil {
+		return nil, ErrWalletNotCreated
+	}
+	if w.keystore.IsLocked() {
+		return nil, ErrKeystoreLocked
+	}
+	accounts := w.keystore.GetAccounts()
+	if len(accounts) == 0 {
+		return nil, errors.New("[Wallet], wallet has no account.")
+	}
+
+	signers := w.getSigners()
+	builder := NewBuilder(w.cfg.AssetID, accounts[0].ProgramHash)
+	builder.SetFee(w.cfg.FeePerKB, w.cfg.Fee)
+	builder.SetCrossChainFee(w.cfg.CrossChainFee)
+	for programHash, s := range signers {
+		if s.multi != nil {
+			builder.SetProgram(programHash, s.multi.RedeemScript, s.multi.M)
+		}
+	}
+	for _, utxo := range w.unspents {
+		if _, ok := signers[utxo.ProgramHash]; ok {
+			builder.AddUnspents(utxo)
+		}
+	}
+
+	tx, err := build(builder)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.sign(tx, signers); err != nil {
+		return nil, err
+	}
+	if err := w.cfg.SendTransaction(tx); err != nil {
+		return nil, err
+	}
+
+	for _, input := range tx.Inputs {
+		delete(w.unspents, input.Previous)
+		w.pending[input.Previous] = tx.Hash()
+	}
+	return tx, nil
+}
+
+// sign signs every program referenced by the transaction inputs.
+func (w *Wallet) sign(tx *core.Transaction, signers map[Uint168]*signer) error {
+	signed := make(map[Uint168]bool)
+	for _, input := range tx.Inputs {
+		utxo, ok := w.unspents[input.Previous]
+		if !ok {
+			return errors.New("[Wallet], unknown transaction input.")
+		}
+		if signed[utxo.ProgramHash] {
+			continue
+		}
+		signed[utxo.ProgramHash] = true
+
+		s := signers[utxo.ProgramHash]
+		if s.multi == nil {
+			if err := SignTransaction(tx, s.accounts[0]); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, account := range s.accounts {
+			if MultiSignCompleted(tx, s.multi) {
+				break
+			}
+			if err := SignMultiSignTransaction(tx, s.multi, account); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// signer is the accounts of the wallet which can sign a program hash, multi is
+// nil for a standard program.
+type signer struct {
+	accounts []*Account
+	multi    *MultiSignAccount
+}
+
+// getSigners returns the program hashes can be signed by the wallet.
+func (w *Wallet) getSigners() map[Uint168]*signer {
+	signers := make(map[Uint168]*signer)
+	if w.keystore == nil {
+		return signers
+	}
+	accounts := w.keystore.GetAccounts()
+	for _, account := range accounts {
+		signers[account.ProgramHash] = &signer{accounts: []*Account{account}}
+	}
+	for _, multi := range w.keystore.GetMultiSignAccounts() {
+		var owned []*Account
+		for _, publicKey := range multi.PublicKeys {
+			for _, account := range accounts {
+				if crypto.Equal(publicKey, account.PublicKey) {
+					owned = append(owned, account)
+				}
+			}
+		}
+		if len(owned) >= multi.M {
+			signers[multi.ProgramHash] = &signer{accounts: owned, multi: multi}
+		}
+	}
+	return signers
+}
+
+func (w *Wallet) getProgramHashes() map[Uint168]bool {
+	programHashes := make(map[Uint168]bool)
+	if w.keystore == nil {
+		return programHashes
+	}
+	for _, account := range w.keystore.GetAccounts() {
+		programHashes[account.ProgramHash] = true
+	}
+	for _, multi := range w.keystore.GetMultiSignAccounts() {
+		programHashes[multi.ProgramHash] = true
+	}
+	return programHashes
+}
+
+// rescan reloads UTXOs of the wallet from chain, outputs spent by pending
+// transactions are excluded until they are spent in chain, or the pending
+// transaction is dropped from the transaction pool.
+func (w *Wallet) rescan() error {
+	unspents := make(map[core.OutPoint]*UTXO)
+	for programHash := range w.getProgramHashes() {
+		utxos, err := w.cfg.GetUnspents(programHash)
+		if err != nil {
+			return err
+		}
+		for _, utxo := range utxos {
+			// outputs can not be spent by inputs are skipped
+			outPoint, err := utxo.OutPoint()
+			if err != nil {
+				continue
+			}
+			unspents[*outPoint] = utxo
+		}
+	}
+
+	for outPoint, txHash := range w.pending {
+		_, unspent := unspents[outPoint]
+		if unspent && (w.cfg.IsInPool == nil || w.cfg.IsInPool(txHash)) {
+			delete(unspents, outPoint)
+		} else {
+			delete(w.pending, outPoint)
+		}
+	}
+	w.unspents = unspents
+	return nil
+}
+
+// isRelated returns if the block spends or pays to the wallet.
+func (w *Wallet) isRelated(block *core.Block) bool {
+	programHashes := w.getProgramHashes()
+	for _, tx := range block.Transactions {
+		for _, input := range tx.Inputs {
+			if _, ok := w.unspents[input.Previous]; ok {
+				return true
+			}
+			if _, ok := w.pending[input.Previous]; ok {
+				return true
+			}
+		}
+		for _, output := range tx.Outputs {
+			if programHashes[output.ProgramHash] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (w *Wallet) blockPersistCompleted(v interface{}) {
+	block, ok := v.(*core.Block)
+	if !ok {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// pending transactions may be dropped from the transaction pool by the
+	// block, so rescan if there is any.
+	if len(w.pending) > 0 || w.isRelated(block) {
+		w.rescan()
+	}
+}
+
+func (w *Wallet) rollbackTransaction(v interface{}) {
+	if _, ok := v.(*core.Block); !ok {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rescan()
+}
@@ -0,0 +1,451 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA.SideChain/blockchain"
+	"github.com/elastos/Elastos.ELA.SideChain/core"
+
+	. "github.com/elastos/Elastos.ELA.Utility/common"
+	"github.com/elastos/Elastos.ELA.Utility/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// utxoStore returns references of the UTXOs added to the test, the other
+// methods of IChainStore are not used by VerifySignature.
+type utxoStore struct {
+	blockchain.IChainStore
+	outputs map[core.OutPoint]*core.Output
+}
+
+func (s *utxoStore) GetTxReference(tx *core.Transaction) (map[*core.Input]*core.Output, error) {
+	reference := make(map[*core.Input]*core.Output)
+	for _, input := range tx.Inputs {
+		output, ok := s.outputs[input.Previous]
+		if !ok {
+			return nil, errTestReference
+		}
+		reference[input] = output
+	}
+	return reference, nil
+}
+
+var errTestReference = errors.New("reference not found")
+
+var testAssetID = Uint256{1}
+
+func newTestStore() *utxoStore {
+	store := &utxoStore{outputs: make(map[core.OutPoint]*core.Output)}
+	blockchain.DefaultLedger = &blockchain.Ledger{Store: store}
+	return store
+}
+
+func (s *utxoStore) addUnspents(programHash Uint168, values ...Fixed64) []*UTXO {
+	var utxos []*UTXO
+	for i, value := range values {
+		var txID Uint256
+		rand.Read(txID[:])
+		s.outputs[*core.NewOutPoint(txID, uint16(i))] = &core.Output{
+			AssetID:     testAssetID,
+			Value:       value,
+			ProgramHash: programHash,
+		}
+		utxos = append(utxos, &UTXO{TxId: txID, Index: uint32(i), Value: value, ProgramHash: programHash})
+	}
+	return utxos
+}
+
+func totalValue(outputs []*core.Output) Fixed64 {
+	var total Fixed64
+	for _, output := range outputs {
+		total += output.Value
+	}
+	return total
+}
+
+func TestBuildTransfer(t *testing.T) {
+	store := newTestStore()
+	account, err := NewAccount()
+	assert.NoError(t, err)
+	receiver, err := NewAccount()
+	assert.NoError(t, err)
+	address, err := receiver.Address()
+	assert.NoError(t, err)
+
+	builder := NewBuilder(testAssetID, account.ProgramHash)
+	builder.SetFee(0, 100)
+	builder.AddUnspents(store.addUnspents(account.ProgramHash, 300, 1000, 5000, 20000)...)
+
+	// the smallest UTXO covers amount and fee
+	tx, err := builder.BuildTransfer([]*Output{{Address: address, Amount: 800}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tx.Inputs))
+	assert.Equal(t, 2, len(tx.Outputs))
+	assert.Equal(t, receiver.ProgramHash, tx.Outputs[0].ProgramHash)
+	assert.Equal(t, Fixed64(800), tx.Outputs[0].Value)
+	assert.Equal(t, account.ProgramHash, tx.Outputs[1].ProgramHash)
+	assert.Equal(t, Fixed64(100), tx.Outputs[1].Value)
+	assert.Equal(t, Fixed64(300+5000+20000), builder.Balance())
+
+	// unsigned transaction does not pass
+	assert.Error(t, blockchain.VerifySignature(tx, 0))
+
+	assert.NoError(t, SignTransaction(tx, account))
+	assert.NoError(t, blockchain.VerifySignature(tx, 0))
+
+	// signed by another account
+	assert.NoError(t, SignTransaction(tx, receiver))
+	assert.Error(t, blockchain.VerifySignature(tx, 0))
+
+	// largest UTXOs are picked when no UTXO covers the amount alone
+	tx, err = builder.BuildTransfer([]*Output{{Address: address, Amount: 24800}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(tx.Inputs))
+	assert.Equal(t, 2, len(tx.Outputs))
+	assert.Equal(t, Fixed64(100), tx.Outputs[1].Value)
+	assert.Equal(t, Fixed64(300), builder.Balance())
+	assert.NoError(t, SignTransaction(tx, account))
+	assert.NoError(t, blockchain.VerifySignature(tx, 0))
+
+	// balance is not enough
+	_, err = builder.BuildTransfer([]*Output{{Address: address, Amount: 300}})
+	assert.EqualError(t, err, "[Wallet], available balance is not enough.")
+	assert.Equal(t, Fixed64(300), builder.Balance())
+
+	_, err = builder.BuildTransfer([]*Output{{Address: "invalid", Amount: 100}})
+	assert.Error(t, err)
+}
+
+func TestBuildFeeBySize(t *testing.T) {
+	store := newTestStore()
+	account, err := NewAccount()
+	assert.NoError(t, err)
+	address, err := account.Address()
+	assert.NoError(t, err)
+
+	feeOf := func(tx *core.Transaction) Fixed64 {
+		var input Fixed64
+		for _, i := range tx.Inputs {
+			input += store.outputs[i.Previous].Value
+		}
+		return input - totalValue(tx.Outputs)
+	}
+
+	// the fee is paid by the signed size
+	builder := NewBuilder(testAssetID, account.ProgramHash)
+	builder.SetFee(10000, 100)
+	builder.AddUnspents(store.addUnspents(account.ProgramHash, 100000)...)
+	tx, err := builder.BuildTransfer([]*Output{{Address: address, Amount: 1000}})
+	assert.NoError(t, err)
+	assert.NoError(t, SignTransaction(tx, account))
+	fee := feeOf(tx)
+	assert.True(t, fee >= Fixed64(tx.GetSize()*10), "fee %d of size %d", fee, tx.GetSize())
+	assert.True(t, fee < Fixed64(tx.GetSize()*10+100), "fee %d of size %d", fee, tx.GetSize())
+
+	// more inputs pay more fee
+	builder = NewBuilder(testAssetID, account.ProgramHash)
+	builder.SetFee(10000, 100)
+	builder.AddUnspents(store.addUnspents(account.ProgramHash, 3000, 3000, 3000, 3000)...)
+	tx, err = builder.BuildTransfer([]*Output{{Address: address, Amount: 8000}})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(tx.Inputs))
+	assert.NoError(t, SignTransaction(tx, account))
+	assert.True(t, feeOf(tx) >= Fixed64(tx.GetSize()*10))
+
+	// the minimum fee applies to small transactions
+	builder = NewBuilder(testAssetID, account.ProgramHash)
+	builder.SetFee(1, 100)
+	builder.AddUnspents(store.addUnspents(account.ProgramHash, 100000)...)
+	tx, err = builder.BuildTransfer([]*Output{{Address: address, Amount: 1000}})
+	assert.NoError(t, err)
+	assert.Equal(t, Fixed64(100), feeOf(tx))
+
+	// the signed size of multisig programs
+	var accounts []*Account
+	var publicKeys []*crypto.PublicKey
+	for i := 0; i < 3; i++ {
+		account, err := NewAccount()
+		assert.NoError(t, err)
+		accounts = append(accounts, account)
+		publicKeys = append(publicKeys, account.PublicKey)
+	}
+	multi, err := NewMultiSignAccount(2, publicKeys)
+	assert.NoError(t, err)
+	builder = NewBuilder(testAssetID, multi.ProgramHash)
+	builder.SetFee(10000, 100)
+	builder.SetProgram(multi.ProgramHash, multi.RedeemScript, multi.M)
+	builder.AddUnspents(store.addUnspents(multi.ProgramHash, 100000)...)
+	tx, err = builder.BuildTransfer([]*Output{{Address: address, Amount: 1000}})
+	assert.NoError(t, err)
+	assert.NoError(t, SignMultiSignTransaction(tx, multi, accounts[0]))
+	assert.NoError(t, SignMultiSignTransaction(tx, multi, accounts[1]))
+	assert.True(t, MultiSignCompleted(tx, multi))
+	assert.True(t, feeOf(tx) >= Fixed64(tx.GetSize()*10))
+}
+
+func TestUTXOOutPoint(t *testing.T) {
+	utxo := &UTXO{TxId: Uint256{1}, Index: math.MaxUint16, Value: 100}
+	outPoint, err := utxo.OutPoint()
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(math.MaxUint16), outPoint.Index)
+
+	// the index can not be referenced by an input
+	utxo.Index = math.MaxUint16 + 1
+	_, err = utxo.OutPoint()
+	assert.EqualError(t, err, "[Wallet], UTXO output index out of range.")
+
+	account, err := NewAccount()
+	assert.NoError(t, err)
+	address, err := account.Address()
+	assert.NoError(t, err)
+	builder := NewBuilder(testAssetID, account.ProgramHash)
+	builder.SetFee(0, 10)
+	builder.AddUnspents(utxo)
+	_, err = builder.BuildTransfer([]*Output{{Address: address, Amount: 1}})
+	assert.EqualError(t, err, "[Wallet], UTXO output index out of range.")
+	assert.Equal(t, 1, len(builder.GetUnspents()))
+}
+
+func TestBuildMultiSignTransfer(t *testing.T) {
+	store := newTestStore()
+	var accounts []*Account
+	var publicKeys []*crypto.PublicKey
+	for i := 0; i < 3; i++ {
+		account, err := NewAccount()
+		assert.NoError(t, err)
+		accounts = append(accounts, account)
+		publicKeys = append(publicKeys, account.PublicKey)
+	}
+	multi, err := NewMultiSignAccount(2, publicKeys)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(PrefixMultisig), multi.ProgramHash[0])
+	single := accounts[0]
+
+	builder := NewBuilder(testAssetID, multi.ProgramHash)
+	builder.SetFee(0, 100)
+	builder.AddUnspents(store.addUnspents(multi.ProgramHash, 1000)...)
+	builder.AddUnspents(store.addUnspents(single.ProgramHash, 1000)...)
+
+	address, err := single.Address()
+	assert.NoError(t, err)
+	tx, err := builder.BuildTransfer([]*Output{{Address: address, Amount: 1500}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(tx.Inputs))
+
+	assert.NoError(t, SignTransaction(tx, single))
+	assert.NoError(t, SignMultiSignTransaction(tx, multi, accounts[1]))
+	assert.False(t, MultiSignCompleted(tx, multi))
+	assert.Error(t, blockchain.VerifySignature(tx, 0))
+
+	// the same signer can not sign twice
+	err = SignMultiSignTransaction(tx, multi, accounts[1])
+	assert.EqualError(t, err, "[Wallet], multisig transaction already signed by account.")
+
+	// not a signer
+	other, err := NewAccount()
+	assert.NoError(t, err)
+	err = SignMultiSignTransaction(tx, multi, other)
+	assert.EqualError(t, err, "[Wallet], account is not a signer of the multisig account.")
+
+	assert.NoError(t, SignMultiSignTransaction(tx, multi, accounts[2]))
+	assert.True(t, MultiSignCompleted(tx, multi))
+	assert.NoError(t, blockchain.VerifySignature(tx, 0))
+
+	err = SignMultiSignTransaction(tx, multi, accounts[0])
+	assert.EqualError(t, err, "[Wallet], multisig transaction already has enough signatures.")
+}
+
+func TestBuildCrossChainTransfer(t *testing.T) {
+	store := newTestStore()
+	account, err := NewAccount()
+	assert.NoError(t, err)
+	mainChainAccount, err := NewAccount()
+	assert.NoError(t, err)
+	mainChainAddress, err := mainChainAccount.Address()
+	assert.NoError(t, err)
+
+	builder := NewBuilder(testAssetID, account.ProgramHash)
+	builder.SetFee(0, 10000)
+	builder.SetCrossChainFee(10000)
+	builder.AddUnspents(store.addUnspents(account.ProgramHash, 100000)...)
+
+	tx, err := builder.BuildCrossChainTransfer([]*Output{{Address: mainChainAddress, Amount: 50000}})
+	assert.NoError(t, err)
+	payload := tx.Payload.(*core.PayloadTransferCrossChainAsset)
+	assert.Equal(t, []string{mainChainAddress}, payload.CrossChainAddresses)
+	assert.Equal(t, []uint64{0}, payload.OutputIndexes)
+	assert.Equal(t, []Fixed64{50000}, payload.CrossChainAmounts)
+	assert.Equal(t, Uint168{}, tx.Outputs[0].ProgramHash)
+	assert.Equal(t, Fixed64(60000), tx.Outputs[0].Value)
+	assert.Equal(t, Fixed64(100000-10000), totalValue(tx.Outputs))
+
+	assert.NoError(t, SignTransaction(tx, account))
+	assert.NoError(t, blockchain.VerifySignature(tx, 0))
+
+	// only standard and multisig main chain addresses
+	id, err := account.ID()
+	assert.NoError(t, err)
+	_, err = builder.BuildCrossChainTransfer([]*Output{{Address: id, Amount: 50000}})
+	assert.Error(t, err)
+}
+
+func TestBuildIdentification(t *testing.T) {
+	store := newTestStore()
+	account, err := NewAccount()
+	assert.NoError(t, err)
+
+	builder := NewBuilder(testAssetID, account.ProgramHash)
+	builder.SetFee(0, 100)
+	builder.AddUnspents(store.addUnspents(account.ProgramHash, 1000)...)
+
+	payload := &core.PayloadRegisterIdentification{
+		Contents: []core.RegisterIdentificationContent{{
+			Path:   "kyc/person/identityCard",
+			Values: []core.RegisterIdentificationValue{{DataHash: Uint256{2}, Proof: "proof"}},
+		}},
+	}
+	tx, err := builder.BuildIdentification(account, payload)
+	assert.NoError(t, err)
+	id, err := account.ID()
+	assert.NoError(t, err)
+	assert.Equal(t, id, payload.ID)
+	idProgramHash, err := account.IDProgramHash()
+	assert.NoError(t, err)
+	assert.Equal(t, byte(PrefixRegisterId), idProgramHash[0])
+	assert.Equal(t, *idProgramHash, tx.Outputs[0].ProgramHash)
+
+	// the identification program must be signed as well
+	assert.NoError(t, SignIdentification(tx, account))
+	assert.Error(t, blockchain.VerifySignature(tx, 0))
+	assert.NoError(t, blockchain.CheckRegisterIdentificationPayload(tx,
+		tx.Payload.(*core.PayloadRegisterIdentification)))
+
+	assert.NoError(t, SignTransaction(tx, account))
+	assert.Equal(t, 2, len(tx.Programs))
+	assert.NoError(t, blockchain.VerifySignature(tx, 0))
+}
+
+func TestNewAccountFromPrivateKey(t *testing.T) {
+	account, err := NewAccount()
+	assert.NoError(t, err)
+
+	restored, err := NewAccountFromPrivateKey(account.PrivateKey)
+	assert.NoError(t, err)
+	assert.Equal(t, account.RedeemScript, restored.RedeemScript)
+	assert.Equal(t, account.ProgramHash, restored.ProgramHash)
+
+	_, err = NewAccountFromPrivateKey(make([]byte, 32))
+	assert.Error(t, err)
+	_, err = NewAccountFromPrivateKey(append(account.PrivateKey, 0))
+	assert.Error(t, err)
+}
+
+func TestWallet(t *testing.T) {
+	store := newTestStore()
+	dir, err := ioutil.TempDir("", "wallet")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	chain := make(map[Uint168][]*UTXO)
+	var sent []*core.Transaction
+	pool := make(map[Uint256]bool)
+	w, err := New(&Config{
+		Path:    filepath.Join(dir, "keystore.dat"),
+		AssetID: testAssetID,
+		Fee:     100,
+		GetUnspents: func(programHash Uint168) ([]*UTXO, error) {
+			return chain[programHash], nil
+		},
+		SendTransaction: func(tx *core.Transaction) error {
+			sent = append(sent, tx)
+			pool[tx.Hash()] = true
+			return blockchain.VerifySignature(tx, 0)
+		},
+		IsInPool: func(txHash Uint256) bool {
+			return pool[txHash]
+		},
+	})
+	assert.NoError(t, err)
+
+	// the wallet must be created explicitly before it's unlocked
+	_, err = w.NewAddress()
+	assert.Equal(t, ErrWalletNotCreated, err)
+	assert.Equal(t, ErrWalletNotCreated, w.Unlock("passphrase", time.Minute))
+	assert.Error(t, w.Create(""))
+	assert.NoError(t, w.Create("passphrase"))
+	assert.Equal(t, ErrWalletCreated, w.Create("passphrase"))
+	_, err = w.NewAddress()
+	assert.Equal(t, ErrKeystoreLocked, err)
+	assert.EqualError(t, w.Unlock("passphrase", 0), "[Wallet], unlock timeout must be positive.")
+	assert.NoError(t, w.Unlock("passphrase", time.Minute))
+	address, err := w.NewAddress()
+	assert.NoError(t, err)
+	programHash, err := Uint168FromAddress(address)
+	assert.NoError(t, err)
+
+	// UTXOs are loaded when a related block is persisted
+	chain[*programHash] = store.addUnspents(*programHash, 1000, 2000)
+	w.blockPersistCompleted(&core.Block{Transactions: []*core.Transaction{
+		{Outputs: []*core.Output{{ProgramHash: *programHash}}},
+	}})
+	assert.Equal(t, Fixed64(3000), w.GetBalance())
+
+	receiver, err := NewAccount()
+	assert.NoError(t, err)
+	receiverAddress, err := receiver.Address()
+	assert.NoError(t, err)
+
+	w.Lock()
+	_, err = w.SendToAddress(receiverAddress, 500)
+	assert.Equal(t, ErrKeystoreLocked, err)
+	assert.Equal(t, ErrInvalidPassphrase, w.Unlock("wrong", time.Minute))
+	assert.NoError(t, w.Unlock("passphrase", time.Minute))
+
+	tx, err := w.SendToAddress(receiverAddress, 500)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(sent))
+	assert.Equal(t, Fixed64(2000), w.GetBalance())
+
+	// the pending spent UTXO is excluded by rescan until it's spent in chain
+	w.rollbackTransaction(&core.Block{})
+	assert.Equal(t, Fixed64(2000), w.GetBalance())
+	chain[*programHash] = []*UTXO{chain[*programHash][1], {
+		TxId: tx.Hash(), Index: 1, Value: 400, ProgramHash: *programHash,
+	}}
+	w.blockPersistCompleted(&core.Block{Transactions: []*core.Transaction{tx}})
+	assert.Equal(t, Fixed64(2400), w.GetBalance())
+	assert.Equal(t, 0, len(w.pending))
+
+	// the pending spent UTXO is released if it's dropped from the pool
+	tx, err = w.SendToAddress(receiverAddress, 1500)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(w.pending))
+	assert.Equal(t, Fixed64(400), w.GetBalance())
+	w.blockPersistCompleted(&core.Block{})
+	assert.Equal(t, Fixed64(400), w.GetBalance())
+	delete(pool, tx.Hash())
+	w.blockPersistCompleted(&core.Block{})
+	assert.Equal(t, 0, len(w.pending))
+	assert.Equal(t, Fixed64(2400), w.GetBalance())
+
+	// UTXOs of a multisig account are spendable only if the wallet holds M keys
+	multiAddress, err := w.NewMultiSignAddress(2, []*crypto.PublicKey{
+		w.keystore.GetAccounts()[0].PublicKey, receiver.PublicKey})
+	assert.NoError(t, err)
+	multiProgramHash, err := Uint168FromAddress(multiAddress)
+	assert.NoError(t, err)
+	chain[*multiProgramHash] = store.addUnspents(*multiProgramHash, 5000)
+	w.rollbackTransaction(&core.Block{})
+	assert.Equal(t, 3, len(w.ListUnspent()))
+	assert.Equal(t, Fixed64(2400), w.GetBalance())
+
+	_, err = w.SendToAddress(receiverAddress, 3000)
+	assert.EqualError(t, err, "[Wallet], available balance is not enough.")
+}